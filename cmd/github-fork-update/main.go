@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"os"
 
 	"github.com/mjdusa/github-fork-update/internal/run"
 )
@@ -11,6 +13,7 @@ func main() {
 
 	err := run.Run(ctx)
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(run.ExitCode(err))
 	}
 }
@@ -0,0 +1,240 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	keyDefaults = "defaults"
+	keyRepos    = "repos"
+
+	keySkip     = "skip"
+	keyBranches = "branches"
+	keyStrategy = "strategy"
+	keyNotify   = "notify"
+)
+
+// Config is a parsed configuration file. Defaults holds command line flag values in file order, Repos the
+// per-repository entries keyed by lower-cased owner/name.
+type Config struct {
+	Path     string
+	Defaults []*Setting
+	Repos    map[string]*Repo
+}
+
+// Setting is the value of a single flag given in the defaults section, a sequence yields one value per item.
+type Setting struct {
+	Name   string
+	Values []string
+	Line   int
+}
+
+// Repo is the per-repository entry for owner/name.
+type Repo struct {
+	Name     string
+	Line     int
+	Skip     bool
+	Branches []string
+	Strategy string
+	Notify   []string
+
+	// Lines maps each key given for the repository to the line it is on.
+	Lines map[string]int
+}
+
+// LineError points at the line of the configuration file the error is about.
+type LineError struct {
+	Path string
+	Line int
+	Err  error
+}
+
+func (lerr *LineError) Error() string {
+	return fmt.Sprintf("%s:%d: %v", lerr.Path, lerr.Line, lerr.Err)
+}
+
+func (lerr *LineError) Unwrap() error {
+	return lerr.Err
+}
+
+// Errorf returns a LineError for the given line of the file.
+func (cfg *Config) Errorf(line int, format string, args ...interface{}) error {
+	return &LineError{Path: cfg.Path, Line: line, Err: fmt.Errorf(format, args...)}
+}
+
+// Load reads and parses the configuration file at path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile error: %w", err)
+	}
+
+	return Parse(path, data)
+}
+
+// Parse parses configuration data, path is only used in error messages.
+func Parse(path string, data []byte) (*Config, error) {
+	cfg := Config{
+		Path:     path,
+		Defaults: make([]*Setting, 0),
+		Repos:    map[string]*Repo{},
+	}
+
+	doc := yaml.Node{}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&doc); err != nil {
+		if errors.Is(err, io.EOF) {
+			return &cfg, nil
+		}
+
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, cfg.Errorf(root.Line, "expected a mapping with %q and %q", keyDefaults, keyRepos)
+	}
+
+	for i := 0; i < len(root.Content); i += 2 {
+		key, val := root.Content[i], root.Content[i+1]
+
+		var err error
+
+		switch key.Value {
+		case keyDefaults:
+			err = cfg.parseDefaults(val)
+		case keyRepos:
+			err = cfg.parseRepos(val)
+		default:
+			err = cfg.Errorf(key.Line, "unknown key %q", key.Value)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &cfg, nil
+}
+
+func (cfg *Config) parseDefaults(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return cfg.Errorf(node.Line, "%s must be a mapping of flag names to values", keyDefaults)
+	}
+
+	for i := 0; i < len(node.Content); i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+
+		values, err := cfg.scalars(key.Value, val)
+		if err != nil {
+			return err
+		}
+
+		cfg.Defaults = append(cfg.Defaults, &Setting{Name: key.Value, Values: values, Line: key.Line})
+	}
+
+	return nil
+}
+
+func (cfg *Config) parseRepos(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return cfg.Errorf(node.Line, "%s must be a mapping of owner/name to settings", keyRepos)
+	}
+
+	for i := 0; i < len(node.Content); i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+
+		if !strings.Contains(key.Value, "/") {
+			return cfg.Errorf(key.Line, "expected owner/name, got %q", key.Value)
+		}
+
+		name := strings.ToLower(key.Value)
+		if prev, ok := cfg.Repos[name]; ok {
+			return cfg.Errorf(key.Line, "duplicate entry for %s, first given on line %d", key.Value, prev.Line)
+		}
+
+		repo, err := cfg.parseRepo(key, val)
+		if err != nil {
+			return err
+		}
+
+		cfg.Repos[name] = repo
+	}
+
+	return nil
+}
+
+func (cfg *Config) parseRepo(key *yaml.Node, node *yaml.Node) (*Repo, error) {
+	repo := Repo{
+		Name:  key.Value,
+		Line:  key.Line,
+		Lines: map[string]int{},
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return nil, cfg.Errorf(node.Line, "settings for %s must be a mapping", key.Value)
+	}
+
+	for i := 0; i < len(node.Content); i += 2 {
+		rkey, val := node.Content[i], node.Content[i+1]
+
+		var err error
+
+		switch rkey.Value {
+		case keySkip:
+			if derr := val.Decode(&repo.Skip); derr != nil {
+				err = cfg.Errorf(val.Line, "%s must be true or false", keySkip)
+			}
+		case keyStrategy:
+			if val.Kind != yaml.ScalarNode || len(val.Value) == 0 {
+				err = cfg.Errorf(val.Line, "%s must be a single value", keyStrategy)
+			}
+
+			repo.Strategy = val.Value
+		case keyBranches:
+			repo.Branches, err = cfg.scalars(keyBranches, val)
+		case keyNotify:
+			repo.Notify, err = cfg.scalars(keyNotify, val)
+		default:
+			err = cfg.Errorf(rkey.Line, "unknown key %q for %s", rkey.Value, key.Value)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		repo.Lines[rkey.Value] = rkey.Line
+	}
+
+	return &repo, nil
+}
+
+// scalars returns the value of a scalar node, or the items of a sequence of scalars.
+func (cfg *Config) scalars(name string, node *yaml.Node) ([]string, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return []string{node.Value}, nil
+	case yaml.SequenceNode:
+		values := make([]string, 0, len(node.Content))
+
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return nil, cfg.Errorf(item.Line, "%s items must be single values", name)
+			}
+
+			values = append(values, item.Value)
+		}
+
+		return values, nil
+	default:
+		return nil, cfg.Errorf(node.Line, "%s must be a value or a list of values", name)
+	}
+}
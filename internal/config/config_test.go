@@ -0,0 +1,97 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mjdusa/github-fork-update/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfig = `defaults:
+  concurrency: 4
+  branches: [release-*, "re:^v[0-9]+$"]
+  conflict-pr: true
+repos:
+  Owner/Frozen:
+    skip: true
+  owner/app:
+    branches: dev
+    strategy: reset
+    notify:
+      - team@example.com
+`
+
+func TestParse(t *testing.T) {
+	cfg, err := config.Parse("test.yml", []byte(testConfig))
+	if err != nil {
+		t.Fatalf("config.Parse returned error: %v", err)
+	}
+
+	if assert.Len(t, cfg.Defaults, 3) {
+		assert.Equal(t, &config.Setting{Name: "concurrency", Values: []string{"4"}, Line: 2}, cfg.Defaults[0])
+		assert.Equal(t, []string{"release-*", "re:^v[0-9]+$"}, cfg.Defaults[1].Values)
+	}
+
+	if assert.Contains(t, cfg.Repos, "owner/frozen") {
+		assert.True(t, cfg.Repos["owner/frozen"].Skip)
+		assert.Equal(t, "Owner/Frozen", cfg.Repos["owner/frozen"].Name)
+	}
+
+	if assert.Contains(t, cfg.Repos, "owner/app") {
+		app := cfg.Repos["owner/app"]
+		assert.Equal(t, []string{"dev"}, app.Branches)
+		assert.Equal(t, "reset", app.Strategy)
+		assert.Equal(t, []string{"team@example.com"}, app.Notify)
+		assert.Equal(t, 10, app.Lines["strategy"])
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	cfg, err := config.Parse("empty.yml", []byte{})
+	if assert.NoError(t, err) {
+		assert.Empty(t, cfg.Defaults)
+		assert.Empty(t, cfg.Repos)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "not a mapping", data: "- a\n", want: "bad.yml:1: expected a mapping"},
+		{name: "unknown section", data: "defaults: {}\nrepo: {}\n", want: `bad.yml:2: unknown key "repo"`},
+		{name: "nested default", data: "defaults:\n  concurrency:\n    a: 1\n", want: "bad.yml:3: concurrency must be"},
+		{name: "repo without owner", data: "repos:\n  app:\n    skip: true\n", want: `bad.yml:2: expected owner/name`},
+		{name: "unknown repo key", data: "repos:\n  o/a:\n    skip: true\n    stratgy: reset\n", want: `bad.yml:4: unknown key "stratgy"`},
+		{name: "bad skip", data: "repos:\n  o/a:\n    skip: maybe\n", want: "bad.yml:3: skip must be true or false"},
+		{name: "duplicate repo", data: "repos:\n  o/a: {skip: true}\n  O/A: {skip: false}\n", want: "bad.yml:3: duplicate entry"},
+		{name: "nested branches", data: "repos:\n  o/a:\n    branches: [[a]]\n", want: "bad.yml:3: branches items"},
+		{name: "invalid yaml", data: "defaults: [\n", want: "bad.yml: yaml:"},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			_, err := config.Parse("bad.yml", []byte(tst.data))
+			assert.ErrorContains(t, err, tst.want)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o600); err != nil {
+		t.Fatalf("os.WriteFile returned error: %v", err)
+	}
+
+	cfg, err := config.Load(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, path, cfg.Path)
+	}
+
+	_, err = config.Load(filepath.Join(t.TempDir(), "missing.yml"))
+	assert.Error(t, err)
+}
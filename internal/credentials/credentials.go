@@ -0,0 +1,52 @@
+// Package credentials finds a GitHub token stored by other tools, the gh CLI and git credential helpers.
+package credentials
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// DefaultHost is the host credentials are looked up for when no GitHub Enterprise Server is used.
+const DefaultHost = "github.com"
+
+// ErrNoCredential is returned when a source has no token for the host.
+var ErrNoCredential = errors.New("no credential found")
+
+// HostFromBaseURL returns the host the tokens for baseURL are stored under, DefaultHost when baseURL is
+// empty or not a URL.
+func HostFromBaseURL(baseURL string) string {
+	parsed, err := url.Parse(baseURL)
+	if err != nil || len(parsed.Hostname()) == 0 {
+		return DefaultHost
+	}
+
+	return strings.ToLower(parsed.Hostname())
+}
+
+// cachedToken remembers the first token a source found so the helper behind it runs once.
+type cachedToken struct {
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+func (cache *cachedToken) get(fetch func() (string, error)) (*oauth2.Token, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.token != nil {
+		return cache.token, nil
+	}
+
+	token, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	cache.token = &oauth2.Token{AccessToken: token}
+
+	return cache.token, nil
+}
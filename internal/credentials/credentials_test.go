@@ -0,0 +1,14 @@
+package credentials_test
+
+import (
+	"testing"
+
+	"github.com/mjdusa/github-fork-update/internal/credentials"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostFromBaseURL(t *testing.T) {
+	assert.Equal(t, "github.com", credentials.HostFromBaseURL(""))
+	assert.Equal(t, "github.com", credentials.HostFromBaseURL("://bad"))
+	assert.Equal(t, "github.example.com", credentials.HostFromBaseURL("https://GitHub.Example.com:8443/api/v3/"))
+}
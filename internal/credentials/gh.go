@@ -0,0 +1,115 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"gopkg.in/yaml.v3"
+)
+
+// GHHosts reads the token the gh CLI stored for Host in its hosts.yml file.
+type GHHosts struct {
+	// Path is the hosts.yml file, DefaultGHHostsPath when empty.
+	Path string
+	Host string
+
+	cache cachedToken
+}
+
+// ghHost is the part of a hosts.yml entry needed to find the token. Newer gh releases keep a token per
+// account under users and the active account in user.
+type ghHost struct {
+	User       string `yaml:"user"`
+	OAuthToken string `yaml:"oauth_token"`
+	Users      map[string]struct {
+		OAuthToken string `yaml:"oauth_token"`
+	} `yaml:"users"`
+}
+
+// NewGHHosts returns the gh CLI source for host.
+func NewGHHosts(host string) *GHHosts {
+	return &GHHosts{Host: host}
+}
+
+// DefaultGHHostsPath returns where the gh CLI keeps hosts.yml, honouring GH_CONFIG_DIR and XDG_CONFIG_HOME.
+func DefaultGHHostsPath() (string, error) {
+	if dir := os.Getenv("GH_CONFIG_DIR"); len(dir) > 0 {
+		return filepath.Join(dir, "hosts.yml"), nil
+	}
+
+	if dir := os.Getenv("XDG_CONFIG_HOME"); len(dir) > 0 {
+		return filepath.Join(dir, "gh", "hosts.yml"), nil
+	}
+
+	if dir := os.Getenv("AppData"); runtime.GOOS == "windows" && len(dir) > 0 {
+		return filepath.Join(dir, "GitHub CLI", "hosts.yml"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("os.UserHomeDir error: %w", err)
+	}
+
+	return filepath.Join(home, ".config", "gh", "hosts.yml"), nil
+}
+
+func (src *GHHosts) Name() string {
+	return "gh hosts.yml"
+}
+
+// Token returns the stored token, ErrNoCredential when gh has not logged in to the host or keeps the
+// token in the system keyring.
+func (src *GHHosts) Token() (*oauth2.Token, error) {
+	return src.cache.get(src.read)
+}
+
+func (src *GHHosts) read() (string, error) {
+	path := src.Path
+	if len(path) == 0 {
+		dpath, err := DefaultGHHostsPath()
+		if err != nil {
+			return "", err
+		}
+
+		path = dpath
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s does not exist", ErrNoCredential, path)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("os.ReadFile error: %w", err)
+	}
+
+	hosts := make(map[string]ghHost)
+	if uerr := yaml.Unmarshal(data, &hosts); uerr != nil {
+		return "", fmt.Errorf("%s: %w", path, uerr)
+	}
+
+	for name, host := range hosts {
+		if !strings.EqualFold(name, src.Host) {
+			continue
+		}
+
+		if len(host.OAuthToken) > 0 {
+			return host.OAuthToken, nil
+		}
+
+		if user, ok := host.Users[host.User]; ok && len(user.OAuthToken) > 0 {
+			return user.OAuthToken, nil
+		}
+
+		return "", fmt.Errorf("%w: no oauth_token for %s in %s, gh may keep it in the system keyring",
+			ErrNoCredential, src.Host, path)
+	}
+
+	return "", fmt.Errorf("%w: %s is not logged in to %s", ErrNoCredential, path, src.Host)
+}
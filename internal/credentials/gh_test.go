@@ -0,0 +1,99 @@
+package credentials_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mjdusa/github-fork-update/internal/credentials"
+	"github.com/stretchr/testify/assert"
+)
+
+const hostsYAML = `github.com:
+    user: octocat
+    oauth_token: gho_legacy
+    git_protocol: https
+GitHub.Example.com:
+    user: hubot
+    users:
+        octocat:
+            oauth_token: gho_other
+        hubot:
+            oauth_token: gho_active
+keyring.example.com:
+    user: octocat
+    git_protocol: https
+`
+
+func TestGHHostsToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.yml")
+	if err := os.WriteFile(path, []byte(hostsYAML), 0o600); err != nil {
+		t.Fatalf("os.WriteFile returned error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		host    string
+		want    string
+		wantErr string
+	}{
+		{name: "legacy token", path: path, host: "github.com", want: "gho_legacy"},
+		{name: "active account", path: path, host: "github.example.com", want: "gho_active"},
+		{name: "keyring", path: path, host: "keyring.example.com", wantErr: "system keyring"},
+		{name: "not logged in", path: path, host: "other.example.com", wantErr: "is not logged in to other.example.com"},
+		{name: "missing file", path: path + ".missing", host: "github.com", wantErr: "does not exist"},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			src := credentials.GHHosts{Path: tst.path, Host: tst.host}
+
+			token, err := src.Token()
+			if len(tst.wantErr) > 0 {
+				assert.ErrorIs(t, err, credentials.ErrNoCredential)
+				assert.ErrorContains(t, err, tst.wantErr)
+
+				return
+			}
+
+			if assert.NoError(t, err) {
+				assert.Equal(t, tst.want, token.AccessToken)
+			}
+		})
+	}
+}
+
+func TestGHHostsTokenMalformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.yml")
+	if err := os.WriteFile(path, []byte("github.com: [\n"), 0o600); err != nil {
+		t.Fatalf("os.WriteFile returned error: %v", err)
+	}
+
+	src := credentials.GHHosts{Path: path, Host: "github.com"}
+
+	_, err := src.Token()
+	assert.ErrorContains(t, err, path)
+	assert.NotErrorIs(t, err, credentials.ErrNoCredential)
+}
+
+func TestDefaultGHHostsPath(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Setenv("GH_CONFIG_DIR", dir)
+
+	path, err := credentials.DefaultGHHostsPath()
+	if assert.NoError(t, err) {
+		assert.Equal(t, filepath.Join(dir, "hosts.yml"), path)
+	}
+
+	t.Setenv("GH_CONFIG_DIR", "")
+	t.Setenv("XDG_CONFIG_HOME", dir)
+
+	path, err = credentials.DefaultGHHostsPath()
+	if assert.NoError(t, err) {
+		assert.Equal(t, filepath.Join(dir, "gh", "hosts.yml"), path)
+	}
+
+	assert.Equal(t, "gh hosts.yml", credentials.NewGHHosts("github.com").Name())
+}
@@ -0,0 +1,83 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// GitCredentialTimeout bounds how long a credential helper may take to answer.
+const GitCredentialTimeout = 30 * time.Second
+
+// GitCredential asks the configured git credential helpers for the password stored for https://Host.
+type GitCredential struct {
+	Host string
+
+	// Run executes git with args, feeding it stdin, and returns its output. It runs the git binary
+	// with prompts disabled when nil.
+	Run func(ctx context.Context, stdin string, args ...string) (string, error)
+
+	cache cachedToken
+}
+
+// NewGitCredential returns the git credential source for host.
+func NewGitCredential(host string) *GitCredential {
+	return &GitCredential{Host: host}
+}
+
+func (src *GitCredential) Name() string {
+	return "git credential fill"
+}
+
+// Token returns the password of the stored credential, ErrNoCredential when no helper has one.
+func (src *GitCredential) Token() (*oauth2.Token, error) {
+	return src.cache.get(src.fill)
+}
+
+func (src *GitCredential) fill() (string, error) {
+	run := src.Run
+	if run == nil {
+		run = runGit
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), GitCredentialTimeout)
+	defer cancel()
+
+	out, err := run(ctx, fmt.Sprintf("protocol=https\nhost=%s\n\n", src.Host), "credential", "fill")
+	if err != nil {
+		return "", fmt.Errorf("%w: git credential fill for %s: %w", ErrNoCredential, src.Host, err)
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		if password, ok := strings.CutPrefix(strings.TrimRight(line, "\r"), "password="); ok && len(password) > 0 {
+			return password, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: git credential fill returned no password for %s", ErrNoCredential, src.Host)
+}
+
+// runGit runs git without letting it or a helper prompt on the terminal. The output is not part of
+// the error as it may hold the credential.
+func runGit(ctx context.Context, stdin string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never")
+
+	var stdout, stderr bytes.Buffer
+
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
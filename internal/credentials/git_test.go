@@ -0,0 +1,58 @@
+package credentials_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mjdusa/github-fork-update/internal/credentials"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitCredentialToken(t *testing.T) {
+	calls := 0
+	src := credentials.NewGitCredential("github.com")
+	src.Run = func(_ context.Context, stdin string, args ...string) (string, error) {
+		calls++
+
+		assert.Equal(t, "protocol=https\nhost=github.com\n\n", stdin)
+		assert.Equal(t, []string{"credential", "fill"}, args)
+
+		return "protocol=https\nhost=github.com\nusername=octocat\npassword=gho_helper\n", nil
+	}
+
+	for range 2 {
+		token, err := src.Token()
+		if assert.NoError(t, err) {
+			assert.Equal(t, "gho_helper", token.AccessToken)
+		}
+	}
+
+	assert.Equal(t, 1, calls, "the helper runs once")
+	assert.Equal(t, "git credential fill", src.Name())
+}
+
+func TestGitCredentialTokenErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		err  error
+		want string
+	}{
+		{name: "helper fails", err: errors.New("terminal prompts disabled"), want: "terminal prompts disabled"},
+		{name: "no password", out: "protocol=https\nhost=github.com\nusername=octocat\n", want: "returned no password"},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			src := credentials.NewGitCredential("github.com")
+			src.Run = func(context.Context, string, ...string) (string, error) {
+				return tst.out, tst.err
+			}
+
+			_, err := src.Token()
+			assert.ErrorIs(t, err, credentials.ErrNoCredential)
+			assert.ErrorContains(t, err, tst.want)
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package environment
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/mjdusa/github-fork-update/internal/credentials"
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+)
+
+const (
+	// AuthSourceFlag, AuthSourceStdin, AuthSourceFile and AuthSourceTokensFile name where the token came from.
+	AuthSourceFlag       = "-auth"
+	AuthSourceStdin      = "-auth-stdin"
+	AuthSourceFile       = "-auth-file"
+	AuthSourceTokensFile = "-tokens-file"
+
+	// GitHubTokenEnv and GHTokenEnv are the environment variables read, in this order, when no
+	// token source is given. The token helpers are asked only when neither is set.
+	GitHubTokenEnv = "GITHUB_TOKEN"
+	GHTokenEnv     = "GH_TOKEN"
+
+	redacted = "[redacted]"
+)
+
+// Secret is a string flag that never prints its value.
+type Secret string
+
+// Set stores value as the secret.
+func (sec *Secret) Set(value string) error {
+	*sec = Secret(value)
+
+	return nil
+}
+
+// String returns a placeholder for a non-empty secret so formatting never reveals it.
+func (sec Secret) String() string {
+	if len(sec) == 0 {
+		return ""
+	}
+
+	return redacted
+}
+
+// GoString keeps %#v from revealing the secret.
+func (sec Secret) GoString() string {
+	return sec.String()
+}
+
+// Value returns the secret itself.
+func (sec Secret) Value() string {
+	return string(sec)
+}
+
+// DefaultTokenHelpers returns the gh CLI and git credential helper sources for host.
+func DefaultTokenHelpers(host string) []githubapi.TokenSource {
+	return []githubapi.TokenSource{credentials.NewGHHosts(host), credentials.NewGitCredential(host)}
+}
+
+// resolveAuth sets Auth from, in order of precedence, -auth, -auth-stdin, -auth-file, -tokens-file,
+// GITHUB_TOKEN, GH_TOKEN and the token helpers for the host of BaseURL. At most one of the first four may
+// be given, -tokens-file also sets TokenPool. A GitHub App given by -app-id replaces all of them.
+func (params *Parameters) resolveAuth(stdin io.Reader, helpers func(host string) []githubapi.TokenSource) error {
+	if params.AppID != 0 {
+		return params.resolveAppAuth()
+	}
+
+	if len(params.AppKeyFile) > 0 || params.AppInstallationID != 0 || len(params.AppOrg) > 0 {
+		return fmt.Errorf("-app-key-file, -app-installation-id and -app-org require -app-id")
+	}
+
+	given := make([]string, 0, 4)
+
+	if len(params.Auth) > 0 {
+		given = append(given, AuthSourceFlag)
+	}
+
+	if params.AuthStdin {
+		given = append(given, AuthSourceStdin)
+	}
+
+	if len(params.AuthFile) > 0 {
+		given = append(given, AuthSourceFile)
+	}
+
+	if len(params.TokensFile) > 0 {
+		given = append(given, AuthSourceTokensFile)
+	}
+
+	if len(given) > 1 {
+		return fmt.Errorf("only one of %s may be given", strings.Join(given, ", "))
+	}
+
+	var token string
+
+	var err error
+
+	switch {
+	case len(params.Auth) > 0:
+		params.AuthSource = AuthSourceFlag
+		token = params.Auth.Value()
+	case params.AuthStdin:
+		params.AuthSource = AuthSourceStdin
+		token, err = readTokenStdin(stdin)
+	case len(params.AuthFile) > 0:
+		params.AuthSource = AuthSourceFile
+		token, err = readTokenFile(params.AuthFile)
+	case len(params.TokensFile) > 0:
+		params.AuthSource = AuthSourceTokensFile
+		params.TokenPool, token, err = readTokensFile(params.TokensFile)
+	default:
+		for _, name := range []string{GitHubTokenEnv, GHTokenEnv} {
+			if token = strings.TrimSpace(os.Getenv(name)); len(token) > 0 {
+				params.AuthSource = name
+
+				break
+			}
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(token) > 0 {
+		params.Auth = Secret(token)
+		params.TokenSource = githubapi.StaticTokenSource(token, params.AuthSource)
+
+		return nil
+	}
+
+	return params.resolveHelperAuth(helpers(credentials.HostFromBaseURL(params.BaseURL)))
+}
+
+// resolveHelperAuth takes the token from the first helper that has one. Every helper that failed is
+// named in the error when none has.
+func (params *Parameters) resolveHelperAuth(helpers []githubapi.TokenSource) error {
+	failed := make([]string, 0, len(helpers))
+
+	for _, helper := range helpers {
+		token, err := helper.Token()
+		if err == nil && len(token.AccessToken) > 0 {
+			params.Auth = Secret(token.AccessToken)
+			params.AuthSource = helper.Name()
+			params.TokenSource = helper
+
+			return nil
+		}
+
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", helper.Name(), err))
+		}
+	}
+
+	if len(failed) == 0 {
+		return fmt.Errorf("empty auth token error")
+	}
+
+	return fmt.Errorf("empty auth token error, give -auth-file, -auth-stdin or GITHUB_TOKEN (%s)",
+		strings.Join(failed, "; "))
+}
+
+// resolveAppAuth sets TokenSource to the installation tokens of the GitHub App. Installation tokens
+// belong to no user, so only the forks of -app-org are synced when neither -user nor -repos is given.
+func (params *Parameters) resolveAppAuth() error {
+	switch {
+	case len(params.Auth) > 0 || params.AuthStdin || len(params.AuthFile) > 0 || len(params.TokensFile) > 0:
+		return fmt.Errorf("-app-id cannot be combined with -auth, -auth-stdin, -auth-file or -tokens-file")
+	case len(params.AppKeyFile) == 0:
+		return fmt.Errorf("-app-id requires -app-key-file")
+	case params.AppInstallationID == 0 && len(params.AppOrg) == 0:
+		return fmt.Errorf("-app-id requires -app-installation-id or -app-org")
+	case params.AllOrgs:
+		return fmt.Errorf("-all-orgs cannot be used with -app-id, list the organizations with -org")
+	case len(params.User) == 0 && len(params.Repos) == 0 && len(params.AppOrg) == 0:
+		return fmt.Errorf("-app-id requires -user, -repos or -app-org to know whose forks to sync")
+	}
+
+	if len(params.User) == 0 && len(params.Repos) == 0 {
+		params.Orgs = append(params.Orgs, params.AppOrg)
+		params.OrgsOnly = true
+	}
+
+	data, err := readPrivateFile(params.AppKeyFile)
+	if err != nil {
+		return err
+	}
+
+	key, err := githubapi.ParseAppKey(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", params.AppKeyFile, err)
+	}
+
+	src := githubapi.AppTokenSource{
+		AppID:          params.AppID,
+		Key:            key,
+		InstallationID: params.AppInstallationID,
+		Org:            params.AppOrg,
+		BaseURL:        params.BaseURL,
+	}
+
+	params.AuthSource = src.Name()
+	params.TokenSource = &src
+
+	return nil
+}
+
+func readTokenStdin(stdin io.Reader) (string, error) {
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("reading token from stdin error: %w", err)
+	}
+
+	token := strings.TrimSpace(line)
+	if len(token) == 0 {
+		return "", fmt.Errorf("empty token on stdin")
+	}
+
+	return token, nil
+}
+
+// readTokenFile reads the token from the first line of path, refusing files anyone may read.
+func readTokenFile(path string) (string, error) {
+	data, err := readPrivateFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	token, _, _ := strings.Cut(string(data), "\n")
+	if token = strings.TrimSpace(token); len(token) == 0 {
+		return "", fmt.Errorf("empty token in %s", path)
+	}
+
+	return token, nil
+}
+
+// readTokensFile reads the token pool of path, one token per line, preceded by "owner,...=" when it is
+// meant for the forks of those owners only. Blank lines and lines starting with # are skipped. The first
+// token is returned as well.
+func readTokensFile(path string) ([]githubapi.PoolToken, string, error) {
+	data, err := readPrivateFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	pool := make([]githubapi.PoolToken, 0)
+	first := ""
+
+	for num, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		list, token, found := strings.Cut(line, "=")
+		if !found {
+			list, token = "", line
+		}
+
+		owners := make([]string, 0)
+
+		for _, owner := range strings.Split(list, ",") {
+			if owner = strings.TrimSpace(owner); len(owner) > 0 {
+				owners = append(owners, owner)
+			}
+		}
+
+		if token = strings.TrimSpace(token); len(token) == 0 {
+			return nil, "", fmt.Errorf("%s:%d: empty token", path, num+1)
+		}
+
+		if found && len(owners) == 0 {
+			return nil, "", fmt.Errorf("%s:%d: no owner before =", path, num+1)
+		}
+
+		if len(first) == 0 {
+			first = token
+		}
+
+		pool = append(pool, githubapi.PoolToken{
+			Source: githubapi.StaticTokenSource(token, fmt.Sprintf("%s:%d", path, num+1)),
+			Owners: owners,
+		})
+	}
+
+	if len(pool) == 0 {
+		return nil, "", fmt.Errorf("no tokens in %s", path)
+	}
+
+	return pool, first, nil
+}
+
+// readPrivateFile reads a file holding a credential, refusing files anyone may read.
+func readPrivateFile(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.Stat error: %w", err)
+	}
+
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o004 != 0 {
+		return nil, fmt.Errorf("refusing to read %s: file is world-readable (mode %04o), run 'chmod 600 %s'",
+			path, info.Mode().Perm(), path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile error: %w", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,311 @@
+package environment_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mjdusa/github-fork-update/internal/credentials"
+	"github.com/mjdusa/github-fork-update/internal/environment"
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+)
+
+func (s *EnvSuite) writeToken(mode os.FileMode, data string) string {
+	path := filepath.Join(s.T().TempDir(), "token")
+	if err := os.WriteFile(path, []byte(data), mode); err != nil {
+		s.T().Fatalf("os.WriteFile returned error: %v", err)
+	}
+
+	if err := os.Chmod(path, mode); err != nil {
+		s.T().Fatalf("os.Chmod returned error: %v", err)
+	}
+
+	return path
+}
+
+func (s *EnvSuite) TestGetParametersAuthSources() {
+	tokenFile := s.writeToken(0o600, "file_token\nignored\n")
+
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		env        map[string]string
+		wantToken  string
+		wantSource string
+	}{
+		{
+			name:       "flag wins over environment",
+			args:       []string{"-auth", "flag_token"},
+			env:        map[string]string{environment.GitHubTokenEnv: "env_token"},
+			wantToken:  "flag_token",
+			wantSource: environment.AuthSourceFlag,
+		},
+		{
+			name:       "stdin",
+			args:       []string{"-auth-stdin"},
+			stdin:      "  stdin_token \n",
+			env:        map[string]string{environment.GitHubTokenEnv: "env_token"},
+			wantToken:  "stdin_token",
+			wantSource: environment.AuthSourceStdin,
+		},
+		{
+			name:       "file",
+			args:       []string{"-auth-file", tokenFile},
+			wantToken:  "file_token",
+			wantSource: environment.AuthSourceFile,
+		},
+		{
+			name:       "GITHUB_TOKEN wins over GH_TOKEN",
+			env:        map[string]string{environment.GitHubTokenEnv: "github_token", environment.GHTokenEnv: "gh_token"},
+			wantToken:  "github_token",
+			wantSource: environment.GitHubTokenEnv,
+		},
+		{
+			name:       "GH_TOKEN",
+			env:        map[string]string{environment.GHTokenEnv: "gh_token"},
+			wantToken:  "gh_token",
+			wantSource: environment.GHTokenEnv,
+		},
+	}
+
+	for _, tst := range tests {
+		s.Run(tst.name, func() {
+			for name, value := range tst.env {
+				s.T().Setenv(name, value)
+			}
+
+			env := environment.Environment{Stdin: strings.NewReader(tst.stdin)}
+			os.Args = append([]string{"app"}, tst.args...)
+
+			params, err := env.GetParameters()
+			if s.NoError(err) {
+				s.Equal(tst.wantToken, params.Auth.Value())
+				s.Equal(tst.wantSource, params.AuthSource)
+			}
+		})
+	}
+}
+
+func (s *EnvSuite) TestGetParametersAuthErrors() {
+	worldReadable := s.writeToken(0o644, "file_token\n")
+	empty := s.writeToken(0o600, "\n")
+
+	tests := []struct {
+		name  string
+		args  []string
+		stdin string
+		want  string
+	}{
+		{name: "several sources", args: []string{"-auth", "a", "-auth-stdin"}, want: "only one of -auth, -auth-stdin"},
+		{name: "world readable", args: []string{"-auth-file", worldReadable}, want: "file is world-readable (mode 0644)"},
+		{name: "empty file", args: []string{"-auth-file", empty}, want: "empty token in"},
+		{name: "missing file", args: []string{"-auth-file", worldReadable + ".missing"}, want: "os.Stat error"},
+		{name: "empty stdin", args: []string{"-auth-stdin"}, stdin: "\n", want: "empty token on stdin"},
+		{name: "nothing", want: "empty auth token error"},
+	}
+
+	for _, tst := range tests {
+		s.Run(tst.name, func() {
+			env := environment.Environment{Stdin: strings.NewReader(tst.stdin)}
+			os.Args = append([]string{"app"}, tst.args...)
+
+			_, err := env.GetParameters()
+			s.ErrorContains(err, tst.want)
+			s.NotContains(fmt.Sprint(err), "file_token")
+		})
+	}
+}
+
+func (s *EnvSuite) TestGetParametersTokensFile() {
+	tokensFile := s.writeToken(0o600, "# bot account\nbot_token\n\nacme, acme-labs = acme_token\n")
+	env := environment.Environment{Stdin: strings.NewReader("")}
+	os.Args = []string{"app", "-tokens-file", tokensFile}
+
+	params, err := env.GetParameters()
+	if !s.NoError(err) {
+		return
+	}
+
+	s.Equal(environment.AuthSourceTokensFile, params.AuthSource)
+	s.Equal("bot_token", params.Auth.Value())
+
+	if s.Len(params.TokenPool, 2) {
+		s.Equal(tokensFile+":2", params.TokenPool[0].Source.Name())
+		s.Empty(params.TokenPool[0].Owners)
+		s.Equal(tokensFile+":4", params.TokenPool[1].Source.Name())
+		s.Equal([]string{"acme", "acme-labs"}, params.TokenPool[1].Owners)
+
+		token, terr := params.TokenPool[1].Source.Token()
+		if s.NoError(terr) {
+			s.Equal("acme_token", token.AccessToken)
+		}
+	}
+
+	s.NotContains(fmt.Sprintf("%+v", params), "acme_token")
+}
+
+func (s *EnvSuite) TestGetParametersTokensFileErrors() {
+	tests := []struct {
+		name string
+		data string
+		args []string
+		want string
+	}{
+		{name: "no tokens", data: "# none yet\n", want: "no tokens in"},
+		{name: "no owner", data: "a_token\n=b_token\n", want: ":2: no owner before ="},
+		{name: "empty token", data: "acme=\n", want: ":1: empty token"},
+		{name: "with -auth", data: "a_token\n", args: []string{"-auth", "b"}, want: "only one of -auth, -tokens-file"},
+	}
+
+	for _, tst := range tests {
+		s.Run(tst.name, func() {
+			env := environment.Environment{Stdin: strings.NewReader("")}
+			os.Args = append([]string{"app", "-tokens-file", s.writeToken(0o600, tst.data)}, tst.args...)
+
+			_, err := env.GetParameters()
+			s.ErrorContains(err, tst.want)
+		})
+	}
+}
+
+func (s *EnvSuite) TestSecretRedacted() {
+	secret := environment.Secret("ghp_secret")
+
+	s.Equal("[redacted]", secret.String())
+	s.Equal("[redacted]", fmt.Sprintf("%v %s %#v", &secret, &secret, secret)[:10])
+	s.NotContains(fmt.Sprintf("%v %s %#v %+v", secret, secret, secret, environment.Parameters{Auth: secret}), "ghp_secret")
+	s.Equal("ghp_secret", secret.Value())
+
+	var empty environment.Secret
+	s.Empty(empty.String())
+}
+
+func (s *EnvSuite) TestGetParametersTokenHelpers() {
+	missing := credentials.NewGitCredential("github.com")
+	missing.Run = func(context.Context, string, ...string) (string, error) {
+		return "", errors.New("no helper")
+	}
+
+	hosts := make([]string, 0, 2)
+	env := environment.Environment{
+		Stdin: strings.NewReader(""),
+		TokenHelpers: func(host string) []githubapi.TokenSource {
+			hosts = append(hosts, host)
+
+			return []githubapi.TokenSource{missing, githubapi.StaticTokenSource("helper_token", "test helper")}
+		},
+	}
+
+	os.Args = []string{"app", "-base-url", "https://GitHub.example.com/api/v3/"}
+
+	params, err := env.GetParameters()
+	s.Require().NoError(err)
+	s.Equal("helper_token", params.Auth.Value())
+	s.Equal("test helper", params.AuthSource)
+	s.Equal("test helper", params.TokenSource.Name())
+	s.Equal([]string{"github.example.com"}, hosts)
+
+	s.T().Setenv(environment.GHTokenEnv, "gh_token")
+	os.Args = []string{"app"}
+
+	params, err = env.GetParameters()
+	s.Require().NoError(err)
+	s.Equal(environment.GHTokenEnv, params.AuthSource)
+	s.Len(hosts, 1, "helpers are not asked when a token is set")
+}
+
+func (s *EnvSuite) TestGetParametersTokenHelpersFail() {
+	missing := credentials.NewGitCredential("github.com")
+	missing.Run = func(context.Context, string, ...string) (string, error) {
+		return "", errors.New("no helper")
+	}
+
+	env := environment.Environment{
+		Stdin: strings.NewReader(""),
+		TokenHelpers: func(string) []githubapi.TokenSource {
+			return []githubapi.TokenSource{missing}
+		},
+	}
+
+	os.Args = []string{"app"}
+
+	_, err := env.GetParameters()
+	s.ErrorContains(err, "empty auth token error")
+	s.ErrorContains(err, "git credential fill: no credential found")
+}
+
+func (s *EnvSuite) TestGetParametersApp() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+
+	keyFile := s.writeToken(0o600,
+		string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})))
+	s.T().Setenv(environment.GitHubTokenEnv, "env_token")
+
+	env := environment.Environment{Stdin: strings.NewReader("")}
+	os.Args = []string{"app", "-app-id", "42", "-app-key-file", keyFile, "-app-org", "widgets"}
+
+	params, err := env.GetParameters()
+	s.Require().NoError(err)
+	s.Equal("GitHub App 42", params.AuthSource)
+	s.Equal("GitHub App 42", params.TokenSource.Name())
+	s.Empty(params.User)
+	s.Equal(environment.StringList{"widgets"}, params.Orgs, "only the app organization is synced")
+	s.True(params.OrgsOnly)
+	s.Empty(params.Auth)
+
+	os.Args = []string{"app", "-app-id", "42", "-app-key-file", keyFile, "-app-installation-id", "7", "-user", "octocat"}
+
+	params, err = env.GetParameters()
+	s.Require().NoError(err)
+	s.Equal("octocat", params.User)
+	s.False(params.OrgsOnly)
+}
+
+func (s *EnvSuite) TestGetParametersAppErrors() {
+	keyFile := s.writeToken(0o600, "not a key\n")
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "with token", args: []string{"-app-id", "1", "-auth", "a"}, want: "-app-id cannot be combined with -auth"},
+		{name: "no key", args: []string{"-app-id", "1", "-app-org", "o"}, want: "-app-id requires -app-key-file"},
+		{
+			name: "no installation",
+			args: []string{"-app-id", "1", "-app-key-file", keyFile},
+			want: "-app-id requires -app-installation-id or -app-org",
+		},
+		{
+			name: "no owner",
+			args: []string{"-app-id", "1", "-app-key-file", keyFile, "-app-installation-id", "7"},
+			want: "-app-id requires -user, -repos or -app-org",
+		},
+		{
+			name: "all orgs",
+			args: []string{"-app-id", "1", "-app-key-file", keyFile, "-app-org", "o", "-all-orgs"},
+			want: "-all-orgs cannot be used with -app-id",
+		},
+		{name: "bad key", args: []string{"-app-id", "1", "-app-key-file", keyFile, "-app-org", "o"}, want: "no PEM data found"},
+		{name: "without app id", args: []string{"-app-org", "o"}, want: "require -app-id"},
+	}
+
+	for _, tst := range tests {
+		s.Run(tst.name, func() {
+			env := environment.Environment{Stdin: strings.NewReader("")}
+			os.Args = append([]string{"app"}, tst.args...)
+
+			_, err := env.GetParameters()
+			s.ErrorContains(err, tst.want)
+		})
+	}
+}
@@ -3,13 +3,276 @@ package environment
 import (
 	"flag"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Masterminds/semver/v3"
+	"github.com/mjdusa/github-fork-update/internal/config"
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/httpcache"
+	"github.com/mjdusa/github-fork-update/internal/pattern"
 	"github.com/mjdusa/github-fork-update/internal/version"
 )
 
+const (
+	// CommandSync merges upstream into the forks, it is used when no command is given.
+	CommandSync = "sync"
+
+	// CommandApply executes a plan file written by a dry-run.
+	CommandApply = "apply"
+
+	// CommandRollback moves fork branches back to the heads recorded for a previous run.
+	CommandRollback = "rollback"
+
+	// CommandDoctor checks the configuration, token and connectivity and prints a checklist.
+	CommandDoctor = "doctor"
+
+	// EnvPrefix prefixes the environment variable of every flag, e.g. GITHUB_FORK_UPDATE_CONCURRENCY.
+	EnvPrefix = "GITHUB_FORK_UPDATE_"
+
+	// DefaultConcurrency is the number of forks merged in parallel when -concurrency is not given.
+	DefaultConcurrency = 1
+
+	configFlag = "config"
+	authFlag   = "auth"
+)
+
 type Environment struct {
+	// Stdin is read by -auth-stdin, os.Stdin when nil.
+	Stdin io.Reader
+
+	// TokenHelpers returns the sources tried, in order, for the token of host when no token is given
+	// on the command line or in the environment. DefaultTokenHelpers is used when nil.
+	TokenHelpers func(host string) []githubapi.TokenSource
+}
+
+// Parameters holds the values resolved from the command line.
+type Parameters struct {
+	// Command is the subcommand to run and Args its positional arguments.
+	Command string
+	Args    []string
+
+	// ConfigFile is the YAML file flag defaults and per-repository settings are read from.
+	ConfigFile string
+
+	// ConfigErr and AuthErr hold the config file and token errors of the doctor command, which reports
+	// them instead of failing.
+	ConfigErr error
+	AuthErr   error
+
+	// Auth is the token, resolved from -auth, -auth-stdin, -auth-file, -tokens-file, GITHUB_TOKEN,
+	// GH_TOKEN or a token helper as recorded in AuthSource. TokenSource supplies it to the client.
+	Auth        Secret
+	AuthFile    string
+	AuthStdin   bool
+	AuthSource  string
+	TokenSource githubapi.TokenSource
+
+	// TokensFile lists several tokens to rotate between, TokenPool holds them once resolved.
+	TokensFile string
+	TokenPool  []githubapi.PoolToken
+
+	// AppID and AppKeyFile authenticate as a GitHub App instead of with a token, using the installation
+	// AppInstallationID or, when zero, the installation on AppOrg.
+	AppID             int64
+	AppKeyFile        string
+	AppInstallationID int64
+	AppOrg            string
+
+	// BaseURL and UploadURL point at a GitHub Enterprise Server, github.com is used when BaseURL is empty.
+	BaseURL   string
+	UploadURL string
+
+	Debug       bool
+	Verbose     bool
+	Concurrency int
+
+	// MaxRateWait is the longest pause for a rate limit reset, rate limit errors fail at once when zero.
+	MaxRateWait time.Duration
+
+	// MaxRetries is how often a request that failed transiently is retried, zero never retries.
+	MaxRetries int
+
+	ContinueOnError bool
+	DryRun          bool
+	PlanOut         string
+
+	Branches       pattern.List
+	RepoBranches   RepoPatterns
+	CreateBranches pattern.List
+	ConflictPR     bool
+
+	Strategy       string
+	RepoStrategies RepoValues
+
+	// Discovery selects the API the forks are listed with.
+	Discovery string
+
+	SyncTags       bool
+	Tags           pattern.List
+	TagRange       string
+	TagConstraints *semver.Constraints
+	PruneTags      bool
+
+	// HistoryDir is where runs are recorded, empty for the per-user default. RunID selects the
+	// run to roll back, Force rolls back branches that moved on since the run.
+	HistoryDir string
+	NoHistory  bool
+	RunID      string
+	Force      bool
+
+	// CacheDir is where list and compare responses are kept for conditional requests, empty for the
+	// per-user default. The cache is trimmed to CacheMaxMB megabytes.
+	CacheDir   string
+	CacheMaxMB int
+	NoCache    bool
+
+	// User is the account whose forks are synced, the authenticated user when empty. Repos, extended
+	// by the lines of ReposFile, names the owner/name forks to sync instead of listing accounts.
+	User      string
+	Repos     StringList
+	ReposFile string
+
+	// Orgs and AllOrgs add organization forks to the ones of the user, OrgsOnly leaves out the user.
+	Orgs     StringList
+	AllOrgs  bool
+	OrgsOnly bool
+
+	// Include, Exclude, Visibility, Archived, Topics, Languages and PushedWithin select the forks to sync.
+	Include      pattern.List
+	Exclude      pattern.List
+	Visibility   string
+	Archived     string
+	Topics       pattern.List
+	Languages    pattern.List
+	PushedWithin Age
+
+	// SkipRepos and RepoNotify hold the per-repository skip and notify settings of the config
+	// file, keyed by lower-cased owner/name.
+	SkipRepos  map[string]bool
+	RepoNotify map[string][]string
+}
+
+// RepoPatterns maps a lower-cased owner/name to a pattern list, it implements flag.Value for
+// repeatable "owner/name=pattern,pattern" flags.
+type RepoPatterns map[string]pattern.List
+
+// Set parses a single "owner/name=pattern,pattern" entry.
+func (rp RepoPatterns) Set(value string) error {
+	repo, pats, ok := strings.Cut(value, "=")
+	if !ok || !strings.Contains(repo, "/") {
+		return fmt.Errorf("expected owner/name=pattern[,pattern], got %q", value)
+	}
+
+	list, err := pattern.Parse(pats)
+	if err != nil {
+		return fmt.Errorf("pattern.Parse error: %w", err)
+	}
+
+	key := strings.ToLower(strings.TrimSpace(repo))
+	rp[key] = append(rp[key], list...)
+
+	return nil
+}
+
+// RepoValues maps a lower-cased owner/name to a single value, it implements flag.Value for
+// repeatable "owner/name=value" flags.
+type RepoValues map[string]string
+
+// Set parses a single "owner/name=value" entry.
+func (rv RepoValues) Set(value string) error {
+	repo, val, ok := strings.Cut(value, "=")
+	if !ok || !strings.Contains(repo, "/") || len(val) == 0 {
+		return fmt.Errorf("expected owner/name=value, got %q", value)
+	}
+
+	rv[strings.ToLower(strings.TrimSpace(repo))] = strings.TrimSpace(val)
+
+	return nil
+}
+
+func (rv RepoValues) String() string {
+	entries := make([]string, 0, len(rv))
+	for repo, val := range rv {
+		entries = append(entries, repo+"="+val)
+	}
+
+	sort.Strings(entries)
+
+	return strings.Join(entries, " ")
+}
+
+// StringList is a repeatable flag of comma separated values.
+type StringList []string
+
+// Set appends the comma separated values, ignoring empty entries.
+func (list *StringList) Set(value string) error {
+	for _, val := range strings.Split(value, ",") {
+		if val = strings.TrimSpace(val); len(val) > 0 {
+			*list = append(*list, val)
+		}
+	}
+
+	return nil
+}
+
+func (list *StringList) String() string {
+	if list == nil {
+		return ""
+	}
+
+	return strings.Join(*list, ",")
+}
+
+// Age is a duration flag that also accepts a whole number of days, e.g. "30d".
+type Age time.Duration
+
+// Set parses a Go duration or a number of days with a "d" suffix.
+func (age *Age) Set(value string) error {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		num, err := strconv.Atoi(days)
+		if err != nil || num < 0 {
+			return fmt.Errorf("expected a number of days, got %q", value)
+		}
+
+		*age = Age(time.Duration(num) * 24 * time.Hour)
+
+		return nil
+	}
+
+	dur, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("time.ParseDuration error: %w", err)
+	}
+
+	*age = Age(dur)
+
+	return nil
+}
+
+func (age *Age) String() string {
+	if age == nil || *age == 0 {
+		return ""
+	}
+
+	return time.Duration(*age).String()
+}
+
+func (rp RepoPatterns) String() string {
+	entries := make([]string, 0, len(rp))
+	for repo, list := range rp {
+		entries = append(entries, repo+"="+list.String())
+	}
+
+	sort.Strings(entries)
+
+	return strings.Join(entries, " ")
 }
 
 func NewEnvironment() (*Environment, error) {
@@ -17,7 +280,7 @@ func NewEnvironment() (*Environment, error) {
 }
 
 // GetParameters returns the command line parameters with basic go flags.
-func (env *Environment) GetParameters() (*string, *bool, *bool, error) {
+func (env *Environment) GetParameters() (*Parameters, error) {
 	app := ""
 	if len(os.Args) > 0 {
 		app = os.Args[0]
@@ -28,29 +291,452 @@ func (env *Environment) GetParameters() (*string, *bool, *bool, error) {
 		args = os.Args[1:]
 	}
 
+	params := Parameters{
+		Command:        CommandSync,
+		RepoBranches:   RepoPatterns{},
+		RepoStrategies: RepoValues{},
+		SkipRepos:      map[string]bool{},
+		RepoNotify:     map[string][]string{},
+	}
+
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		params.Command = args[0]
+		args = args[1:]
+	}
+
 	flagSet := flag.NewFlagSet(app, flag.ContinueOnError)
 
 	flagSet.SetOutput(os.Stderr)
 
-	var auth string
-	var dbg bool
-	var verbose bool
-
 	// add flags
-	flagSet.StringVar(&auth, "auth", "", "GitHub Auth Token")
-	flagSet.BoolVar(&dbg, "debug", false, "Log Debug")
-	flagSet.BoolVar(&verbose, "verbose", false, "Show Verbose Logging")
+	flagSet.StringVar(&params.ConfigFile, configFlag, "", "YAML file with flag defaults and per-repository settings")
+	flagSet.Var(&params.Auth, authFlag, "GitHub Auth Token, prefer -auth-file, -auth-stdin or GITHUB_TOKEN")
+	flagSet.StringVar(&params.AuthFile, "auth-file", "",
+		"Read the GitHub Auth Token from this file, it must not be world-readable")
+	flagSet.BoolVar(&params.AuthStdin, "auth-stdin", false, "Read the GitHub Auth Token from the first line of stdin")
+	flagSet.StringVar(&params.TokensFile, "tokens-file", "",
+		"Rotate between the GitHub Auth Tokens of this file, one [owner,...=]token per line, it must not be world-readable")
+	flagSet.Int64Var(&params.AppID, "app-id", 0, "Authenticate as this GitHub App instead of with a token")
+	flagSet.StringVar(&params.AppKeyFile, "app-key-file", "",
+		"PEM private key of the GitHub App, it must not be world-readable")
+	flagSet.Int64Var(&params.AppInstallationID, "app-installation-id", 0, "Installation of the GitHub App to use")
+	flagSet.StringVar(&params.AppOrg, "app-org", "",
+		"Organization whose GitHub App installation is used when -app-installation-id is not given")
+	flagSet.StringVar(&params.BaseURL, "base-url", "",
+		"GitHub Enterprise Server API URL, e.g. https://github.example.com/api/v3/ (default github.com)")
+	flagSet.StringVar(&params.UploadURL, "upload-url", "",
+		"GitHub Enterprise Server upload URL (default -base-url)")
+	flagSet.BoolVar(&params.Debug, "debug", false, "Log Debug")
+	flagSet.BoolVar(&params.Verbose, "verbose", false, "Show Verbose Logging")
+	flagSet.IntVar(&params.Concurrency, "concurrency", DefaultConcurrency,
+		"Number of forks to merge upstream in parallel")
+	flagSet.DurationVar(&params.MaxRateWait, "max-rate-wait", githubapi.DefaultMaxRateLimitWait,
+		"Longest pause for a rate limit reset before failing, 0 fails at once")
+	flagSet.IntVar(&params.MaxRetries, "max-retries", githubapi.DefaultMaxRetries,
+		"Retries of a request that failed on a server error, dropped connection or timeout, 0 never retries")
+	flagSet.BoolVar(&params.ContinueOnError, "continue-on-error", false,
+		"Keep syncing after a fork fails and report every failure at the end")
+	flagSet.BoolVar(&params.DryRun, "dry-run", false,
+		"Report what would be synced using the compare API without merging")
+	flagSet.StringVar(&params.PlanOut, "plan-out", "",
+		"Write the dry-run plan to this JSON file for a later 'apply' (implies -dry-run)")
+	flagSet.Var(&params.Branches, "branches",
+		"Comma separated globs (or re:<regexp>) of extra branches to sync in every fork (repeatable)")
+	flagSet.Var(params.RepoBranches, "repo-branches",
+		"owner/name=pattern[,pattern] branch selector for a single fork, replaces -branches (repeatable)")
+	flagSet.Var(&params.CreateBranches, "create-branches",
+		"Comma separated globs (or re:<regexp>) of upstream branches to create in forks that lack them (repeatable)")
+	flagSet.BoolVar(&params.ConflictPR, "conflict-pr", false,
+		"Open or update a sync/upstream-<date> pull request when merge-upstream reports a conflict")
+	flagSet.StringVar(&params.Strategy, "strategy", githubapi.StrategyMerge,
+		"How forks are synced: 'merge' uses merge-upstream, 'reset' force-aligns with upstream after a backup")
+	flagSet.StringVar(&params.Discovery, "discovery", githubapi.DiscoveryREST,
+		"How forks are listed: 'rest' pages through every repository, 'graphql' fetches only forks and skips level ones")
+	flagSet.Var(params.RepoStrategies, "repo-strategy",
+		"owner/name=strategy override for a single fork, replaces -strategy (repeatable)")
+	flagSet.BoolVar(&params.SyncTags, "sync-tags", false, "Mirror upstream tags into the forks as lightweight tags")
+	flagSet.Var(&params.Tags, "tags", "Comma separated globs (or re:<regexp>) of tags to mirror (repeatable)")
+	flagSet.StringVar(&params.TagRange, "tag-range", "", "Only mirror tags within this semver range, e.g. '>= 1.2, < 2'")
+	flagSet.BoolVar(&params.PruneTags, "prune-tags", false, "Delete selected fork tags that no longer exist upstream")
+	flagSet.StringVar(&params.User, "user", "",
+		"Sync the public forks of this user instead of the authenticated one")
+	flagSet.Var(&params.Repos, "repos",
+		"Comma separated owner/name forks to sync without listing any account (repeatable)")
+	flagSet.StringVar(&params.ReposFile, "repos-file", "",
+		"File with one owner/name fork to sync per line, blank lines and # comments are ignored")
+	flagSet.Var(&params.Orgs, "org",
+		"Comma separated organizations whose forks, writable with the token, are synced as well (repeatable)")
+	flagSet.BoolVar(&params.AllOrgs, "all-orgs", false,
+		"Sync the writable forks of every organization the user belongs to")
+	flagSet.Var(&params.Include, "include",
+		"Comma separated globs (or re:<regexp>) of owner/name forks to sync, all when unset (repeatable)")
+	flagSet.Var(&params.Exclude, "exclude",
+		"Comma separated globs (or re:<regexp>) of owner/name forks to skip, wins over -include (repeatable)")
+	flagSet.StringVar(&params.Visibility, "visibility", "",
+		"Comma separated visibilities (public, private, internal) of the forks to sync")
+	flagSet.StringVar(&params.Archived, "archived", githubapi.ArchivedInclude,
+		"Archived forks: 'include' them, 'exclude' them or sync 'only' them")
+	flagSet.Var(&params.Topics, "topics",
+		"Comma separated globs (or re:<regexp>), sync only forks with a matching topic (repeatable)")
+	flagSet.Var(&params.Languages, "language",
+		"Comma separated globs (or re:<regexp>), sync only forks with a matching primary language (repeatable)")
+	flagSet.Var(&params.PushedWithin, "pushed-within",
+		"Skip forks not pushed to within this age, e.g. '720h' or '30d'")
+	flagSet.StringVar(&params.HistoryDir, "history-dir", "",
+		"Directory runs are recorded in for 'rollback' (default <user config dir>/github-fork-update/history)")
+	flagSet.BoolVar(&params.NoHistory, "no-history", false,
+		"Do not record the pre-sync branch heads, the run cannot be rolled back")
+	flagSet.StringVar(&params.RunID, "run", "", "Id of the recorded run to roll back, omit to list the recorded runs")
+	flagSet.BoolVar(&params.Force, "force", false,
+		"Roll back branches that moved on since the run, discarding the commits pushed after it")
+	flagSet.StringVar(&params.CacheDir, "cache-dir", "",
+		"Directory list and compare responses are cached in (default <user cache dir>/github-fork-update/http)")
+	flagSet.IntVar(&params.CacheMaxMB, "cache-max-mb", httpcache.DefaultMaxBytes>>20,
+		"Size in megabytes the response cache is trimmed to, least recently used first")
+	flagSet.BoolVar(&params.NoCache, "no-cache", false,
+		"Do not cache responses, every request counts against the rate limit")
 
 	// Parse the flags
 	if err := flagSet.Parse(args); err != nil {
-		return nil, nil, nil, fmt.Errorf("error parsing flags: %w", err)
+		return nil, fmt.Errorf("error parsing flags: %w", err)
+	}
+
+	params.Args = flagSet.Args()
+
+	// flags given on the command line win over environment variables, which win over the config file
+	set := map[string]bool{}
+	flagSet.Visit(func(flg *flag.Flag) {
+		set[flg.Name] = true
+	})
+
+	if err := applyEnv(flagSet, set); err != nil {
+		return nil, err
+	}
+
+	if len(params.ConfigFile) > 0 {
+		if cerr := params.loadConfig(flagSet, set); cerr != nil {
+			if params.Command != CommandDoctor {
+				return nil, cerr
+			}
+
+			params.ConfigErr = cerr
+		}
+	}
+
+	if err := params.validateCommand(); err != nil {
+		return nil, err
+	}
+
+	if len(params.PlanOut) > 0 {
+		params.DryRun = true
+	}
+
+	if err := params.validateStrategies(); err != nil {
+		return nil, err
+	}
+
+	if err := params.validateFilter(); err != nil {
+		return nil, err
+	}
+
+	if err := params.validateRepos(); err != nil {
+		return nil, err
+	}
+
+	if err := params.validateURLs(); err != nil {
+		return nil, err
+	}
+
+	if len(params.TagRange) > 0 {
+		constraints, err := semver.NewConstraint(params.TagRange)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -tag-range %q: %w", params.TagRange, err)
+		}
+
+		params.TagConstraints = constraints
+	}
+
+	stdin := env.Stdin
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+
+	helpers := env.TokenHelpers
+	if helpers == nil {
+		helpers = DefaultTokenHelpers
+	}
+
+	if err := params.resolveAuth(stdin, helpers); err != nil {
+		if params.Command != CommandDoctor {
+			return nil, err
+		}
+
+		params.AuthErr = err
+	}
+
+	if params.Concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be at least 1, got %d", params.Concurrency)
+	}
+
+	if params.MaxRateWait < 0 {
+		return nil, fmt.Errorf("-max-rate-wait must not be negative, got %s", params.MaxRateWait)
+	}
+
+	if params.MaxRetries < 0 {
+		return nil, fmt.Errorf("-max-retries must not be negative, got %d", params.MaxRetries)
+	}
+
+	if !githubapi.ValidDiscovery(params.Discovery) {
+		return nil, fmt.Errorf("unknown discovery %q, expected %s or %s", params.Discovery, githubapi.DiscoveryREST,
+			githubapi.DiscoveryGraphQL)
+	}
+
+	if params.CacheMaxMB < 1 {
+		return nil, fmt.Errorf("-cache-max-mb must be at least 1, got %d", params.CacheMaxMB)
+	}
+
+	return &params, nil
+}
+
+// loadConfig reads ConfigFile and applies it to the flags not in set.
+func (params *Parameters) loadConfig(flagSet *flag.FlagSet, set map[string]bool) error {
+	cfg, err := config.Load(params.ConfigFile)
+	if err != nil {
+		return fmt.Errorf("config.Load error: %w", err)
+	}
+
+	return params.applyConfig(flagSet, set, cfg)
+}
+
+// EnvName returns the environment variable that sets the named flag.
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnv sets every flag not in set from its environment variable, if present.
+func applyEnv(flagSet *flag.FlagSet, set map[string]bool) error {
+	var err error
+
+	flagSet.VisitAll(func(flg *flag.Flag) {
+		value, ok := os.LookupEnv(EnvName(flg.Name))
+		if err != nil || set[flg.Name] || !ok {
+			return
+		}
+
+		if serr := flagSet.Set(flg.Name, value); serr != nil {
+			err = fmt.Errorf("invalid %s: %w", EnvName(flg.Name), serr)
+		}
+
+		set[flg.Name] = true
+	})
+
+	return err
+}
+
+// applyConfig sets every flag not in set from the config defaults and adds the per-repository
+// settings of repositories not already given on the command line or in the environment.
+func (params *Parameters) applyConfig(flagSet *flag.FlagSet, set map[string]bool, cfg *config.Config) error {
+	for _, setting := range cfg.Defaults {
+		if setting.Name == configFlag || flagSet.Lookup(setting.Name) == nil {
+			return cfg.Errorf(setting.Line, "unknown flag %q", setting.Name)
+		}
+
+		// the config file is not checked for being private like the token files are
+		if setting.Name == authFlag {
+			return cfg.Errorf(setting.Line, "the token cannot be given in the config file, use -auth-file or %s",
+				GitHubTokenEnv)
+		}
+
+		if set[setting.Name] {
+			continue
+		}
+
+		for _, value := range setting.Values {
+			if err := flagSet.Set(setting.Name, value); err != nil {
+				return cfg.Errorf(setting.Line, "invalid %s: %w", setting.Name, err)
+			}
+		}
+	}
+
+	for name, repo := range cfg.Repos {
+		if repo.Skip {
+			params.SkipRepos[name] = true
+		}
+
+		if len(repo.Notify) > 0 {
+			params.RepoNotify[name] = repo.Notify
+		}
+
+		if _, ok := params.RepoBranches[name]; !ok && len(repo.Branches) > 0 {
+			list := make(pattern.List, 0, len(repo.Branches))
+
+			for _, branch := range repo.Branches {
+				pat, err := pattern.Compile(branch)
+				if err != nil {
+					return cfg.Errorf(repo.Lines["branches"], "invalid branches for %s: %w", repo.Name, err)
+				}
+
+				list = append(list, pat)
+			}
+
+			params.RepoBranches[name] = list
+		}
+
+		if _, ok := params.RepoStrategies[name]; !ok && len(repo.Strategy) > 0 {
+			if !githubapi.ValidStrategy(repo.Strategy) {
+				return cfg.Errorf(repo.Lines["strategy"], "unknown strategy %q for %s", repo.Strategy, repo.Name)
+			}
+
+			params.RepoStrategies[name] = repo.Strategy
+		}
+	}
+
+	return nil
+}
+
+func (params *Parameters) validateCommand() error {
+	if params.Force && params.Command != CommandRollback {
+		return fmt.Errorf("-force can only be used with %s", CommandRollback)
+	}
+
+	switch params.Command {
+	case CommandSync, CommandDoctor:
+		if len(params.Args) > 0 {
+			return fmt.Errorf("unexpected arguments: %s", strings.Join(params.Args, " "))
+		}
+	case CommandApply:
+		if len(params.Args) != 1 {
+			return fmt.Errorf("usage: %s [flags] <plan.json>", CommandApply)
+		}
+	case CommandRollback:
+		if len(params.Args) > 0 {
+			return fmt.Errorf("usage: %s [flags] -run <id>", CommandRollback)
+		}
+
+		if params.NoHistory {
+			return fmt.Errorf("-no-history cannot be used with %s", CommandRollback)
+		}
+	default:
+		return fmt.Errorf("unknown command %q", params.Command)
+	}
+
+	return nil
+}
+
+func (params *Parameters) validateStrategies() error {
+	if !githubapi.ValidStrategy(params.Strategy) {
+		return fmt.Errorf("unknown strategy %q", params.Strategy)
+	}
+
+	for repo, strategy := range params.RepoStrategies {
+		if !githubapi.ValidStrategy(strategy) {
+			return fmt.Errorf("unknown strategy %q for %s", strategy, repo)
+		}
+	}
+
+	return nil
+}
+
+func (params *Parameters) validateURLs() error {
+	if len(params.UploadURL) > 0 && len(params.BaseURL) == 0 {
+		return fmt.Errorf("-upload-url requires -base-url")
+	}
+
+	for name, value := range map[string]string{"-base-url": params.BaseURL, "-upload-url": params.UploadURL} {
+		if len(value) == 0 {
+			continue
+		}
+
+		parsed, err := url.Parse(value)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || len(parsed.Host) == 0 {
+			return fmt.Errorf("invalid %s %q, expected an http(s) URL", name, value)
+		}
+	}
+
+	return nil
+}
+
+// validateRepos appends the forks listed in ReposFile to Repos and checks every entry is owner/name. The
+// listed forks replace the listing of any account, so -user, -org and -all-orgs cannot be given with them.
+func (params *Parameters) validateRepos() error {
+	if len(params.ReposFile) > 0 {
+		repos, err := readReposFile(params.ReposFile)
+		if err != nil {
+			return err
+		}
+
+		params.Repos = append(params.Repos, repos...)
+	}
+
+	if len(params.Repos) > 0 && (len(params.User) > 0 || len(params.Orgs) > 0 || params.AllOrgs) {
+		return fmt.Errorf("-repos and -repos-file cannot be combined with -user, -org or -all-orgs")
 	}
 
-	if len(auth) == 0 {
-		return nil, nil, nil, fmt.Errorf("empty auth token error")
+	for _, repo := range params.Repos {
+		if !validRepoName(repo) {
+			return fmt.Errorf("expected owner/name in -repos, got %q", repo)
+		}
+	}
+
+	return nil
+}
+
+// readReposFile returns the owner/name entries of the file, one per line.
+func readReposFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile error: %w", err)
+	}
+
+	repos := make([]string, 0)
+
+	for num, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		if line = strings.TrimSpace(line); len(line) == 0 {
+			continue
+		}
+
+		if !validRepoName(line) {
+			return nil, fmt.Errorf("%s:%d: expected owner/name, got %q", path, num+1, line)
+		}
+
+		repos = append(repos, line)
+	}
+
+	return repos, nil
+}
+
+func validRepoName(repo string) bool {
+	owner, name, ok := strings.Cut(repo, "/")
+
+	return ok && len(owner) > 0 && len(name) > 0 && !strings.Contains(name, "/")
+}
+
+func (params *Parameters) validateFilter() error {
+	if !githubapi.ValidArchived(params.Archived) {
+		return fmt.Errorf("unknown -archived value %q", params.Archived)
+	}
+
+	for _, visibility := range params.Visibilities() {
+		if !githubapi.ValidVisibility(visibility) {
+			return fmt.Errorf("unknown visibility %q", visibility)
+		}
+	}
+
+	return nil
+}
+
+// Visibilities splits the comma separated Visibility parameter.
+func (params *Parameters) Visibilities() []string {
+	visibilities := make([]string, 0)
+
+	for _, visibility := range strings.Split(params.Visibility, ",") {
+		if visibility = strings.ToLower(strings.TrimSpace(visibility)); len(visibility) > 0 {
+			visibilities = append(visibilities, visibility)
+		}
 	}
 
-	return &auth, &dbg, &verbose, nil
+	return visibilities
 }
 
 func (env *Environment) Report(verbose bool, dbg bool) string {
@@ -2,8 +2,11 @@ package environment_test
 
 import (
 	"os"
+	"path/filepath"
 	"runtime/debug"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mjdusa/github-fork-update/internal/environment"
 	"github.com/mjdusa/github-fork-update/internal/version"
@@ -20,6 +23,16 @@ func Test_EnvSuite(t *testing.T) {
 	suite.Run(t, &EnvSuite{})
 }
 
+// SetupTest keeps tokens from the developer's environment, gh CLI and git credential helpers out of
+// the tests.
+func (s *EnvSuite) SetupTest() {
+	s.T().Setenv(environment.GitHubTokenEnv, "")
+	s.T().Setenv(environment.GHTokenEnv, "")
+	s.T().Setenv("GH_CONFIG_DIR", s.T().TempDir())
+	s.T().Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	s.T().Setenv("GIT_CONFIG_NOSYSTEM", "1")
+}
+
 type TestGetParameters struct {
 	Description     string
 	AuthFlag        *string
@@ -81,6 +94,83 @@ func (s *EnvSuite) TestGetParameters2() {
 			wantErr:  false,
 			wantVerb: true,
 		},
+		{
+			name:     "Test with concurrency argument",
+			args:     []string{"-auth", "test_token", "-concurrency", "8"},
+			wantDbg:  false,
+			wantErr:  false,
+			wantVerb: false,
+		},
+		{
+			name:     "Test with zero concurrency argument",
+			args:     []string{"-auth", "test_token", "-concurrency", "0"},
+			wantDbg:  false,
+			wantErr:  true,
+			wantVerb: false,
+		},
+		{
+			name:     "Test with zero max-retries argument",
+			args:     []string{"-auth", "test_token", "-max-retries", "0"},
+			wantDbg:  false,
+			wantErr:  false,
+			wantVerb: false,
+		},
+		{
+			name:     "Test with graphql discovery argument",
+			args:     []string{"-auth", "test_token", "-discovery", "graphql"},
+			wantDbg:  false,
+			wantErr:  false,
+			wantVerb: false,
+		},
+		{
+			name:     "Test with unknown discovery argument",
+			args:     []string{"-auth", "test_token", "-discovery", "soap"},
+			wantDbg:  false,
+			wantErr:  true,
+			wantVerb: false,
+		},
+		{
+			name:     "Test with zero cache-max-mb argument",
+			args:     []string{"-auth", "test_token", "-cache-max-mb", "0"},
+			wantDbg:  false,
+			wantErr:  true,
+			wantVerb: false,
+		},
+		{
+			name:     "Test with negative max-retries argument",
+			args:     []string{"-auth", "test_token", "-max-retries", "-1"},
+			wantDbg:  false,
+			wantErr:  true,
+			wantVerb: false,
+		},
+		{
+			name:     "Test with force outside rollback",
+			args:     []string{"-auth", "test_token", "-force"},
+			wantDbg:  false,
+			wantErr:  true,
+			wantVerb: false,
+		},
+		{
+			name:     "Test with apply command",
+			args:     []string{"apply", "-auth", "test_token", "plan.json"},
+			wantDbg:  false,
+			wantErr:  false,
+			wantVerb: false,
+		},
+		{
+			name:     "Test with apply command missing plan file",
+			args:     []string{"apply", "-auth", "test_token"},
+			wantDbg:  false,
+			wantErr:  true,
+			wantVerb: false,
+		},
+		{
+			name:     "Test with unknown command",
+			args:     []string{"destroy", "-auth", "test_token"},
+			wantDbg:  false,
+			wantErr:  true,
+			wantVerb: false,
+		},
 		{
 			name:     "Test with invalid argument",
 			args:     []string{"-invalid", "value"},
@@ -100,7 +190,7 @@ func (s *EnvSuite) TestGetParameters2() {
 			// Set the command line arguments
 			os.Args = append([]string{"app"}, tst.args...)
 
-			_, gotDbg, gotVerb, err := env.GetParameters()
+			got, err := env.GetParameters()
 
 			if err != nil {
 				if !tst.wantErr {
@@ -112,9 +202,9 @@ func (s *EnvSuite) TestGetParameters2() {
 					t.Errorf("GetParameters() test %s returned no error, wantErr %v",
 						tst.name, tst.wantErr)
 				}
-				assert.Equal(t, tst.wantDbg, *gotDbg, "GetParameters() Debug test '%s'",
+				assert.Equal(t, tst.wantDbg, got.Debug, "GetParameters() Debug test '%s'",
 					tst.name)
-				assert.Equal(t, tst.wantVerb, *gotVerb, "GetParameters() Verbose test '%s'",
+				assert.Equal(t, tst.wantVerb, got.Verbose, "GetParameters() Verbose test '%s'",
 					tst.name)
 			}
 		})
@@ -174,3 +264,377 @@ func (s *EnvSuite) TestReport() {
 		})
 	}
 }
+
+func (s *EnvSuite) TestGetParametersCommand() {
+	env, err := environment.NewEnvironment()
+	if err != nil {
+		s.T().Errorf("NewEnvironment() error = %v", err)
+	}
+
+	os.Args = []string{"app", "-auth", "test_token", "-plan-out", "plan.json"}
+
+	params, err := env.GetParameters()
+	if s.NoError(err) {
+		s.Equal(environment.CommandSync, params.Command)
+		s.True(params.DryRun)
+		s.Equal("plan.json", params.PlanOut)
+	}
+
+	os.Args = []string{"app", "apply", "-auth", "test_token", "plan.json"}
+
+	params, err = env.GetParameters()
+	if s.NoError(err) {
+		s.Equal(environment.CommandApply, params.Command)
+		s.Equal([]string{"plan.json"}, params.Args)
+	}
+
+	os.Args = []string{"app", "rollback", "-auth", "test_token", "-run", "20261018T093000Z", "-dry-run"}
+
+	params, err = env.GetParameters()
+	if s.NoError(err) {
+		s.Equal(environment.CommandRollback, params.Command)
+		s.Equal("20261018T093000Z", params.RunID)
+		s.True(params.DryRun)
+	}
+
+	os.Args = []string{"app", "rollback", "-auth", "test_token", "-no-history"}
+
+	_, err = env.GetParameters()
+	s.Error(err)
+}
+
+func (s *EnvSuite) TestGetParametersBranches() {
+	env, err := environment.NewEnvironment()
+	if err != nil {
+		s.T().Errorf("NewEnvironment() error = %v", err)
+	}
+
+	os.Args = []string{"app", "-auth", "test_token", "-branches", "release-*", "-branches", "re:^v[0-9]+$",
+		"-repo-branches", "Owner/Repo=dev,stable"}
+
+	params, err := env.GetParameters()
+	if s.NoError(err) {
+		s.Equal("release-*,re:^v[0-9]+$", params.Branches.String())
+		s.Equal("owner/repo=dev,stable", params.RepoBranches.String())
+		s.True(params.RepoBranches["owner/repo"].Match("stable"))
+	}
+
+	for _, args := range [][]string{
+		{"app", "-auth", "test_token", "-branches", "re:("},
+		{"app", "-auth", "test_token", "-repo-branches", "dev"},
+		{"app", "-auth", "test_token", "-repo-branches", "owner/repo=["},
+	} {
+		os.Args = args
+
+		_, err = env.GetParameters()
+		s.Error(err, "GetParameters(%v)", args)
+	}
+}
+
+func (s *EnvSuite) TestGetParametersTags() {
+	env, err := environment.NewEnvironment()
+	if err != nil {
+		s.T().Errorf("NewEnvironment() error = %v", err)
+	}
+
+	os.Args = []string{"app", "-auth", "test_token", "-sync-tags", "-tags", "v*", "-tag-range", ">= 1.2, < 2",
+		"-prune-tags"}
+
+	params, err := env.GetParameters()
+	if s.NoError(err) {
+		s.True(params.SyncTags)
+		s.True(params.PruneTags)
+		s.Equal("v*", params.Tags.String())
+		s.NotNil(params.TagConstraints)
+	}
+
+	os.Args = []string{"app", "-auth", "test_token", "-tag-range", "not a range"}
+
+	_, err = env.GetParameters()
+	s.Error(err)
+}
+
+func (s *EnvSuite) TestGetParametersStrategy() {
+	env, err := environment.NewEnvironment()
+	if err != nil {
+		s.T().Errorf("NewEnvironment() error = %v", err)
+	}
+
+	os.Args = []string{"app", "-auth", "test_token", "-repo-strategy", "Owner/Mirror=reset"}
+
+	params, err := env.GetParameters()
+	if s.NoError(err) {
+		s.Equal("merge", params.Strategy)
+		s.Equal("owner/mirror=reset", params.RepoStrategies.String())
+	}
+
+	for _, args := range [][]string{
+		{"app", "-auth", "test_token", "-strategy", "rebase"},
+		{"app", "-auth", "test_token", "-repo-strategy", "owner/mirror=rebase"},
+		{"app", "-auth", "test_token", "-repo-strategy", "reset"},
+	} {
+		os.Args = args
+
+		_, err = env.GetParameters()
+		s.Error(err, "GetParameters(%v)", args)
+	}
+}
+
+func writeConfig(s *EnvSuite, data string) string {
+	path := filepath.Join(s.T().TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		s.T().Fatalf("os.WriteFile returned error: %v", err)
+	}
+
+	return path
+}
+
+func (s *EnvSuite) TestGetParametersConfig() {
+	env, err := environment.NewEnvironment()
+	if err != nil {
+		s.T().Errorf("NewEnvironment() error = %v", err)
+	}
+
+	path := writeConfig(s, `defaults:
+  concurrency: 4
+  strategy: reset
+  branches: [release-*, dev]
+repos:
+  owner/frozen:
+    skip: true
+  owner/app:
+    branches: [stable]
+    strategy: merge
+    notify: [team@example.com]
+  owner/lib:
+    strategy: merge
+`)
+
+	s.T().Setenv(environment.EnvName("concurrency"), "6")
+
+	os.Args = []string{"app", "-auth", "test_token", "-config", path, "-strategy", "merge", "-repo-strategy",
+		"owner/lib=reset"}
+
+	params, err := env.GetParameters()
+	if s.NoError(err) {
+		s.Equal(6, params.Concurrency, "environment wins over config")
+		s.Equal("merge", params.Strategy, "flag wins over config")
+		s.Equal("release-*,dev", params.Branches.String())
+		s.True(params.SkipRepos["owner/frozen"])
+		s.True(params.RepoBranches["owner/app"].Match("stable"))
+		s.False(params.RepoBranches["owner/app"].Match("dev"))
+		s.Equal("owner/app=merge owner/lib=reset", params.RepoStrategies.String())
+		s.Equal([]string{"team@example.com"}, params.RepoNotify["owner/app"])
+	}
+}
+
+func (s *EnvSuite) TestGetParametersEnv() {
+	env, err := environment.NewEnvironment()
+	if err != nil {
+		s.T().Errorf("NewEnvironment() error = %v", err)
+	}
+
+	s.Equal("GITHUB_FORK_UPDATE_CONTINUE_ON_ERROR", environment.EnvName("continue-on-error"))
+
+	s.T().Setenv(environment.EnvName("auth"), "env_token")
+	s.T().Setenv(environment.EnvName("continue-on-error"), "true")
+
+	os.Args = []string{"app"}
+
+	params, err := env.GetParameters()
+	if s.NoError(err) {
+		s.Equal("env_token", params.Auth.Value())
+		s.True(params.ContinueOnError)
+	}
+
+	s.T().Setenv(environment.EnvName("concurrency"), "many")
+
+	_, err = env.GetParameters()
+	s.ErrorContains(err, "invalid GITHUB_FORK_UPDATE_CONCURRENCY")
+}
+
+func (s *EnvSuite) TestGetParametersConfigErrors() {
+	env, err := environment.NewEnvironment()
+	if err != nil {
+		s.T().Errorf("NewEnvironment() error = %v", err)
+	}
+
+	tests := []struct {
+		data string
+		want string
+	}{
+		{data: "defaults:\n  verbose: true\n  concurency: 4\n", want: `config.yml:3: unknown flag "concurency"`},
+		{data: "defaults:\n  verbose: true\n  concurrency: many\n", want: "config.yml:3: invalid concurrency"},
+		{data: "defaults:\n  verbose: true\n  auth: t\n", want: "config.yml:3: the token cannot be given in the config file"},
+		{data: "defaults:\n  config: other.yml\n", want: `config.yml:2: unknown flag "config"`},
+		{data: "repos:\n  o/a:\n    strategy: rebase\n", want: `config.yml:3: unknown strategy "rebase"`},
+		{data: "repos:\n  o/a:\n    skip: false\n    branches: [\"re:(\"]\n", want: "config.yml:4: invalid branches"},
+	}
+
+	for _, tst := range tests {
+		os.Args = []string{"app", "-auth", "test_token", "-config", writeConfig(s, tst.data)}
+
+		_, err = env.GetParameters()
+		s.ErrorContains(err, tst.want)
+	}
+}
+
+func (s *EnvSuite) TestGetParametersFilter() {
+	env, err := environment.NewEnvironment()
+	if err != nil {
+		s.T().Errorf("NewEnvironment() error = %v", err)
+	}
+
+	os.Args = []string{"app", "-auth", "test_token", "-include", "octocat/*", "-exclude", "*/experiment-*",
+		"-visibility", "Public, private", "-archived", "exclude", "-topics", "go", "-language", "Go",
+		"-pushed-within", "30d"}
+
+	params, err := env.GetParameters()
+	if s.NoError(err) {
+		s.Equal("octocat/*", params.Include.String())
+		s.Equal("*/experiment-*", params.Exclude.String())
+		s.Equal([]string{"public", "private"}, params.Visibilities())
+		s.Equal("exclude", params.Archived)
+		s.Equal(environment.Age(720*time.Hour), params.PushedWithin)
+		s.Equal("720h0m0s", params.PushedWithin.String())
+	}
+
+	os.Args = []string{"app", "-auth", "test_token", "-pushed-within", "36h"}
+
+	params, err = env.GetParameters()
+	if s.NoError(err) {
+		s.Equal(environment.Age(36*time.Hour), params.PushedWithin)
+	}
+
+	for _, args := range [][]string{
+		{"app", "-auth", "test_token", "-visibility", "secret"},
+		{"app", "-auth", "test_token", "-archived", "maybe"},
+		{"app", "-auth", "test_token", "-pushed-within", "xd"},
+		{"app", "-auth", "test_token", "-pushed-within", "soon"},
+	} {
+		os.Args = args
+
+		_, err = env.GetParameters()
+		s.Error(err, "GetParameters(%v)", args)
+	}
+}
+
+func (s *EnvSuite) TestGetParametersOrgs() {
+	env, err := environment.NewEnvironment()
+	if err != nil {
+		s.T().Errorf("NewEnvironment() error = %v", err)
+	}
+
+	os.Args = []string{"app", "-auth", "test_token", "-org", "acme, widgets", "-org", "tools", "-all-orgs"}
+
+	params, err := env.GetParameters()
+	if s.NoError(err) {
+		s.Equal(environment.StringList{"acme", "widgets", "tools"}, params.Orgs)
+		s.Equal("acme,widgets,tools", params.Orgs.String())
+		s.True(params.AllOrgs)
+	}
+}
+
+func (s *EnvSuite) TestGetParametersRepos() {
+	env, err := environment.NewEnvironment()
+	if err != nil {
+		s.T().Errorf("NewEnvironment() error = %v", err)
+	}
+
+	path := filepath.Join(s.T().TempDir(), "repos.txt")
+	if werr := os.WriteFile(path, []byte("# CI forks\nowner/c\n\n  owner/d  # pinned\n"), 0o600); werr != nil {
+		s.T().Fatalf("os.WriteFile returned error: %v", werr)
+	}
+
+	os.Args = []string{"app", "-auth", "test_token", "-repos", "owner/a,owner/b", "-repos-file", path}
+
+	params, err := env.GetParameters()
+	if s.NoError(err) {
+		s.Equal(environment.StringList{"owner/a", "owner/b", "owner/c", "owner/d"}, params.Repos)
+	}
+
+	bad := filepath.Join(s.T().TempDir(), "bad.txt")
+	if werr := os.WriteFile(bad, []byte("owner/a\nnot-a-repo\n"), 0o600); werr != nil {
+		s.T().Fatalf("os.WriteFile returned error: %v", werr)
+	}
+
+	os.Args = []string{"app", "-auth", "test_token", "-repos-file", bad}
+
+	_, err = env.GetParameters()
+	s.ErrorContains(err, "bad.txt:2: expected owner/name")
+
+	for _, args := range [][]string{
+		{"app", "-auth", "test_token", "-repos", "owner"},
+		{"app", "-auth", "test_token", "-repos", "owner/a/b"},
+		{"app", "-auth", "test_token", "-repos-file", filepath.Join(s.T().TempDir(), "missing.txt")},
+	} {
+		os.Args = args
+
+		_, err = env.GetParameters()
+		s.Error(err, "GetParameters(%v)", args)
+	}
+
+	for _, args := range [][]string{
+		{"app", "-auth", "test_token", "-user", "bot", "-repos", "owner/a"},
+		{"app", "-auth", "test_token", "-org", "acme", "-repos-file", path},
+		{"app", "-auth", "test_token", "-all-orgs", "-repos", "owner/a"},
+	} {
+		os.Args = args
+
+		_, err = env.GetParameters()
+		s.EqualError(err, "-repos and -repos-file cannot be combined with -user, -org or -all-orgs",
+			"GetParameters(%v)", args)
+	}
+}
+
+func (s *EnvSuite) TestGetParametersEnterprise() {
+	env, err := environment.NewEnvironment()
+	if err != nil {
+		s.T().Errorf("NewEnvironment() error = %v", err)
+	}
+
+	os.Args = []string{"app", "-auth", "test_token", "-base-url", "https://github.example.com/api/v3/",
+		"-upload-url", "https://github.example.com/api/uploads/"}
+
+	params, err := env.GetParameters()
+	if s.NoError(err) {
+		s.Equal("https://github.example.com/api/v3/", params.BaseURL)
+		s.Equal("https://github.example.com/api/uploads/", params.UploadURL)
+	}
+
+	for _, args := range [][]string{
+		{"app", "-auth", "test_token", "-base-url", "github.example.com"},
+		{"app", "-auth", "test_token", "-base-url", "ftp://github.example.com"},
+		{"app", "-auth", "test_token", "-upload-url", "https://github.example.com/api/uploads/"},
+	} {
+		os.Args = args
+
+		_, err = env.GetParameters()
+		s.Error(err, "GetParameters(%v)", args)
+	}
+}
+
+func (s *EnvSuite) TestGetParametersDoctor() {
+	env := environment.Environment{Stdin: strings.NewReader("")}
+	os.Args = []string{"app", "doctor", "-config", writeConfig(s, "defaults:\n  concurency: 4\n")}
+
+	params, err := env.GetParameters()
+	if s.NoError(err, "the doctor reports config and token errors instead of failing") {
+		s.Equal(environment.CommandDoctor, params.Command)
+		s.ErrorContains(params.ConfigErr, `config.yml:2: unknown flag "concurency"`)
+		s.ErrorContains(params.AuthErr, "empty auth token error")
+	}
+
+	os.Args = []string{"app", "doctor", "-auth", "test_token"}
+
+	params, err = env.GetParameters()
+	if s.NoError(err) {
+		s.NoError(params.ConfigErr)
+		s.NoError(params.AuthErr)
+	}
+
+	os.Args = []string{"app", "doctor", "-auth", "test_token", "extra"}
+
+	_, err = env.GetParameters()
+	s.ErrorContains(err, "unexpected arguments: extra")
+}
@@ -0,0 +1,168 @@
+package githubapi
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v53/github"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// AppJWTLifetime is how long the JWT minted for a GitHub App is valid, GitHub accepts at most 10 minutes.
+	AppJWTLifetime = 9 * time.Minute
+
+	// AppTokenRefresh is how long before it expires an installation token is replaced by a new one.
+	AppTokenRefresh = 5 * time.Minute
+
+	// appJWTSkew backdates the JWT issue time to allow for clock drift between us and GitHub.
+	appJWTSkew = time.Minute
+
+	appRequestTimeout = 30 * time.Second
+)
+
+// AppTokenSource supplies installation tokens of a GitHub App. The installation is looked up from Org
+// when InstallationID is zero.
+type AppTokenSource struct {
+	AppID          int64
+	Key            *rsa.PrivateKey
+	InstallationID int64
+	Org            string
+
+	// BaseURL is the GitHub Enterprise Server API URL, github.com is used when empty.
+	BaseURL string
+
+	// Now returns the current time, time.Now when nil.
+	Now func() time.Time
+
+	mu sync.Mutex
+}
+
+// ParseAppKey parses the PEM encoded private key of a GitHub App, PKCS#1 as downloaded from GitHub or PKCS#8.
+func ParseAppKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("x509.ParsePKCS8PrivateKey error: %w", err)
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is %T, expected RSA", parsed)
+	}
+
+	return key, nil
+}
+
+// MintAppJWT returns the RS256 signed JWT that authenticates the app itself, valid from now for AppJWTLifetime.
+func MintAppJWT(appID int64, key *rsa.PrivateKey, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal error: %w", err)
+	}
+
+	claims, err := json.Marshal(map[string]int64{
+		"iat": now.Add(-appJWTSkew).Unix(),
+		"exp": now.Add(AppJWTLifetime).Unix(),
+		"iss": appID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal error: %w", err)
+	}
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("rsa.SignPKCS1v15 error: %w", err)
+	}
+
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+func (src *AppTokenSource) Name() string {
+	return fmt.Sprintf("GitHub App %d", src.AppID)
+}
+
+// Token exchanges a fresh JWT for an installation token. The token reports itself expired AppTokenRefresh
+// early so the client asks for the next one while the current one still works.
+func (src *AppTokenSource) Token() (*oauth2.Token, error) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	now := time.Now
+	if src.Now != nil {
+		now = src.Now
+	}
+
+	jwt, err := MintAppJWT(src.AppID, src.Key, now())
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := src.appClient(jwt)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), appRequestTimeout)
+	defer cancel()
+
+	if src.InstallationID == 0 {
+		inst, _, ierr := client.Apps.FindOrganizationInstallation(ctx, src.Org)
+		if ierr != nil {
+			return nil, fmt.Errorf("client.Apps.FindOrganizationInstallation error: %w", ierr)
+		}
+
+		src.InstallationID = inst.GetID()
+	}
+
+	token, _, err := client.Apps.CreateInstallationToken(ctx, src.InstallationID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("client.Apps.CreateInstallationToken error: %w", err)
+	}
+
+	//nolint:exhaustruct // installation tokens are plain bearer tokens
+	otoken := oauth2.Token{AccessToken: token.GetToken()}
+	if expires := token.GetExpiresAt(); !expires.IsZero() {
+		otoken.Expiry = expires.Add(-AppTokenRefresh)
+	}
+
+	return &otoken, nil
+}
+
+// appClient returns a client authenticated as the app itself by jwt.
+func (src *AppTokenSource) appClient(jwt string) (*github.Client, error) {
+	httpClient := oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: jwt}))
+
+	if len(src.BaseURL) == 0 {
+		return github.NewClient(httpClient), nil
+	}
+
+	client, err := github.NewEnterpriseClient(src.BaseURL, src.BaseURL, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("github.NewEnterpriseClient error: %w", err)
+	}
+
+	return client, nil
+}
@@ -0,0 +1,215 @@
+package githubapi_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	appKeyOnce sync.Once
+	appKey     *rsa.PrivateKey
+)
+
+func testAppKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	appKeyOnce.Do(func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("rsa.GenerateKey returned error: %v", err)
+		}
+
+		appKey = key
+	})
+
+	return appKey
+}
+
+func TestParseAppKey(t *testing.T) {
+	key := testAppKey(t)
+
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	parsed, err := githubapi.ParseAppKey(pkcs1)
+	if assert.NoError(t, err) {
+		assert.True(t, key.Equal(parsed))
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("x509.MarshalPKCS8PrivateKey returned error: %v", err)
+	}
+
+	parsed, err = githubapi.ParseAppKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if assert.NoError(t, err) {
+		assert.True(t, key.Equal(parsed))
+	}
+
+	_, err = githubapi.ParseAppKey([]byte("not a key"))
+	assert.ErrorContains(t, err, "no PEM data found")
+}
+
+func TestMintAppJWT(t *testing.T) {
+	key := testAppKey(t)
+	now := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+
+	jwt, err := githubapi.MintAppJWT(42, key, now)
+	if err != nil {
+		t.Fatalf("githubapi.MintAppJWT returned error: %v", err)
+	}
+
+	parts := strings.Split(jwt, ".")
+	if !assert.Len(t, parts, 3) {
+		return
+	}
+
+	header := map[string]string{}
+	claims := map[string]int64{}
+
+	for i, dst := range []any{&header, &claims} {
+		data, derr := base64.RawURLEncoding.DecodeString(parts[i])
+		if assert.NoError(t, derr) {
+			assert.NoError(t, json.Unmarshal(data, dst))
+		}
+	}
+
+	assert.Equal(t, "RS256", header["alg"])
+	assert.Equal(t, int64(42), claims["iss"])
+	assert.Equal(t, now.Add(-time.Minute).Unix(), claims["iat"])
+	assert.Equal(t, now.Add(githubapi.AppJWTLifetime).Unix(), claims["exp"])
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if assert.NoError(t, err) {
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig))
+	}
+}
+
+// appServer answers the installation lookup and token endpoints, handing out installation tokens that
+// expire after lifetime, and the user endpoint, recording the token each request used.
+type appServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	minted   int
+	lookups  int
+	used     []string
+	lifetime time.Duration
+}
+
+func newAppServer(t *testing.T, lifetime time.Duration) *appServer {
+	t.Helper()
+
+	srv := appServer{lifetime: lifetime}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/orgs/widgets/installation", func(wtr http.ResponseWriter, req *http.Request) {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+
+		srv.lookups++
+		assert.Equal(t, http.MethodGet, req.Method)
+		fmt.Fprint(wtr, `{"id":7}`)
+	})
+	mux.HandleFunc("/api/v3/app/installations/7/access_tokens", func(wtr http.ResponseWriter, req *http.Request) {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+
+		srv.minted++
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Len(t, strings.Split(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "), "."), 3)
+		fmt.Fprintf(wtr, `{"token":"ghs_%d","expires_at":%q}`, srv.minted,
+			time.Now().Add(srv.lifetime).UTC().Format(time.RFC3339))
+	})
+	mux.HandleFunc("/api/v3/user", func(wtr http.ResponseWriter, req *http.Request) {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+
+		srv.used = append(srv.used, req.Header.Get("Authorization"))
+		fmt.Fprint(wtr, `{"login":"widgets"}`)
+	})
+
+	srv.Server = httptest.NewServer(mux)
+
+	return &srv
+}
+
+func TestAppTokenSourceRefresh(t *testing.T) {
+	tests := []struct {
+		name       string
+		lifetime   time.Duration
+		wantMinted int
+		wantUsed   []string
+	}{
+		{
+			name:       "token reused while valid",
+			lifetime:   time.Hour,
+			wantMinted: 1,
+			wantUsed:   []string{"Bearer ghs_1", "Bearer ghs_1"},
+		},
+		{
+			name:       "token refreshed before expiry",
+			lifetime:   githubapi.AppTokenRefresh - time.Minute,
+			wantMinted: 3,
+			wantUsed:   []string{"Bearer ghs_2", "Bearer ghs_3"},
+		},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			srv := newAppServer(t, tst.lifetime)
+			defer srv.Close()
+
+			src := githubapi.AppTokenSource{AppID: 42, Key: testAppKey(t), Org: "widgets", BaseURL: srv.URL}
+			ctx := context.Background()
+
+			gha, err := githubapi.NewGitHubAPIFromSource(ctx, &src, srv.URL, "")
+			if err != nil {
+				t.Fatalf("githubapi.NewGitHubAPIFromSource returned error: %v", err)
+			}
+
+			for range 2 {
+				_, _, uerr := gha.Client.Users.Get(ctx, "")
+				assert.NoError(t, uerr)
+			}
+
+			assert.Equal(t, tst.wantMinted, srv.minted)
+			assert.Equal(t, 1, srv.lookups, "the installation is looked up once")
+			assert.Equal(t, tst.wantUsed, srv.used)
+			assert.Equal(t, int64(7), src.InstallationID)
+			assert.Equal(t, "GitHub App 42", src.Name())
+		})
+	}
+}
+
+func TestAppTokenSourceErrors(t *testing.T) {
+	srv := newAppServer(t, time.Hour)
+	defer srv.Close()
+
+	src := githubapi.AppTokenSource{AppID: 42, Key: testAppKey(t), Org: "gadgets", BaseURL: srv.URL}
+
+	_, err := src.Token()
+	assert.ErrorContains(t, err, "client.Apps.FindOrganizationInstallation error")
+
+	src = githubapi.AppTokenSource{AppID: 42, Key: testAppKey(t), InstallationID: 8, BaseURL: srv.URL}
+
+	_, err = src.Token()
+	assert.ErrorContains(t, err, "client.Apps.CreateInstallationToken error")
+}
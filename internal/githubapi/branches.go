@@ -0,0 +1,194 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v53/github"
+	"github.com/mjdusa/github-fork-update/internal/pattern"
+)
+
+// branchPatterns returns the branch selector for the fork, a per-repository entry replaces the per-run one.
+func (opts *SyncOptions) branchPatterns(fullName string) pattern.List {
+	if pats, ok := opts.RepoBranches[strings.ToLower(fullName)]; ok {
+		return pats
+	}
+
+	return opts.Branches
+}
+
+// listAllBranches pages through every branch of the repository and returns their names.
+func (api *GitHubAPI) listAllBranches(ctx context.Context, owner string, repo string) (map[string]string, error) {
+	names := map[string]string{}
+	page := 1
+	perPage := 100
+
+	for {
+		//nolint:exhaustruct // defaults are desired except for paging
+		opts := github.BranchListOptions{
+			ListOptions: github.ListOptions{
+				Page:    page,
+				PerPage: perPage,
+			},
+		}
+
+		branches, err := api.ListBranches(ctx, owner, repo, &opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, branch := range branches {
+			names[branch.GetName()] = branch.GetCommit().GetSHA()
+		}
+
+		if len(branches) < perPage {
+			return names, nil
+		}
+
+		page++
+	}
+}
+
+// branchSets holds the branch heads of a fork and of its upstream repository.
+type branchSets struct {
+	upOwner  string
+	upName   string
+	fork     map[string]string
+	upstream map[string]string
+}
+
+// listBranchSets lists the branches of the fork and of its parent.
+func (api *GitHubAPI) listBranchSets(ctx context.Context, owner string, name string) (*branchSets, error) {
+	repo, err := api.GetRepository(ctx, owner, name)
+	if err != nil {
+		return nil, err
+	}
+
+	parent := repo.GetParent()
+	if parent == nil {
+		return nil, fmt.Errorf("repository %s/%s has no parent", owner, name)
+	}
+
+	sets := branchSets{
+		upOwner: parent.GetOwner().GetLogin(),
+		upName:  parent.GetName(),
+	}
+
+	sets.fork, err = api.listAllBranches(ctx, owner, name)
+	if err != nil {
+		return nil, fmt.Errorf("listAllBranches error: %w", err)
+	}
+
+	sets.upstream, err = api.listAllBranches(ctx, sets.upOwner, sets.upName)
+	if err != nil {
+		return nil, fmt.Errorf("listAllBranches error: %w", err)
+	}
+
+	return &sets, nil
+}
+
+// matching returns defaultBranch followed by the sorted branches present on both sides that match pats.
+func (sets *branchSets) matching(defaultBranch string, pats pattern.List) []string {
+	matched := make([]string, 0)
+
+	for branch := range sets.fork {
+		if branch == defaultBranch || !pats.Match(branch) {
+			continue
+		}
+
+		if _, ok := sets.upstream[branch]; ok {
+			matched = append(matched, branch)
+		}
+	}
+
+	sort.Strings(matched)
+
+	return append([]string{defaultBranch}, matched...)
+}
+
+// missing returns the sorted upstream branches that match pats but do not exist in the fork.
+func (sets *branchSets) missing(pats pattern.List) []string {
+	names := make([]string, 0)
+
+	for branch := range sets.upstream {
+		if _, ok := sets.fork[branch]; !ok && pats.Match(branch) {
+			names = append(names, branch)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// MatchingBranches returns the fork's default branch followed by every other branch that exists in
+// both the fork and its upstream and matches pats, sorted by name.
+func (api *GitHubAPI) MatchingBranches(ctx context.Context, owner string, name string, defaultBranch string,
+	pats pattern.List) ([]string, error) {
+	sets, err := api.listBranchSets(ctx, owner, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return sets.matching(defaultBranch, pats), nil
+}
+
+// createMissingBranches creates, or plans when dryRun is set, a fork branch for every upstream
+// branch in sets that matches pats and is missing from the fork. The new branch points at the
+// upstream head, which the fork can reference because forks share the upstream object network.
+func (api *GitHubAPI) createMissingBranches(ctx context.Context, owner string, name string, sets *branchSets,
+	pats pattern.List, dryRun bool) []*SyncResult {
+	results := make([]*SyncResult, 0)
+
+	for _, branch := range sets.missing(pats) {
+		sha := sets.upstream[branch]
+		res := SyncResult{
+			Owner:          owner,
+			Repo:           name,
+			Branch:         branch,
+			Planned:        dryRun,
+			UpstreamOwner:  sets.upOwner,
+			UpstreamRepo:   sets.upName,
+			UpstreamBranch: branch,
+			UpstreamSHA:    sha,
+		}
+
+		if dryRun {
+			res.MergeType = MergeTypeCreate
+			res.Message = fmt.Sprintf("would create branch from upstream %s at %s", res.UpstreamFullName(), shortSHA(sha))
+		} else {
+			api.createUpstreamBranch(ctx, &res)
+		}
+
+		results = append(results, &res)
+	}
+
+	return results
+}
+
+// createUpstreamBranch creates res.Branch in the fork at res.UpstreamSHA and records the outcome in res.
+func (api *GitHubAPI) createUpstreamBranch(ctx context.Context, res *SyncResult) {
+	if err := api.CreateBranch(ctx, res.Owner, res.Repo, res.Branch, res.UpstreamSHA); err != nil {
+		res.Status, res.Message = errorStatus(err)
+		res.Err = fmt.Errorf("CreateBranch error: %w", err)
+
+		return
+	}
+
+	res.MergeType = MergeTypeCreated
+	res.HeadSHA = res.UpstreamSHA
+	res.Message = fmt.Sprintf("created branch from upstream %s at %s", res.UpstreamFullName(), shortSHA(res.UpstreamSHA))
+}
+
+// shortSHA abbreviates a commit SHA for console output.
+func shortSHA(sha string) string {
+	const shortLen = 7
+
+	if len(sha) > shortLen {
+		return sha[:shortLen]
+	}
+
+	return sha
+}
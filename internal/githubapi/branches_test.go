@@ -0,0 +1,227 @@
+package githubapi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v53/github"
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/http/httptest"
+	"github.com/mjdusa/github-fork-update/internal/pattern"
+	"github.com/stretchr/testify/assert"
+)
+
+// handleBranchRepo registers a fork of Test_upstream/name with the given fork and upstream branches.
+func handleBranchRepo(t *testing.T, srvr *httptest.Server, owner string, name string,
+	forkBranches []string, upBranches []string) {
+	t.Helper()
+
+	srvr.Mux.HandleFunc(fmt.Sprintf("/repos/%s/%s", owner, name), func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodGet)
+		fmt.Fprintf(wtr, `{"name":%q,"owner":{"login":%q},"fork":true,"default_branch":"main",`+
+			`"parent":{"name":%q,"owner":{"login":"Test_upstream"},"default_branch":"main"}}`, name, owner, name)
+	})
+
+	branchesJSON := func(names []string) string {
+		branches := make([]map[string]any, 0, len(names))
+		for _, n := range names {
+			branches = append(branches, map[string]any{"name": n, "commit": map[string]string{"sha": "sha-" + n}})
+		}
+
+		data, _ := json.Marshal(branches)
+
+		return string(data)
+	}
+
+	srvr.Mux.HandleFunc(fmt.Sprintf("/repos/%s/%s/branches", owner, name), func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodGet)
+		fmt.Fprint(wtr, branchesJSON(forkBranches))
+	})
+
+	srvr.Mux.HandleFunc(fmt.Sprintf("/repos/Test_upstream/%s/branches", name),
+		func(wtr http.ResponseWriter, req *http.Request) {
+			testMethod(t, req, http.MethodGet)
+			fmt.Fprint(wtr, branchesJSON(upBranches))
+		})
+}
+
+func TestMatchingBranches(t *testing.T) {
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+	defer srvr.Close()
+
+	handleBranchRepo(t, srvr, "Test_owner", "Test_repo",
+		[]string{"main", "release-2", "release-1", "v2", "feature", "release-fork-only"},
+		[]string{"main", "release-1", "release-2", "v2", "feature", "release-3"})
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	pats, perr := pattern.Parse("release-*,re:^v[0-9]+$")
+	if perr != nil {
+		t.Fatalf("pattern.Parse returned error: %v", perr)
+	}
+
+	branches, err := gha.MatchingBranches(ctx, "Test_owner", "Test_repo", "main", pats)
+	if err != nil {
+		t.Fatalf("githubapi.MatchingBranches returned error: %v", err)
+	}
+
+	assert.Equal(t, []string{"main", "release-1", "release-2", "v2"}, branches)
+}
+
+func TestSyncForksBranches(t *testing.T) {
+	var mu sync.Mutex
+	merged := make([]string, 0)
+
+	srvr := newSyncTestServer(t, 1, func(wtr http.ResponseWriter, req *http.Request, repo string) {
+		rmur := new(github.RepoMergeUpstreamRequest)
+		json.NewDecoder(req.Body).Decode(rmur) //nolint:errcheck  // We don't care about the error here
+
+		mu.Lock()
+		merged = append(merged, repo+":"+rmur.GetBranch())
+		mu.Unlock()
+
+		fmt.Fprint(wtr, `{"message":"merged","merge_type":"merge"}`)
+	})
+	defer srvr.Close()
+
+	handleBranchRepo(t, srvr, syncTestOwner, "repo00", []string{"main", "release-1", "dev"},
+		[]string{"main", "release-1", "dev"})
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	runPats, _ := pattern.Parse("release-*")
+	repoPats, _ := pattern.Parse("dev")
+
+	rpt, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{Branches: runPats})
+	if err != nil {
+		t.Fatalf("githubapi.SyncForks returned error: %v", err)
+	}
+
+	assert.Equal(t, []string{"repo00:main", "repo00:release-1"}, merged)
+	assert.Len(t, rpt.Results, 2)
+	assert.Contains(t, out.String(), "-> Repo 'Test_owner/repo00 release-1' merged")
+
+	merged = merged[:0]
+
+	_, err = gha.SyncForks(ctx, "", githubapi.SyncOptions{
+		Branches:     runPats,
+		RepoBranches: map[string]pattern.List{"test_owner/repo00": repoPats},
+	})
+	if err != nil {
+		t.Fatalf("githubapi.SyncForks returned error: %v", err)
+	}
+
+	assert.Equal(t, []string{"repo00:main", "repo00:dev"}, merged)
+}
+
+func TestSyncForksCreateBranches(t *testing.T) {
+	srvr := newSyncTestServer(t, 1, func(wtr http.ResponseWriter, _ *http.Request, _ string) {
+		fmt.Fprint(wtr, `{"message":"ok","merge_type":"none"}`)
+	})
+	defer srvr.Close()
+
+	handleBranchRepo(t, srvr, syncTestOwner, "repo00", []string{"main", "release-1"},
+		[]string{"main", "release-1", "release-2", "release-3", "feature"})
+
+	created := make([]map[string]string, 0)
+	srvr.Mux.HandleFunc(fmt.Sprintf("/repos/%s/repo00/git/refs", syncTestOwner),
+		func(wtr http.ResponseWriter, req *http.Request) {
+			testMethod(t, req, http.MethodPost)
+
+			ref := map[string]string{}
+			json.NewDecoder(req.Body).Decode(&ref) //nolint:errcheck  // We don't care about the error here
+
+			if ref["ref"] == "refs/heads/release-3" {
+				wtr.WriteHeader(http.StatusUnprocessableEntity)
+				fmt.Fprint(wtr, `{"message":"Reference already exists"}`)
+
+				return
+			}
+
+			created = append(created, ref)
+			fmt.Fprint(wtr, `{"ref":"`+ref["ref"]+`"}`)
+		})
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	pats, _ := pattern.Parse("release-*")
+
+	rpt, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{CreateBranches: pats, ContinueOnError: true})
+	assert.ErrorIs(t, err, githubapi.ErrSyncFailures)
+
+	if assert.Len(t, created, 1) {
+		assert.Equal(t, map[string]string{"ref": "refs/heads/release-2", "sha": "sha-release-2"}, created[0])
+	}
+
+	if assert.Len(t, rpt.Results, 3) {
+		assert.Equal(t, githubapi.MergeTypeCreated, rpt.Results[1].MergeType)
+		assert.Equal(t, http.StatusUnprocessableEntity, rpt.Results[2].Status)
+	}
+
+	assert.Contains(t, out.String(), "-> Repo 'Test_owner/repo00 release-2' created branch from upstream "+
+		"Test_upstream/repo00:release-2 at sha-rel")
+	assert.Contains(t, out.String(), "-> Created 1 branch(es) from upstream")
+}
+
+func TestSyncForksCreateBranchesDryRun(t *testing.T) {
+	srvr := newSyncTestServer(t, 1, func(_ http.ResponseWriter, _ *http.Request, _ string) {
+		t.Errorf("merge-upstream should not be called during a dry-run")
+	})
+	defer srvr.Close()
+
+	handleBranchRepo(t, srvr, syncTestOwner, "repo00", []string{"main"}, []string{"main", "release-2"})
+	handleRef(t, srvr, syncTestOwner, "repo00", "main", "sha-main")
+	srvr.Mux.HandleFunc("/repos/Test_upstream/repo00/compare/main...Test_owner:main",
+		func(wtr http.ResponseWriter, _ *http.Request) {
+			fmt.Fprint(wtr, `{"status":"identical","base_commit":{"sha":"sha-main"}}`)
+		})
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	gha.Out = &bytes.Buffer{}
+
+	pats, _ := pattern.Parse("release-*")
+
+	rpt, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{CreateBranches: pats, DryRun: true})
+	if err != nil {
+		t.Fatalf("githubapi.SyncForks returned error: %v", err)
+	}
+
+	plan := rpt.Plan()
+	if assert.Len(t, plan.Entries, 2) {
+		assert.Equal(t, githubapi.MergeTypeCreate, plan.Entries[1].Action)
+		assert.Equal(t, "sha-release-2", plan.Entries[1].UpstreamSHA)
+		assert.True(t, plan.Entries[1].Actionable())
+	}
+}
@@ -0,0 +1,64 @@
+package githubapi
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// cachedRoutes are the list requests Cacheable accepts, * stands for a single path segment: the
+// repositories and organizations of an owner, and the forks, branches, tags and pull requests of a
+// repository.
+var cachedRoutes = [][]string{
+	{"user", "repos"},
+	{"user", "orgs"},
+	{"users", "*", "repos"},
+	{"users", "*", "orgs"},
+	{"orgs", "*", "repos"},
+	{"repos", "*", "*", "forks"},
+	{"repos", "*", "*", "branches"},
+	{"repos", "*", "*", "tags"},
+	{"repos", "*", "*", "pulls"},
+}
+
+// routeRoots are the first segments of the API routes, whatever precedes them is the base URL path.
+var routeRoots = []string{"user", "users", "orgs", "repos"}
+
+// Cacheable reports whether req is one of the list or compare requests of GitHubAPI, whose responses are
+// worth keeping to revalidate them on the next run.
+func Cacheable(req *http.Request) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+
+	root := slices.IndexFunc(parts, func(part string) bool { return slices.Contains(routeRoots, part) })
+	if root < 0 {
+		return false
+	}
+
+	route := parts[root:]
+
+	// the head of a comparison may itself contain slashes
+	if len(route) >= 5 && route[0] == "repos" && route[3] == "compare" {
+		return true
+	}
+
+	return slices.ContainsFunc(cachedRoutes, func(pattern []string) bool { return matchRoute(pattern, route) })
+}
+
+// matchRoute reports whether route has the segments of pattern.
+func matchRoute(pattern []string, route []string) bool {
+	if len(pattern) != len(route) {
+		return false
+	}
+
+	for idx, seg := range pattern {
+		if seg != "*" && seg != route[idx] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,99 @@
+package githubapi_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	nethttptest "net/http/httptest"
+	"testing"
+
+	"github.com/google/go-github/v53/github"
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/httpcache"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestCacheable(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   bool
+	}{
+		{method: http.MethodGet, path: "/users/o/repos", want: true},
+		{method: http.MethodGet, path: "/orgs/o/repos", want: true},
+		{method: http.MethodGet, path: "/user/orgs", want: true},
+		{method: http.MethodGet, path: "/api/v3/repos/o/app/branches", want: true},
+		{method: http.MethodGet, path: "/repos/o/app/tags", want: true},
+		{method: http.MethodGet, path: "/repos/o/app/forks", want: true},
+		{method: http.MethodGet, path: "/repos/o/app/pulls", want: true},
+		{method: http.MethodGet, path: "/repos/o/app/compare/up:main...main", want: true},
+		{method: http.MethodGet, path: "/user/repos", want: true},
+		{method: http.MethodGet, path: "/api/v3/repos/users/app/tags", want: true},
+		{method: http.MethodGet, path: "/repos/o/app/compare/up:main...sync/upstream", want: true},
+		{method: http.MethodGet, path: "/repos/o/app", want: false},
+		{method: http.MethodGet, path: "/repos/o/tags", want: false},
+		{method: http.MethodGet, path: "/repos/o/forks", want: false},
+		{method: http.MethodGet, path: "/api/v3/repos/o/pulls", want: false},
+		{method: http.MethodGet, path: "/repos/o/app/git/matching-refs/tags", want: false},
+		{method: http.MethodGet, path: "/repos/o/app/pulls/7", want: false},
+		{method: http.MethodGet, path: "/repos/o/app/compare", want: false},
+		{method: http.MethodGet, path: "/users/o", want: false},
+		{method: http.MethodGet, path: "/meta/repos", want: false},
+		{method: http.MethodGet, path: "/repos/o/app/git/ref/heads/main", want: false},
+		{method: http.MethodGet, path: "/user", want: false},
+		{method: http.MethodPost, path: "/repos/o/app/pulls", want: false},
+	}
+
+	for _, tst := range tests {
+		req, err := http.NewRequest(tst.method, "https://api.github.com"+tst.path, nil) //nolint:noctx // never sent
+		if err != nil {
+			t.Fatalf("http.NewRequest returned error: %v", err)
+		}
+
+		assert.Equal(t, tst.want, githubapi.Cacheable(req), "%s %s", tst.method, tst.path)
+	}
+}
+
+func TestCachedBranches(t *testing.T) {
+	etag := `"branches-1"`
+	notModified := 0
+
+	srvr := nethttptest.NewServer(http.HandlerFunc(func(wtr http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+
+		if req.Header.Get("If-None-Match") == etag {
+			notModified++
+			wtr.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		wtr.Header().Set("ETag", etag)
+		fmt.Fprint(wtr, `[{"name":"main"},{"name":"dev"}]`)
+	}))
+	defer srvr.Close()
+
+	cache, err := httpcache.NewCache(t.TempDir(), httpcache.DefaultMaxBytes)
+	if err != nil {
+		t.Fatalf("httpcache.NewCache returned error: %v", err)
+	}
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient,
+		&http.Client{Transport: cache.Transport(http.DefaultTransport, githubapi.Cacheable)})
+
+	gha, err := githubapi.NewGitHubAPIFromSource(ctx, githubapi.StaticTokenSource("token", "test"), srvr.URL, "")
+	if err != nil {
+		t.Fatalf("githubapi.NewGitHubAPIFromSource returned error: %v", err)
+	}
+
+	for range 2 {
+		branches, _, lerr := gha.Client.Repositories.ListBranches(ctx, "o", "app", &github.BranchListOptions{})
+		if assert.NoError(t, lerr) && assert.Len(t, branches, 2) {
+			assert.Equal(t, "dev", branches[1].GetName())
+		}
+	}
+
+	assert.Equal(t, 1, notModified)
+	assert.Equal(t, int64(1), cache.Hits())
+}
@@ -0,0 +1,83 @@
+package githubapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/google/go-github/v53/github"
+)
+
+const (
+	// EnterpriseVersionHeader is the response header GitHub Enterprise Server reports its version in.
+	EnterpriseVersionHeader = "X-GitHub-Enterprise-Version"
+
+	// MergeUpstreamMinVersion is the first GitHub Enterprise Server release with the merge-upstream endpoint.
+	MergeUpstreamMinVersion = "3.4"
+)
+
+// ErrMergeUpstreamUnsupported is returned when the GitHub Enterprise Server predates merge-upstream.
+var ErrMergeUpstreamUnsupported = errors.New("merge-upstream is not available on this server")
+
+// NewEnterpriseGitHubAPI returns a GitHubAPI for a GitHub Enterprise Server. The "/api/v3/" suffix is
+// added to baseURL when missing, uploadURL defaults to baseURL.
+func NewEnterpriseGitHubAPI(ctx context.Context, auth string, baseURL string, uploadURL string) (*GitHubAPI, error) {
+	if len(auth) == 0 {
+		return nil, fmt.Errorf("empty token error")
+	}
+
+	if len(baseURL) == 0 {
+		return nil, fmt.Errorf("empty base url error")
+	}
+
+	return NewGitHubAPIFromSource(ctx, StaticTokenSource(auth, "token"), baseURL, uploadURL)
+}
+
+// ServerVersion returns the GitHub Enterprise Server version seen in the latest response that
+// reported one, empty for github.com or before any such response.
+func (api *GitHubAPI) ServerVersion() string {
+	api.versionMu.Lock()
+	defer api.versionMu.Unlock()
+
+	return api.serverVersion
+}
+
+// DetectServerVersion asks the meta endpoint for the server version, it is empty for github.com.
+func (api *GitHubAPI) DetectServerVersion(ctx context.Context) (string, error) {
+	_, resp, err := withRateLimit(ctx, api, func() (*github.APIMeta, *github.Response, error) {
+		return api.Client.APIMeta(ctx)
+	})
+	if err != nil {
+		return "", fmt.Errorf("api.client.APIMeta error: %w", err)
+	}
+
+	api.noteServerVersion(resp.Response)
+
+	return api.ServerVersion(), nil
+}
+
+// noteServerVersion records the server version reported by the response, if any.
+func (api *GitHubAPI) noteServerVersion(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+
+	if version := resp.Header.Get(EnterpriseVersionHeader); len(version) > 0 {
+		api.versionMu.Lock()
+		defer api.versionMu.Unlock()
+
+		api.serverVersion = version
+	}
+}
+
+// mergeUpstreamUnsupported reports whether the server version predates merge-upstream.
+func mergeUpstreamUnsupported(version string) bool {
+	ver, err := semver.NewVersion(version)
+	if err != nil {
+		return false
+	}
+
+	return ver.LessThan(semver.MustParse(MergeUpstreamMinVersion))
+}
@@ -0,0 +1,131 @@
+package githubapi_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/http/httptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEnterpriseGitHubAPI(t *testing.T) {
+	ctx := context.Background()
+
+	gha, err := githubapi.NewEnterpriseGitHubAPI(ctx, "auth", "https://github.example.com", "")
+	if err != nil {
+		t.Fatalf("githubapi.NewEnterpriseGitHubAPI returned error: %v", err)
+	}
+
+	assert.Equal(t, "https://github.example.com/api/v3/", gha.Client.BaseURL.String())
+	assert.Equal(t, "https://github.example.com/api/uploads/", gha.Client.UploadURL.String())
+
+	_, err = githubapi.NewEnterpriseGitHubAPI(ctx, "", "https://github.example.com", "")
+	assert.Error(t, err)
+
+	_, err = githubapi.NewEnterpriseGitHubAPI(ctx, "auth", "", "")
+	assert.Error(t, err)
+
+	_, err = githubapi.NewEnterpriseGitHubAPI(ctx, "auth", "://bad", "")
+	assert.Error(t, err)
+}
+
+func TestDetectServerVersion(t *testing.T) {
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+	defer srvr.Close()
+
+	srvr.Mux.HandleFunc("/meta", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodGet)
+		wtr.Header().Set(githubapi.EnterpriseVersionHeader, "3.11.2")
+		fmt.Fprint(wtr, `{"verifiable_password_authentication":true}`)
+	})
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	assert.Empty(t, gha.ServerVersion())
+
+	version, err := gha.DetectServerVersion(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "3.11.2", version)
+		assert.Equal(t, "3.11.2", gha.ServerVersion())
+	}
+}
+
+func TestMergeUpstreamUnsupported(t *testing.T) {
+	tests := []struct {
+		version     string
+		unsupported bool
+	}{
+		{version: "3.2.5", unsupported: true},
+		{version: "3.4.0", unsupported: false},
+		{version: "", unsupported: false},
+	}
+
+	for _, tst := range tests {
+		t.Run("version_"+tst.version, func(t *testing.T) {
+			srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+			if serr != nil {
+				t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+			}
+			defer srvr.Close()
+
+			srvr.Mux.HandleFunc("/repos/o/r/merge-upstream", func(wtr http.ResponseWriter, _ *http.Request) {
+				if len(tst.version) > 0 {
+					wtr.Header().Set(githubapi.EnterpriseVersionHeader, tst.version)
+				}
+
+				wtr.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(wtr, `{"message":"Not Found"}`)
+			})
+
+			ctx := context.Background()
+			gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+			if nerr != nil {
+				t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+			}
+
+			out := bytes.Buffer{}
+			gha.Out = &out
+
+			err := gha.MergeUpstreamFork(ctx, "o", "r", "main", false)
+
+			assert.Equal(t, tst.unsupported, errors.Is(err, githubapi.ErrMergeUpstreamUnsupported), "error: %v", err)
+			assert.Equal(t, tst.version, gha.ServerVersion())
+		})
+	}
+}
+
+func TestSyncForksMergeUpstreamUnsupported(t *testing.T) {
+	srvr := newSyncTestServer(t, 2, func(wtr http.ResponseWriter, _ *http.Request, _ string) {
+		wtr.Header().Set(githubapi.EnterpriseVersionHeader, "3.3.0")
+		wtr.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(wtr, `{"message":"Not Found"}`)
+	})
+	defer srvr.Close()
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	_, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{ContinueOnError: true})
+
+	assert.ErrorIs(t, err, githubapi.ErrMergeUpstreamUnsupported)
+	assert.Contains(t, out.String(), "merge-upstream requires GitHub Enterprise Server 3.4 or later, server is 3.3.0")
+}
@@ -0,0 +1,160 @@
+package githubapi
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v53/github"
+	"github.com/mjdusa/github-fork-update/internal/pattern"
+)
+
+const (
+	// ArchivedInclude syncs archived forks along with the others, it is the default.
+	ArchivedInclude = "include"
+
+	// ArchivedExclude skips archived forks.
+	ArchivedExclude = "exclude"
+
+	// ArchivedOnly syncs archived forks only.
+	ArchivedOnly = "only"
+
+	visibilityPublic  = "public"
+	visibilityPrivate = "private"
+)
+
+// ForkFilter selects the forks SyncForks syncs, the zero value selects every fork.
+type ForkFilter struct {
+	// Include, when set, syncs only forks whose owner/name matches. Exclude skips forks whose
+	// owner/name matches and wins over Include.
+	Include pattern.List
+	Exclude pattern.List
+
+	// Visibilities, when set, holds the visibilities (public, private or internal) to sync.
+	Visibilities []string
+
+	// Archived is one of ArchivedInclude, ArchivedExclude or ArchivedOnly, empty is ArchivedInclude.
+	Archived string
+
+	// Topics, when set, syncs only forks with at least one matching topic.
+	Topics pattern.List
+
+	// Languages, when set, syncs only forks whose primary language matches.
+	Languages pattern.List
+
+	// PushedWithin, when positive, skips forks last pushed longer ago.
+	PushedWithin time.Duration
+}
+
+// ValidArchived reports whether value is a known ForkFilter.Archived setting.
+func ValidArchived(value string) bool {
+	switch value {
+	case "", ArchivedInclude, ArchivedExclude, ArchivedOnly:
+		return true
+	default:
+		return false
+	}
+}
+
+// ValidVisibility reports whether value is a repository visibility GitHub reports.
+func ValidVisibility(value string) bool {
+	switch value {
+	case visibilityPublic, visibilityPrivate, "internal":
+		return true
+	default:
+		return false
+	}
+}
+
+// skipReason explains why the fork is not synced, it is empty when the fork is selected. The push permission
+// is checked whatever the source: a fork the token cannot push to would only fail its merge-upstream call, and
+// the authenticated user's own forks always grant push, so in practice this skips organization and -repos forks.
+func (opts SyncOptions) skipReason(repo *github.Repository, now time.Time) string {
+	fullName := repo.GetOwner().GetLogin() + "/" + repo.GetName()
+
+	if opts.SkipRepos[strings.ToLower(fullName)] {
+		return "is skipped by configuration"
+	}
+
+	if repo.Permissions != nil && !repo.Permissions["push"] {
+		return "is not writable with this token"
+	}
+
+	return opts.Filter.skipReason(repo, fullName, now)
+}
+
+func (filter *ForkFilter) skipReason(repo *github.Repository, fullName string, now time.Time) string {
+	if pat := filter.Exclude.Find(fullName); pat != nil {
+		return fmt.Sprintf("matches exclude pattern %q", pat)
+	}
+
+	if len(filter.Include) > 0 && !filter.Include.Match(fullName) {
+		return "matches no include pattern"
+	}
+
+	if visibility := repoVisibility(repo); len(filter.Visibilities) > 0 && !contains(filter.Visibilities, visibility) {
+		return fmt.Sprintf("is %s", visibility)
+	}
+
+	switch {
+	case filter.Archived == ArchivedExclude && repo.GetArchived():
+		return "is archived"
+	case filter.Archived == ArchivedOnly && !repo.GetArchived():
+		return "is not archived"
+	}
+
+	if len(filter.Topics) > 0 && !filter.anyTopic(repo.Topics) {
+		return fmt.Sprintf("has no matching topic (topics: %s)", strings.Join(repo.Topics, ", "))
+	}
+
+	if len(filter.Languages) > 0 && !filter.Languages.Match(repo.GetLanguage()) {
+		return fmt.Sprintf("has primary language %q", repo.GetLanguage())
+	}
+
+	if filter.PushedWithin > 0 {
+		pushed := repo.GetPushedAt().Time
+		if pushed.IsZero() {
+			return "has never been pushed"
+		}
+
+		if age := now.Sub(pushed); age > filter.PushedWithin {
+			return fmt.Sprintf("was last pushed %d day(s) ago", int(age.Hours()/24))
+		}
+	}
+
+	return ""
+}
+
+func (filter *ForkFilter) anyTopic(topics []string) bool {
+	for _, topic := range topics {
+		if filter.Topics.Match(topic) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// repoVisibility returns the visibility of the repository, derived from its private flag when
+// GitHub did not report one.
+func repoVisibility(repo *github.Repository) string {
+	if len(repo.GetVisibility()) > 0 {
+		return repo.GetVisibility()
+	}
+
+	if repo.GetPrivate() {
+		return visibilityPrivate
+	}
+
+	return visibilityPublic
+}
+
+func contains(values []string, value string) bool {
+	for _, val := range values {
+		if val == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,179 @@
+package githubapi_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/http/httptest"
+	"github.com/mjdusa/github-fork-update/internal/pattern"
+	"github.com/stretchr/testify/assert"
+)
+
+// newFilterTestServer serves a page of forks with differing attributes and records which of
+// them were merged.
+func newFilterTestServer(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+
+	recent := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	stale := time.Now().Add(-90 * 24 * time.Hour).UTC().Format(time.RFC3339)
+
+	repos := []string{
+		`{"name":"app","visibility":"public","language":"Go","topics":["service"],"pushed_at":"` + recent + `"}`,
+		`{"name":"secret","visibility":"private","language":"Go","pushed_at":"` + recent + `"}`,
+		`{"name":"old","visibility":"public","archived":true,"language":"C","pushed_at":"` + stale + `"}`,
+		`{"name":"experiment-1","visibility":"public","language":"Python","topics":["lab"],"pushed_at":"` + recent + `"}`,
+	}
+
+	for i, repo := range repos {
+		repos[i] = `{"owner":{"login":"Test_owner"},"fork":true,"default_branch":"main",` + repo[1:]
+	}
+
+	srvr.Mux.HandleFunc("/user", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `{"login":"Test_owner"}`)
+	})
+
+	srvr.Mux.HandleFunc("/user/repos", func(wtr http.ResponseWriter, req *http.Request) {
+		// /users/{login}/repos would leave out the private forks
+		assert.Equal(t, "owner", req.URL.Query().Get("affiliation"))
+
+		if req.URL.Query().Get("page") == "1" {
+			fmt.Fprint(wtr, "["+strings.Join(repos, ",")+"]")
+		} else {
+			fmt.Fprint(wtr, `[]`)
+		}
+	})
+
+	merged := make([]string, 0)
+
+	srvr.Mux.HandleFunc("/repos/Test_owner/", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodPost)
+		merged = append(merged, strings.Split(strings.TrimPrefix(req.URL.Path, "/repos/Test_owner/"), "/")[0])
+		fmt.Fprint(wtr, `{"message":"ok","merge_type":"none"}`)
+	})
+
+	return srvr, &merged
+}
+
+func mustParse(t *testing.T, csv string) pattern.List {
+	t.Helper()
+
+	list, err := pattern.Parse(csv)
+	if err != nil {
+		t.Fatalf("pattern.Parse returned error: %v", err)
+	}
+
+	return list
+}
+
+func TestSyncForksFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		filter     githubapi.ForkFilter
+		wantMerged []string
+		wantReason string
+	}{
+		{
+			name:       "none",
+			wantMerged: []string{"app", "secret", "old", "experiment-1"},
+		},
+		{
+			name:       "exclude",
+			filter:     githubapi.ForkFilter{Exclude: mustParse(t, "*/experiment-*")},
+			wantMerged: []string{"app", "secret", "old"},
+			wantReason: `'Test_owner/experiment-1 main' matches exclude pattern "*/experiment-*", skipping...`,
+		},
+		{
+			name:       "include",
+			filter:     githubapi.ForkFilter{Include: mustParse(t, "re:^Test_owner/(app|old)$")},
+			wantMerged: []string{"app", "old"},
+			wantReason: "'Test_owner/secret main' matches no include pattern, skipping...",
+		},
+		{
+			name:       "visibility",
+			filter:     githubapi.ForkFilter{Visibilities: []string{"public"}},
+			wantMerged: []string{"app", "old", "experiment-1"},
+			wantReason: "'Test_owner/secret main' is private, skipping...",
+		},
+		{
+			name:       "private",
+			filter:     githubapi.ForkFilter{Visibilities: []string{"private"}},
+			wantMerged: []string{"secret"},
+			wantReason: "'Test_owner/app main' is public, skipping...",
+		},
+		{
+			name:       "archived exclude",
+			filter:     githubapi.ForkFilter{Archived: githubapi.ArchivedExclude},
+			wantMerged: []string{"app", "secret", "experiment-1"},
+			wantReason: "'Test_owner/old main' is archived, skipping...",
+		},
+		{
+			name:       "archived only",
+			filter:     githubapi.ForkFilter{Archived: githubapi.ArchivedOnly},
+			wantMerged: []string{"old"},
+			wantReason: "'Test_owner/app main' is not archived, skipping...",
+		},
+		{
+			name:       "topics",
+			filter:     githubapi.ForkFilter{Topics: mustParse(t, "service")},
+			wantMerged: []string{"app"},
+			wantReason: "'Test_owner/experiment-1 main' has no matching topic (topics: lab), skipping...",
+		},
+		{
+			name:       "language",
+			filter:     githubapi.ForkFilter{Languages: mustParse(t, "Go")},
+			wantMerged: []string{"app", "secret"},
+			wantReason: `'Test_owner/experiment-1 main' has primary language "Python", skipping...`,
+		},
+		{
+			name:       "pushed within",
+			filter:     githubapi.ForkFilter{PushedWithin: 30 * 24 * time.Hour},
+			wantMerged: []string{"app", "secret", "experiment-1"},
+			wantReason: "'Test_owner/old main' was last pushed 90 day(s) ago, skipping...",
+		},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			srvr, merged := newFilterTestServer(t)
+			defer srvr.Close()
+
+			ctx := context.Background()
+			gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+			if nerr != nil {
+				t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+			}
+
+			out := bytes.Buffer{}
+			gha.Out = &out
+
+			_, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{Filter: tst.filter, Verbose: true})
+			if err != nil {
+				t.Fatalf("githubapi.SyncForks returned error: %v", err)
+			}
+
+			assert.Equal(t, tst.wantMerged, *merged)
+			assert.Contains(t, out.String(), tst.wantReason)
+		})
+	}
+}
+
+func TestValidFilterValues(t *testing.T) {
+	assert.True(t, githubapi.ValidArchived(""))
+	assert.True(t, githubapi.ValidArchived(githubapi.ArchivedOnly))
+	assert.False(t, githubapi.ValidArchived("yes"))
+
+	assert.True(t, githubapi.ValidVisibility("internal"))
+	assert.False(t, githubapi.ValidVisibility("secret"))
+}
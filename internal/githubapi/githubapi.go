@@ -2,7 +2,14 @@ package githubapi
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/go-github/v53/github"
 )
@@ -18,29 +25,69 @@ const (
 
 type GitHubAPI struct {
 	Client *github.Client
+
+	// Out receives console output, defaults to os.Stdout when nil.
+	Out io.Writer
+
+	// MaxRateLimitWait is the longest pause for a rate limit reset before the rate limit error is
+	// returned, zero never pauses.
+	MaxRateLimitWait time.Duration
+
+	// MaxRetries is how often a request that failed on a 5xx response, a dropped connection or a
+	// timeout is retried, zero never retries.
+	MaxRetries int
+
+	// Debug prints every retried request with its attempt count.
+	Debug bool
+
+	outMu sync.Mutex
+
+	versionMu     sync.Mutex
+	serverVersion string
+
+	tokenMu   sync.Mutex
+	tokenInfo *TokenInfo
+
+	rateMu      sync.Mutex
+	rate        github.Rate
+	pausedUntil time.Time
+	calls       atomic.Int64
+	retries     atomic.Int64
+
+	// pool is set for clients made by NewGitHubAPIFromPool
+	pool *tokenPool
 }
 
+// NewGitHubAPI returns a GitHubAPI for github.com authenticated by the auth token.
 func NewGitHubAPI(ctx context.Context, auth string) (*GitHubAPI, error) {
 	if len(auth) == 0 {
 		return nil, fmt.Errorf("empty token error")
 	}
 
-	client := github.NewTokenClient(ctx, auth)
+	return NewGitHubAPIFromSource(ctx, StaticTokenSource(auth, "token"), "", "")
+}
 
-	if client == nil {
-		return nil, fmt.Errorf("NewTokenClient returned nil")
-	}
+// printf serializes console output so lines written from concurrent workers never interleave.
+func (api *GitHubAPI) printf(format string, args ...any) {
+	api.outMu.Lock()
+	defer api.outMu.Unlock()
 
-	api := GitHubAPI{
-		Client: client,
+	fmt.Fprintf(api.output(), format, args...)
+}
+
+func (api *GitHubAPI) output() io.Writer {
+	if api.Out == nil {
+		return os.Stdout
 	}
 
-	return &api, nil
+	return api.Out
 }
 
 func (api *GitHubAPI) ListOrganizations(ctx context.Context, username string,
 	opts *github.ListOptions) ([]*github.Organization, error) {
-	orgs, _, err := api.Client.Organizations.List(ctx, username, opts)
+	orgs, _, err := withRateLimit(ctx, api, func() ([]*github.Organization, *github.Response, error) {
+		return api.Client.Organizations.List(ctx, username, opts)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("client.Organizations.List error: %w", err)
 	}
@@ -48,10 +95,22 @@ func (api *GitHubAPI) ListOrganizations(ctx context.Context, username string,
 	return orgs, nil
 }
 
+// RateLimits returns the rate limits of the token and their remaining budget.
+func (api *GitHubAPI) RateLimits(ctx context.Context) (*github.RateLimits, error) {
+	limits, _, err := api.Client.RateLimits(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("api.client.RateLimits error: %w", err)
+	}
+
+	return limits, nil
+}
+
 // ListRepositories list the repositories of the specified user.
 func (api *GitHubAPI) ListRepositories(ctx context.Context, user string,
 	opts *github.RepositoryListOptions) ([]*github.Repository, error) {
-	repos, _, err := api.Client.Repositories.List(ctx, user, opts)
+	repos, _, err := withRateLimit(ctx, api, func() ([]*github.Repository, *github.Response, error) {
+		return api.Client.Repositories.List(ctx, user, opts)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("api.client.Repositories.List error: %w", err)
 	}
@@ -59,10 +118,25 @@ func (api *GitHubAPI) ListRepositories(ctx context.Context, user string,
 	return repos, nil
 }
 
+// ListOrgRepositories lists the repositories of the specified organization.
+func (api *GitHubAPI) ListOrgRepositories(ctx context.Context, org string,
+	opts *github.RepositoryListByOrgOptions) ([]*github.Repository, error) {
+	repos, _, err := withRateLimit(ctx, api, func() ([]*github.Repository, *github.Response, error) {
+		return api.Client.Repositories.ListByOrg(ctx, org, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("api.client.Repositories.ListByOrg error: %w", err)
+	}
+
+	return repos, nil
+}
+
 // ListForks lists the forks of the specified repository.
 func (api *GitHubAPI) ListForks(ctx context.Context, owner string, repo string,
 	opts *github.RepositoryListForksOptions) ([]*github.Repository, error) {
-	repos, _, err := api.Client.Repositories.ListForks(ctx, owner, repo, opts)
+	repos, _, err := withRateLimit(ctx, api, func() ([]*github.Repository, *github.Response, error) {
+		return api.Client.Repositories.ListForks(ctx, owner, repo, opts)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("api.client.Repositories.ListForks error: %w", err)
 	}
@@ -70,6 +144,186 @@ func (api *GitHubAPI) ListForks(ctx context.Context, owner string, repo string,
 	return repos, nil
 }
 
+// ListBranches lists the branches of the specified repository.
+func (api *GitHubAPI) ListBranches(ctx context.Context, owner string, repo string,
+	opts *github.BranchListOptions) ([]*github.Branch, error) {
+	branches, _, err := withRateLimit(ctx, api, func() ([]*github.Branch, *github.Response, error) {
+		return api.Client.Repositories.ListBranches(ctx, owner, repo, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("api.client.Repositories.ListBranches error: %w", err)
+	}
+
+	return branches, nil
+}
+
+// ListTags lists the tags of the specified repository.
+func (api *GitHubAPI) ListTags(ctx context.Context, owner string, repo string,
+	opts *github.ListOptions) ([]*github.RepositoryTag, error) {
+	tags, _, err := withRateLimit(ctx, api, func() ([]*github.RepositoryTag, *github.Response, error) {
+		return api.Client.Repositories.ListTags(ctx, owner, repo, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("api.client.Repositories.ListTags error: %w", err)
+	}
+
+	return tags, nil
+}
+
+// GetRepository returns the full repository, including the parent of a fork.
+func (api *GitHubAPI) GetRepository(ctx context.Context, owner string, repo string) (*github.Repository, error) {
+	repository, _, err := withRateLimit(ctx, api, func() (*github.Repository, *github.Response, error) {
+		return api.Client.Repositories.Get(ctx, owner, repo)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("api.client.Repositories.Get error: %w", err)
+	}
+
+	return repository, nil
+}
+
+// GetBranchSHA returns the commit SHA the branch currently points at.
+func (api *GitHubAPI) GetBranchSHA(ctx context.Context, owner string, repo string, branch string) (string, error) {
+	ref, _, err := withRateLimit(ctx, api, func() (*github.Reference, *github.Response, error) {
+		return api.Client.Git.GetRef(ctx, owner, repo, "heads/"+branch)
+	})
+	if err != nil {
+		return "", fmt.Errorf("api.client.Git.GetRef error: %w", err)
+	}
+
+	return ref.GetObject().GetSHA(), nil
+}
+
+// GetTagSHA returns the commit SHA the tag points at, an annotated tag is resolved to its commit.
+func (api *GitHubAPI) GetTagSHA(ctx context.Context, owner string, repo string, tag string) (string, error) {
+	ref, _, err := withRateLimit(ctx, api, func() (*github.Reference, *github.Response, error) {
+		return api.Client.Git.GetRef(ctx, owner, repo, "tags/"+tag)
+	})
+	if err != nil {
+		return "", fmt.Errorf("api.client.Git.GetRef error: %w", err)
+	}
+
+	if ref.GetObject().GetType() != "tag" {
+		return ref.GetObject().GetSHA(), nil
+	}
+
+	annotated, _, err := withRateLimit(ctx, api, func() (*github.Tag, *github.Response, error) {
+		return api.Client.Git.GetTag(ctx, owner, repo, ref.GetObject().GetSHA())
+	})
+	if err != nil {
+		return "", fmt.Errorf("api.client.Git.GetTag error: %w", err)
+	}
+
+	return annotated.GetObject().GetSHA(), nil
+}
+
+// CreateBranch creates a branch in the repository pointing at sha.
+func (api *GitHubAPI) CreateBranch(ctx context.Context, owner string, repo string, branch string, sha string) error {
+	ref := github.Reference{
+		Ref:    github.String("refs/heads/" + branch),
+		Object: &github.GitObject{SHA: github.String(sha)},
+	}
+
+	_, _, err := withRateLimit(ctx, api, func() (*github.Reference, *github.Response, error) {
+		return api.Client.Git.CreateRef(ctx, owner, repo, &ref)
+	})
+	if err != nil {
+		return fmt.Errorf("api.client.Git.CreateRef error: %w", err)
+	}
+
+	return nil
+}
+
+// CreateTag creates a lightweight tag in the repository pointing at sha.
+func (api *GitHubAPI) CreateTag(ctx context.Context, owner string, repo string, tag string, sha string) error {
+	ref := github.Reference{
+		Ref:    github.String("refs/tags/" + tag),
+		Object: &github.GitObject{SHA: github.String(sha)},
+	}
+
+	_, _, err := withRateLimit(ctx, api, func() (*github.Reference, *github.Response, error) {
+		return api.Client.Git.CreateRef(ctx, owner, repo, &ref)
+	})
+	if err != nil {
+		return fmt.Errorf("api.client.Git.CreateRef error: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteTag deletes the tag from the repository.
+func (api *GitHubAPI) DeleteTag(ctx context.Context, owner string, repo string, tag string) error {
+	_, err := withRateLimitNoValue(ctx, api, func() (*github.Response, error) {
+		return api.Client.Git.DeleteRef(ctx, owner, repo, "tags/"+tag)
+	})
+	if err != nil {
+		return fmt.Errorf("api.client.Git.DeleteRef error: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateBranch moves the branch to sha, force allows the update to discard commits.
+func (api *GitHubAPI) UpdateBranch(ctx context.Context, owner string, repo string, branch string, sha string,
+	force bool) error {
+	ref := github.Reference{
+		Ref:    github.String("refs/heads/" + branch),
+		Object: &github.GitObject{SHA: github.String(sha)},
+	}
+
+	_, _, err := withRateLimit(ctx, api, func() (*github.Reference, *github.Response, error) {
+		return api.Client.Git.UpdateRef(ctx, owner, repo, &ref, force)
+	})
+	if err != nil {
+		return fmt.Errorf("api.client.Git.UpdateRef error: %w", err)
+	}
+
+	return nil
+}
+
+// ListPullRequests lists the pull requests of the specified repository.
+func (api *GitHubAPI) ListPullRequests(ctx context.Context, owner string, repo string,
+	opts *github.PullRequestListOptions) ([]*github.PullRequest, error) {
+	prs, _, err := withRateLimit(ctx, api, func() ([]*github.PullRequest, *github.Response, error) {
+		return api.Client.PullRequests.List(ctx, owner, repo, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("api.client.PullRequests.List error: %w", err)
+	}
+
+	return prs, nil
+}
+
+// CreatePullRequest opens a pull request in the specified repository.
+func (api *GitHubAPI) CreatePullRequest(ctx context.Context, owner string, repo string,
+	pull *github.NewPullRequest) (*github.PullRequest, error) {
+	pr, _, err := withRateLimit(ctx, api, func() (*github.PullRequest, *github.Response, error) {
+		return api.Client.PullRequests.Create(ctx, owner, repo, pull)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("api.client.PullRequests.Create error: %w", err)
+	}
+
+	return pr, nil
+}
+
+// CompareCommits compares base and head in the specified repository, head may use the
+// "owner:branch" form to refer to a branch of another repository in the same network.
+func (api *GitHubAPI) CompareCommits(ctx context.Context, owner string, repo string, base string,
+	head string) (*github.CommitsComparison, error) {
+	//nolint:exhaustruct // only a single commit is needed, the comparison counts are returned regardless
+	opts := github.ListOptions{PerPage: 1}
+
+	comp, _, err := withRateLimit(ctx, api, func() (*github.CommitsComparison, *github.Response, error) {
+		return api.Client.Repositories.CompareCommits(ctx, owner, repo, base, head, &opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("api.client.Repositories.CompareCommits error: %w", err)
+	}
+
+	return comp, nil
+}
+
 // MergeUpstream merges the upstream repository into the fork for the specified branch.
 func (api *GitHubAPI) MergeUpstream(ctx context.Context, owner string, repo string,
 	branch string) (*github.RepoMergeUpstreamResult, error) {
@@ -77,7 +331,18 @@ func (api *GitHubAPI) MergeUpstream(ctx context.Context, owner string, repo stri
 		Branch: &branch,
 	}
 
-	result, _, err := api.Client.Repositories.MergeUpstream(ctx, owner, repo, &req)
+	result, resp, err := withRateLimit(ctx, api, func() (*github.RepoMergeUpstreamResult, *github.Response, error) {
+		return api.Client.Repositories.MergeUpstream(ctx, owner, repo, &req)
+	})
+	if resp != nil {
+		api.noteServerVersion(resp.Response)
+	}
+
+	if status, _ := errorStatus(err); status == http.StatusNotFound && mergeUpstreamUnsupported(api.ServerVersion()) {
+		return nil, fmt.Errorf("%w: GitHub Enterprise Server %s, merge-upstream requires %s or later: %w",
+			ErrMergeUpstreamUnsupported, api.ServerVersion(), MergeUpstreamMinVersion, err)
+	}
+
 	if err != nil {
 		return nil, fmt.Errorf("api.client.Repositories.MergeUpstream error: %w", err)
 	}
@@ -87,62 +352,58 @@ func (api *GitHubAPI) MergeUpstream(ctx context.Context, owner string, repo stri
 
 func (api *GitHubAPI) MergeUpstreamFork(ctx context.Context, repoOwner string,
 	repoName string, repoBranch string, verbose bool) error {
-	res, err := api.MergeUpstream(ctx, repoOwner, repoName, repoBranch)
+	res, err := api.mergeUpstreamFork(ctx, repoOwner, repoName, repoBranch)
 	if err != nil {
-		return fmt.Errorf("api.client.Repositories.MergeUpstreamFork error: %w", err)
+		return err
 	}
 
-	if res.MergeType == nil || *res.MergeType == "none" {
-		if verbose {
-			fmt.Printf("-> Repo '%s/%s %s' %s\n", repoOwner, repoName, repoBranch, *res.Message)
-		}
-	} else {
-		fmt.Printf("-> Repo '%s/%s %s' %s\n", repoOwner, repoName, repoBranch, *res.Message)
-	}
+	res.write(api, verbose)
 
 	return nil
 }
 
-func (api *GitHubAPI) SyncForks(ctx context.Context, userName string, verboseFlag bool, debugFlag bool) error {
-	user, _, err := api.Client.Users.Get(ctx, userName)
-	if err != nil {
-		return fmt.Errorf("api.client.Users.Get error: %w", err)
+// mergeUpstreamFork merges upstream into the fork branch and captures the outcome without printing it.
+func (api *GitHubAPI) mergeUpstreamFork(ctx context.Context, repoOwner string,
+	repoName string, repoBranch string) (*SyncResult, error) {
+	sres := SyncResult{
+		Owner:  repoOwner,
+		Repo:   repoName,
+		Branch: repoBranch,
 	}
 
-	page := 1
-	perPage := 30
+	res, err := api.MergeUpstream(ctx, repoOwner, repoName, repoBranch)
+	if err != nil {
+		sres.Status, sres.Message = errorStatus(err)
+		sres.Err = fmt.Errorf("api.client.Repositories.MergeUpstreamFork error: %w", err)
 
-	for {
-		//nolint:exhaustruct // defaults are desired except for paging
-		opts := github.RepositoryListOptions{
-			ListOptions: github.ListOptions{
-				Page:    page,
-				PerPage: perPage,
-			},
+		if hint := api.mergeFailureHint(sres.Status, sres.Message); len(hint) > 0 {
+			sres.Message = hint + ": " + sres.Message
 		}
 
-		repos, gerr := api.ListRepositories(ctx, *user.Login, &opts)
-		if gerr != nil {
-			return fmt.Errorf("ListRepositories error: %w", gerr)
+		if errors.Is(err, ErrMergeUpstreamUnsupported) {
+			sres.Message = fmt.Sprintf("merge-upstream requires GitHub Enterprise Server %s or later, server is %s",
+				MergeUpstreamMinVersion, api.ServerVersion())
 		}
 
-		if len(repos) == 0 {
-			break
-		}
+		return &sres, sres.Err
+	}
 
-		for _, repo := range repos {
-			if *repo.Fork {
-				merr := api.MergeUpstreamFork(ctx, *repo.Owner.Login, *repo.Name, *repo.DefaultBranch, verboseFlag)
-				if merr != nil {
-					return fmt.Errorf("MergeUpstreamFork error: %w", merr)
-				}
-			} else if verboseFlag || debugFlag {
-				fmt.Printf("-> Repo '%s/%s %s' is not a fork, skipping...\n", *repo.Owner.Login, *repo.Name, *repo.DefaultBranch)
-			}
-		}
+	sres.MergeType = res.GetMergeType()
+	sres.Message = res.GetMessage()
+
+	return &sres, nil
+}
 
-		page++
+// errorStatus extracts the HTTP status code and GitHub message from a go-github error.
+func errorStatus(err error) (int, string) {
+	if err == nil {
+		return 0, ""
 	}
 
-	return nil
+	var eresp *github.ErrorResponse
+	if errors.As(err, &eresp) && eresp.Response != nil {
+		return eresp.Response.StatusCode, eresp.Message
+	}
+
+	return 0, err.Error()
 }
@@ -501,7 +501,7 @@ func TestSyncForksSuccessNoUpdate(t *testing.T) {
 	TestSyncForksSuccessNoUpdategetRepositoriesHasFired = false //nolint:gochecknoglobals,nolintlint,lll  // This is a test variable
 
 	// setup for client.Repositories.List(ctx, user, opts)
-	userRepoListURL := fmt.Sprintf("/users/%s/repos", owner)
+	userRepoListURL := "/user/repos"
 	srvr.Mux.HandleFunc(userRepoListURL, func(wtr http.ResponseWriter, req *http.Request) {
 		rlo := new(github.RepositoryListOptions)
 		json.NewDecoder(req.Body).Decode(rlo) //nolint:errcheck  // We don't care about the error here
@@ -532,7 +532,7 @@ func TestSyncForksSuccessNoUpdate(t *testing.T) {
 		t.Errorf("githubapi.NewGitHubAPI error: %v", nerr)
 	}
 
-	err := gha.SyncForks(ctx, "", true, false)
+	_, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{Verbose: true})
 	if err != nil {
 		t.Errorf("githubapi.SyncForks returned error: %v", err)
 	}
@@ -556,7 +556,7 @@ func TestSyncForksBadUserName(t *testing.T) {
 		t.Errorf("githubapi.NewGitHubAPI error: %v", nerr)
 	}
 
-	err := gha.SyncForks(ctx, "%", true, false)
+	_, err := gha.SyncForks(ctx, "%", githubapi.SyncOptions{Verbose: true})
 	if err == nil {
 		t.Errorf("githubapi.SyncForks should have returned an error")
 	} else if strings.Compare(err.Error(), want.Error()) != 0 {
@@ -583,7 +583,7 @@ func TestSyncForksBadGet(t *testing.T) {
 	})
 
 	// setup for client.Repositories.List(ctx, user, opts)
-	userRepoListURL := fmt.Sprintf("/users/%s/repos", login)
+	userRepoListURL := "/user/repos"
 	srvr.Mux.HandleFunc(userRepoListURL, func(wtr http.ResponseWriter, req *http.Request) {
 		rlo := new(github.RepositoryListOptions)
 		json.NewDecoder(req.Body).Decode(rlo) //nolint:errcheck  // We don't care about the error here
@@ -598,7 +598,7 @@ func TestSyncForksBadGet(t *testing.T) {
 		t.Errorf("githubapi.NewGitHubAPI error: %v", nerr)
 	}
 
-	err := gha.SyncForks(ctx, "", true, false)
+	_, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{Verbose: true})
 	if err == nil {
 		t.Errorf("githubapi.SyncForks should have returned an error")
 	}
@@ -633,7 +633,7 @@ func TestSyncForksBadMerge(t *testing.T) {
 	TestSyncForksBadMergegetRepositoriesHasFired = false
 
 	// setup for client.Repositories.List(ctx, user, opts)
-	userRepoListURL := fmt.Sprintf("/users/%s/repos", login)
+	userRepoListURL := "/user/repos"
 	srvr.Mux.HandleFunc(userRepoListURL, func(wtr http.ResponseWriter, req *http.Request) {
 		rlo := new(github.RepositoryListOptions)
 		json.NewDecoder(req.Body).Decode(rlo) //nolint:errcheck  // We don't care about the error here
@@ -663,7 +663,7 @@ func TestSyncForksBadMerge(t *testing.T) {
 		t.Errorf("githubapi.NewGitHubAPI error: %v", nerr)
 	}
 
-	err := gha.SyncForks(ctx, "", true, false)
+	_, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{Verbose: true})
 	if err == nil {
 		t.Errorf("githubapi.SyncForks should have returned an error")
 	}
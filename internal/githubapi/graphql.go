@@ -0,0 +1,251 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v53/github"
+)
+
+const (
+	// DiscoveryREST lists the forks page by page with the REST API, it is the default.
+	DiscoveryREST = "rest"
+
+	// DiscoveryGraphQL lists only the forks, with their parent and branch heads, with the GraphQL API.
+	DiscoveryGraphQL = "graphql"
+
+	graphQLPageSize = 100
+)
+
+// forksQuery lists the forks of an account with everything the filters need, and the default branch
+// heads of fork and parent so forks already level with their parent need no merge-upstream call.
+const forksQuery = `query($login: String!, $cursor: String, $affiliations: [RepositoryAffiliation], $first: Int!) {
+  repositoryOwner(login: $login) {
+    repositories(first: $first, after: $cursor, isFork: true, ownerAffiliations: $affiliations,
+        orderBy: {field: NAME, direction: ASC}) {
+      pageInfo { hasNextPage endCursor }
+      nodes {
+        name
+        owner { login }
+        isArchived
+        visibility
+        viewerPermission
+        pushedAt
+        primaryLanguage { name }
+        repositoryTopics(first: 100) { nodes { topic { name } } }
+        defaultBranchRef { name target { oid } }
+        parent { name owner { login } defaultBranchRef { name target { oid } } }
+      }
+    }
+  }
+}`
+
+// ValidDiscovery reports whether name is a known fork discovery backend.
+func ValidDiscovery(name string) bool {
+	return name == DiscoveryREST || name == DiscoveryGraphQL
+}
+
+type graphQLRequest struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables"`
+}
+
+type graphQLOwner struct {
+	Login string `json:"login"`
+}
+
+type graphQLRef struct {
+	Name   string `json:"name"`
+	Target struct {
+		OID string `json:"oid"`
+	} `json:"target"`
+}
+
+type graphQLRepo struct {
+	Name             string       `json:"name"`
+	Owner            graphQLOwner `json:"owner"`
+	IsArchived       bool         `json:"isArchived"`
+	Visibility       string       `json:"visibility"`
+	ViewerPermission string       `json:"viewerPermission"`
+	PushedAt         *time.Time   `json:"pushedAt"`
+	PrimaryLanguage  *struct {
+		Name string `json:"name"`
+	} `json:"primaryLanguage"`
+	RepositoryTopics struct {
+		Nodes []struct {
+			Topic struct {
+				Name string `json:"name"`
+			} `json:"topic"`
+		} `json:"nodes"`
+	} `json:"repositoryTopics"`
+	DefaultBranchRef *graphQLRef `json:"defaultBranchRef"`
+	Parent           *struct {
+		Name             string       `json:"name"`
+		Owner            graphQLOwner `json:"owner"`
+		DefaultBranchRef *graphQLRef  `json:"defaultBranchRef"`
+	} `json:"parent"`
+}
+
+type graphQLRepos struct {
+	PageInfo struct {
+		HasNextPage bool   `json:"hasNextPage"`
+		EndCursor   string `json:"endCursor"`
+	} `json:"pageInfo"`
+	Nodes []*graphQLRepo `json:"nodes"`
+}
+
+type graphQLForksResponse struct {
+	Data struct {
+		RepositoryOwner *struct {
+			Repositories graphQLRepos `json:"repositories"`
+		} `json:"repositoryOwner"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// repository converts the node into the REST form the filters work on.
+func (node *graphQLRepo) repository() *github.Repository {
+	//nolint:exhaustruct // only the fields the filters read are known
+	repo := github.Repository{
+		Name:       github.String(node.Name),
+		Owner:      &github.User{Login: github.String(node.Owner.Login)},
+		Fork:       github.Bool(true),
+		Archived:   github.Bool(node.IsArchived),
+		Visibility: github.String(strings.ToLower(node.Visibility)),
+		Private:    github.Bool(node.Visibility != "PUBLIC"),
+		Topics:     make([]string, 0, len(node.RepositoryTopics.Nodes)),
+	}
+
+	if node.PushedAt != nil {
+		repo.PushedAt = &github.Timestamp{Time: *node.PushedAt}
+	}
+
+	if node.PrimaryLanguage != nil {
+		repo.Language = github.String(node.PrimaryLanguage.Name)
+	}
+
+	for _, topic := range node.RepositoryTopics.Nodes {
+		repo.Topics = append(repo.Topics, topic.Topic.Name)
+	}
+
+	if node.DefaultBranchRef != nil {
+		repo.DefaultBranch = github.String(node.DefaultBranchRef.Name)
+	}
+
+	if len(node.ViewerPermission) > 0 {
+		perm := node.ViewerPermission
+		repo.Permissions = map[string]bool{
+			"admin": perm == "ADMIN",
+			"push":  perm == "ADMIN" || perm == "MAINTAIN" || perm == "WRITE",
+			"pull":  true,
+		}
+	}
+
+	if node.Parent != nil {
+		//nolint:exhaustruct // only the name is known
+		repo.Parent = &github.Repository{
+			Name:  github.String(node.Parent.Name),
+			Owner: &github.User{Login: github.String(node.Parent.Owner.Login)},
+		}
+	}
+
+	return &repo
+}
+
+// level returns the head both the fork's and its parent's default branch point at, empty when they
+// differ, have different names or are unknown.
+func (node *graphQLRepo) level() string {
+	if node.DefaultBranchRef == nil || node.Parent == nil || node.Parent.DefaultBranchRef == nil {
+		return ""
+	}
+
+	fork, parent := node.DefaultBranchRef, node.Parent.DefaultBranchRef
+	if fork.Name != parent.Name || len(fork.Target.OID) == 0 || fork.Target.OID != parent.Target.OID {
+		return ""
+	}
+
+	return fork.Target.OID
+}
+
+// graphQLURL returns the GraphQL endpoint belonging to the REST base URL, /api/graphql on GitHub
+// Enterprise Server.
+func (api *GitHubAPI) graphQLURL() string {
+	base := *api.Client.BaseURL
+
+	if strings.HasSuffix(base.Path, "/api/v3/") {
+		base.Path = strings.TrimSuffix(base.Path, "v3/") + "graphql"
+
+		return base.String()
+	}
+
+	return base.ResolveReference(&url.URL{Path: "graphql"}).String() //nolint:exhaustruct // relative path only
+}
+
+// listForksPage returns the page of forks of src after cursor, the first page when cursor is empty.
+func (api *GitHubAPI) listForksPage(ctx context.Context, src forkSource, cursor string) (*graphQLRepos, error) {
+	vars := map[string]any{"login": src.owner, "first": graphQLPageSize, "cursor": nil, "affiliations": nil}
+
+	if len(cursor) > 0 {
+		vars["cursor"] = cursor
+	}
+
+	// users list the forks they own, like the REST listing, organizations every fork they hold
+	if !src.org {
+		vars["affiliations"] = []string{"OWNER"}
+	}
+
+	out, _, err := withRateLimit(ctx, api, func() (*graphQLForksResponse, *github.Response, error) {
+		req, err := api.Client.NewRequest(http.MethodPost, api.graphQLURL(),
+			&graphQLRequest{Query: forksQuery, Variables: vars})
+		if err != nil {
+			return nil, nil, fmt.Errorf("api.client.NewRequest error: %w", err)
+		}
+
+		var out graphQLForksResponse
+
+		resp, err := api.Client.Do(ctx, req, &out)
+
+		return &out, resp, err //nolint:wrapcheck // rate limit errors are matched by withRateLimit
+	})
+	if err != nil {
+		return nil, fmt.Errorf("api.client.Do graphql error: %w", err)
+	}
+
+	if len(out.Errors) > 0 {
+		return nil, fmt.Errorf("graphql error: %s", out.Errors[0].Message)
+	}
+
+	if out.Data.RepositoryOwner == nil {
+		return nil, fmt.Errorf("graphql error: no account %s", src.owner)
+	}
+
+	return &out.Data.RepositoryOwner.Repositories, nil
+}
+
+// listSourceGraphQL queues every fork of src, listed with the GraphQL API.
+func (api *GitHubAPI) listSourceGraphQL(ctx context.Context, src forkSource, queue *forkQueue) error {
+	for cursor := ""; ; {
+		page, err := api.listForksPage(ctx, src, cursor)
+		if err != nil {
+			return err
+		}
+
+		for _, node := range page.Nodes {
+			if qerr := queue.add(ctx, node.repository(), node.level()); qerr != nil {
+				return qerr
+			}
+		}
+
+		if !page.PageInfo.HasNextPage || len(page.PageInfo.EndCursor) == 0 {
+			return nil
+		}
+
+		cursor = page.PageInfo.EndCursor
+	}
+}
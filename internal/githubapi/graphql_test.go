@@ -0,0 +1,174 @@
+package githubapi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	nethttptest "net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/http/httptest"
+	"github.com/stretchr/testify/assert"
+)
+
+// graphQLFork returns a forks query node of syncTestOwner whose main branch is at head and whose
+// parent's main branch is at parentHead.
+func graphQLFork(name string, head string, parentHead string, permission string) string {
+	return fmt.Sprintf(`{"name":%q,"owner":{"login":%q},"isArchived":false,"visibility":"PUBLIC",
+		"viewerPermission":%q,"pushedAt":"2024-01-02T03:04:05Z","primaryLanguage":{"name":"Go"},
+		"repositoryTopics":{"nodes":[{"topic":{"name":"cli"}}]},
+		"defaultBranchRef":{"name":"main","target":{"oid":%q}},
+		"parent":{"name":%q,"owner":{"login":"upstream"},"defaultBranchRef":{"name":"main","target":{"oid":%q}}}}`,
+		name, syncTestOwner, permission, head, name, parentHead)
+}
+
+func TestSyncForksGraphQL(t *testing.T) {
+	pages := map[string]string{
+		"":   `{"hasNextPage":true,"endCursor":"c1"}`,
+		"c1": `{"hasNextPage":false,"endCursor":"c2"}`,
+	}
+	nodes := map[string][]string{
+		"":   {graphQLFork("level", "aaa", "aaa", "ADMIN"), graphQLFork("behind", "bbb", "ccc", "WRITE")},
+		"c1": {graphQLFork("readonly", "ddd", "eee", "READ")},
+	}
+
+	merged := make([]string, 0)
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/v3/user", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(wtr, `{"login":%q}`, syncTestOwner)
+	})
+
+	mux.HandleFunc("/api/v3/user/repos", func(wtr http.ResponseWriter, req *http.Request) {
+		t.Errorf("unexpected REST listing %s", req.URL.Path)
+		fmt.Fprint(wtr, `[]`)
+	})
+
+	mux.HandleFunc("/api/graphql", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodPost)
+
+		var body struct {
+			Query     string         `json:"query"`
+			Variables map[string]any `json:"variables"`
+		}
+
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Errorf("json.Decode returned error: %v", err)
+		}
+
+		assert.Contains(t, body.Query, "isFork: true")
+		assert.Equal(t, syncTestOwner, body.Variables["login"])
+		assert.Equal(t, []any{"OWNER"}, body.Variables["affiliations"])
+
+		cursor, _ := body.Variables["cursor"].(string)
+		fmt.Fprintf(wtr, `{"data":{"repositoryOwner":{"repositories":{"pageInfo":%s,"nodes":[%s]}}}}`,
+			pages[cursor], strings.Join(nodes[cursor], ","))
+	})
+
+	mux.HandleFunc("/api/v3/repos/", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodPost)
+		merged = append(merged, req.URL.Path)
+		fmt.Fprint(wtr, `{"message":"merged","merge_type":"fast-forward"}`)
+	})
+
+	srvr := nethttptest.NewServer(mux)
+	defer srvr.Close()
+
+	ctx := context.Background()
+
+	gha, err := githubapi.NewGitHubAPIFromSource(ctx, githubapi.StaticTokenSource("token", "test"),
+		srvr.URL+"/api/v3/", "")
+	if err != nil {
+		t.Fatalf("githubapi.NewGitHubAPIFromSource returned error: %v", err)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	rpt, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{Discovery: githubapi.DiscoveryGraphQL, Verbose: true})
+	if !assert.NoError(t, err) || !assert.Len(t, rpt.Results, 2) {
+		return
+	}
+
+	assert.Equal(t, []string{"/api/v3/repos/" + syncTestOwner + "/behind/merge-upstream"}, merged,
+		"the fork level with its parent needs no merge-upstream")
+
+	assert.Equal(t, "level", rpt.Results[0].Repo)
+	assert.Equal(t, githubapi.MergeTypeNone, rpt.Results[0].MergeType)
+	assert.Equal(t, "This branch is not behind the upstream upstream:main.", rpt.Results[0].Message)
+	assert.Equal(t, "aaa", rpt.Results[0].ForkSHA)
+	assert.Equal(t, "behind", rpt.Results[1].Repo)
+	assert.Equal(t, "fast-forward", rpt.Results[1].MergeType)
+	assert.Contains(t, out.String(), "readonly main' is not writable with this token")
+}
+
+func TestSyncForksGraphQLFallback(t *testing.T) {
+	srvr := newSyncTestServer(t, 2, func(wtr http.ResponseWriter, _ *http.Request, repo string) {
+		fmt.Fprint(wtr, `{"message":"merged `+repo+`","merge_type":"merge"}`)
+	})
+	defer srvr.Close()
+
+	srvr.Mux.HandleFunc("/graphql", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `{"errors":[{"message":"Resource not accessible by integration"}]}`)
+	})
+
+	ctx := context.Background()
+
+	gha, err := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if err != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", err)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	rpt, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{Discovery: githubapi.DiscoveryGraphQL})
+	if assert.NoError(t, err) {
+		assert.Len(t, rpt.Results, 2)
+	}
+
+	assert.Contains(t, out.String(),
+		"-> GraphQL discovery failed (graphql error: Resource not accessible by integration), listing forks with REST...")
+}
+
+func TestSyncForksGraphQLUnavailable(t *testing.T) {
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+	defer srvr.Close()
+
+	srvr.Mux.HandleFunc("/user", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(wtr, `{"login":%q}`, syncTestOwner)
+	})
+
+	srvr.Mux.HandleFunc("/user/repos", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `[]`)
+	})
+
+	ctx := context.Background()
+
+	gha, err := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if err != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", err)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	_, err = gha.SyncForks(ctx, "", githubapi.SyncOptions{Discovery: githubapi.DiscoveryGraphQL})
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "404")
+	assert.Contains(t, out.String(), "listing forks with REST")
+}
+
+func TestValidDiscovery(t *testing.T) {
+	assert.True(t, githubapi.ValidDiscovery(githubapi.DiscoveryREST))
+	assert.True(t, githubapi.ValidDiscovery(githubapi.DiscoveryGraphQL))
+	assert.False(t, githubapi.ValidDiscovery("soap"))
+}
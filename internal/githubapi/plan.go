@@ -0,0 +1,130 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// MergeTypeFastForward is reported when the fork branch can move straight to the upstream head.
+	MergeTypeFastForward = "fast-forward"
+
+	// MergeTypeMerge is reported when a merge commit is required to bring in upstream changes.
+	MergeTypeMerge = "merge"
+
+	// MergeTypeDiverged is reported by a dry-run when the branches share no history, merge-upstream
+	// would be rejected for such a fork.
+	MergeTypeDiverged = "diverged"
+
+	// MergeTypeCreate is reported by a dry-run for an upstream branch that would be created in the fork.
+	MergeTypeCreate = "create"
+
+	// MergeTypeCreated is reported when a missing upstream branch was created in the fork.
+	MergeTypeCreated = "created"
+
+	compareStatusIdentical = "identical"
+	compareStatusAhead     = "ahead"
+	compareStatusBehind    = "behind"
+	compareStatusDiverged  = "diverged"
+
+	// noCommonAncestor is part of the message of the 404 GitHub answers when the compared branches share no
+	// history, any other 404 means a repository or branch is missing.
+	noCommonAncestor = "No common ancestor"
+)
+
+// upstreamBranch returns the upstream repository and branch a fork branch tracks. The fork's
+// default branch tracks the parent's default branch, any other branch tracks the one of the same name.
+func (api *GitHubAPI) upstreamBranch(ctx context.Context, owner string, name string,
+	branch string) (string, string, string, error) {
+	repo, err := api.GetRepository(ctx, owner, name)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	parent := repo.GetParent()
+	if parent == nil {
+		return "", "", "", fmt.Errorf("repository %s/%s has no parent", owner, name)
+	}
+
+	upBranch := branch
+	if branch == repo.GetDefaultBranch() && len(parent.GetDefaultBranch()) > 0 {
+		upBranch = parent.GetDefaultBranch()
+	}
+
+	return parent.GetOwner().GetLogin(), parent.GetName(), upBranch, nil
+}
+
+// PlanFork uses the compare API to work out what merge-upstream would do to the fork branch
+// without changing anything. The result's MergeType holds the planned action.
+func (api *GitHubAPI) PlanFork(ctx context.Context, owner string, name string, branch string) (*SyncResult, error) {
+	res := SyncResult{
+		Owner:   owner,
+		Repo:    name,
+		Branch:  branch,
+		Planned: true,
+	}
+
+	upOwner, upName, upBranch, err := api.upstreamBranch(ctx, owner, name, branch)
+	if err != nil {
+		res.Status, res.Message = errorStatus(err)
+		res.Err = fmt.Errorf("upstreamBranch error: %w", err)
+
+		return &res, res.Err
+	}
+
+	res.UpstreamOwner = upOwner
+	res.UpstreamRepo = upName
+	res.UpstreamBranch = upBranch
+
+	forkSHA, err := api.GetBranchSHA(ctx, owner, name, branch)
+	if err != nil {
+		res.Status, res.Message = errorStatus(err)
+		res.Err = fmt.Errorf("GetBranchSHA error: %w", err)
+
+		return &res, res.Err
+	}
+
+	res.ForkSHA = forkSHA
+
+	comp, err := api.CompareCommits(ctx, upOwner, upName, upBranch, owner+":"+branch)
+	if err != nil {
+		if status, message := errorStatus(err); status == http.StatusNotFound && strings.Contains(message, noCommonAncestor) {
+			res.MergeType = MergeTypeDiverged
+			res.Message = fmt.Sprintf("has diverged from upstream %s (no common history)", res.UpstreamFullName())
+
+			return &res, nil
+		}
+
+		res.Status, res.Message = errorStatus(err)
+		res.Err = fmt.Errorf("CompareCommits error: %w", err)
+
+		return &res, res.Err
+	}
+
+	res.UpstreamSHA = comp.GetBaseCommit().GetSHA()
+	res.AheadBy = comp.GetAheadBy()
+	res.BehindBy = comp.GetBehindBy()
+
+	switch comp.GetStatus() {
+	case compareStatusIdentical, compareStatusAhead:
+		res.MergeType = MergeTypeNone
+		res.Message = fmt.Sprintf("is up to date with upstream %s", res.UpstreamFullName())
+	case compareStatusBehind:
+		res.MergeType = MergeTypeFastForward
+		res.Message = fmt.Sprintf("can fast-forward to upstream %s (behind by %d)",
+			res.UpstreamFullName(), res.BehindBy)
+	case compareStatusDiverged:
+		res.MergeType = MergeTypeMerge
+		res.Message = fmt.Sprintf("needs a merge commit with upstream %s (ahead by %d, behind by %d)",
+			res.UpstreamFullName(), res.AheadBy, res.BehindBy)
+	default:
+		res.Err = fmt.Errorf("unexpected compare status %q", comp.GetStatus())
+		res.Message = res.Err.Error()
+
+		return &res, res.Err
+	}
+
+	return &res, nil
+}
@@ -0,0 +1,181 @@
+package githubapi_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/http/httptest"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	planTestUpstream = "Test_upstream"
+	planTestForkSHA  = "f0f0f0"
+	planTestUpSHA    = "a1a1a1"
+	planTestMissing  = "missing"
+)
+
+// handlePlanRepo registers the repository, ref and compare endpoints PlanFork uses for owner/name,
+// compare answers with compareStatus, with the 404 of branches without common history when compareStatus is
+// empty or with a plain 404 when it is planTestMissing.
+func handlePlanRepo(t *testing.T, srvr *httptest.Server, owner string, name string, compareStatus string) {
+	t.Helper()
+
+	srvr.Mux.HandleFunc(fmt.Sprintf("/repos/%s/%s", owner, name), func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodGet)
+		fmt.Fprintf(wtr, `{"name":%q,"owner":{"login":%q},"fork":true,"default_branch":"main",`+
+			`"parent":{"name":%q,"owner":{"login":%q},"default_branch":"trunk"}}`, name, owner, name, planTestUpstream)
+	})
+
+	srvr.Mux.HandleFunc(fmt.Sprintf("/repos/%s/%s/git/ref/heads/main", owner, name),
+		func(wtr http.ResponseWriter, req *http.Request) {
+			testMethod(t, req, http.MethodGet)
+			fmt.Fprint(wtr, `{"ref":"refs/heads/main","object":{"type":"commit","sha":"`+planTestForkSHA+`"}}`)
+		})
+
+	srvr.Mux.HandleFunc(fmt.Sprintf("/repos/%s/%s/compare/trunk...%s:main", planTestUpstream, name, owner),
+		func(wtr http.ResponseWriter, req *http.Request) {
+			testMethod(t, req, http.MethodGet)
+
+			switch compareStatus {
+			case "":
+				wtr.WriteHeader(http.StatusNotFound)
+				fmt.Fprintf(wtr, `{"message":"No common ancestor between trunk and %s:main."}`, owner)
+
+				return
+			case planTestMissing:
+				wtr.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(wtr, `{"message":"Not Found"}`)
+
+				return
+			}
+
+			fmt.Fprintf(wtr, `{"status":%q,"ahead_by":2,"behind_by":3,"base_commit":{"sha":%q}}`,
+				compareStatus, planTestUpSHA)
+		})
+}
+
+func TestPlanFork(t *testing.T) {
+	tests := []struct {
+		status      string
+		wantType    string
+		wantMessage string
+	}{
+		{status: "identical", wantType: githubapi.MergeTypeNone, wantMessage: "is up to date"},
+		{status: "ahead", wantType: githubapi.MergeTypeNone, wantMessage: "is up to date"},
+		{status: "behind", wantType: githubapi.MergeTypeFastForward, wantMessage: "can fast-forward"},
+		{status: "diverged", wantType: githubapi.MergeTypeMerge, wantMessage: "needs a merge commit"},
+		{status: "", wantType: githubapi.MergeTypeDiverged, wantMessage: "has diverged"},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.wantType+"_"+tst.status, func(t *testing.T) {
+			srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+			if serr != nil {
+				t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+			}
+			defer srvr.Close()
+
+			handlePlanRepo(t, srvr, "Test_owner", "Test_repo", tst.status)
+
+			ctx := context.Background()
+			gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+			if nerr != nil {
+				t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+			}
+
+			res, err := gha.PlanFork(ctx, "Test_owner", "Test_repo", "main")
+			if err != nil {
+				t.Fatalf("githubapi.PlanFork returned error: %v", err)
+			}
+
+			assert.True(t, res.Planned)
+			assert.Equal(t, tst.wantType, res.MergeType)
+			assert.Contains(t, res.Message, tst.wantMessage)
+			assert.Equal(t, planTestUpstream+"/Test_repo:trunk", res.UpstreamFullName())
+			assert.Equal(t, planTestForkSHA, res.ForkSHA)
+		})
+	}
+}
+
+func TestPlanForkMissingBranch(t *testing.T) {
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+	defer srvr.Close()
+
+	handlePlanRepo(t, srvr, "Test_owner", "Test_repo", planTestMissing)
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	res, err := gha.PlanFork(ctx, "Test_owner", "Test_repo", "main")
+
+	assert.ErrorContains(t, err, "CompareCommits error")
+	assert.Equal(t, http.StatusNotFound, res.Status)
+	assert.Empty(t, res.MergeType, "a missing branch is not reported as diverged")
+}
+
+func TestPlanForkNotAFork(t *testing.T) {
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+	defer srvr.Close()
+
+	srvr.Mux.HandleFunc("/repos/Test_owner/Test_repo", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `{"name":"Test_repo","fork":false}`)
+	})
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	res, err := gha.PlanFork(ctx, "Test_owner", "Test_repo", "main")
+	if err == nil {
+		t.Fatalf("githubapi.PlanFork should have returned an error")
+	}
+
+	assert.Equal(t, err, res.Err)
+}
+
+func TestSyncForksDryRun(t *testing.T) {
+	srvr := newSyncTestServer(t, 1, func(_ http.ResponseWriter, _ *http.Request, _ string) {
+		t.Errorf("merge-upstream should not be called during a dry-run")
+	})
+	defer srvr.Close()
+
+	handlePlanRepo(t, srvr, syncTestOwner, "repo00", "behind")
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	rpt, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("githubapi.SyncForks returned error: %v", err)
+	}
+
+	if assert.Len(t, rpt.Results, 1) {
+		assert.Equal(t, githubapi.MergeTypeFastForward, rpt.Results[0].MergeType)
+		assert.Equal(t, planTestUpSHA, rpt.Results[0].UpstreamSHA)
+	}
+
+	assert.Contains(t, out.String(), "[dry-run] can fast-forward to upstream Test_upstream/repo00:trunk (behind by 3)")
+}
@@ -0,0 +1,295 @@
+package githubapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// PlanVersion is the format version written to plan files.
+const PlanVersion = 1
+
+// ErrStalePlan is returned by ApplyPlan when a fork or upstream branch moved since the plan was made.
+var ErrStalePlan = errors.New("plan is stale")
+
+// Plan is the reviewed output of a dry-run that ApplyPlan can later execute.
+type Plan struct {
+	Version   int          `json:"version"`
+	CreatedAt time.Time    `json:"created_at"`
+	Entries   []*PlanEntry `json:"entries"`
+}
+
+// PlanEntry is the intended action for a single fork branch.
+type PlanEntry struct {
+	Owner          string `json:"owner"`
+	Repo           string `json:"repo"`
+	Branch         string `json:"branch"`
+	UpstreamOwner  string `json:"upstream_owner"`
+	UpstreamRepo   string `json:"upstream_repo"`
+	UpstreamBranch string `json:"upstream_branch"`
+	UpstreamSHA    string `json:"upstream_sha"`
+	ForkSHA        string `json:"fork_sha"`
+	Action         string `json:"action"`
+}
+
+// Plan converts the planned results of a dry-run into a Plan, failed results are left out.
+func (rpt *SyncReport) Plan() *Plan {
+	plan := Plan{
+		Version:   PlanVersion,
+		CreatedAt: time.Now().UTC(),
+		Entries:   make([]*PlanEntry, 0, len(rpt.Results)),
+	}
+
+	for _, res := range rpt.Results {
+		if !res.Planned || res.Err != nil {
+			continue
+		}
+
+		plan.Entries = append(plan.Entries, &PlanEntry{
+			Owner:          res.Owner,
+			Repo:           res.Repo,
+			Branch:         res.Branch,
+			UpstreamOwner:  res.UpstreamOwner,
+			UpstreamRepo:   res.UpstreamRepo,
+			UpstreamBranch: res.UpstreamBranch,
+			UpstreamSHA:    res.UpstreamSHA,
+			ForkSHA:        res.ForkSHA,
+			Action:         res.MergeType,
+		})
+	}
+
+	return &plan
+}
+
+// Actionable reports whether applying the entry changes the fork.
+func (entry *PlanEntry) Actionable() bool {
+	switch entry.Action {
+	case MergeTypeFastForward, MergeTypeMerge, MergeTypeCreate, MergeTypeReset, MergeTypeTagCreate, MergeTypeTagDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// tag reports whether the entry creates or deletes a tag rather than changing a branch.
+func (entry *PlanEntry) tag() bool {
+	return entry.Action == MergeTypeTagCreate || entry.Action == MergeTypeTagDelete
+}
+
+// WritePlanFile writes the plan as indented JSON.
+func WritePlanFile(path string, plan *Plan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent error: %w", err)
+	}
+
+	if werr := os.WriteFile(path, append(data, '\n'), 0o600); werr != nil {
+		return fmt.Errorf("os.WriteFile error: %w", werr)
+	}
+
+	return nil
+}
+
+// ReadPlanFile reads a plan previously written by WritePlanFile.
+func ReadPlanFile(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile error: %w", err)
+	}
+
+	plan := Plan{}
+	if uerr := json.Unmarshal(data, &plan); uerr != nil {
+		return nil, fmt.Errorf("json.Unmarshal error: %w", uerr)
+	}
+
+	if plan.Version != PlanVersion {
+		return nil, fmt.Errorf("unsupported plan version %d, want %d", plan.Version, PlanVersion)
+	}
+
+	return &plan, nil
+}
+
+// ApplyPlan merges upstream into, resets or creates every actionable fork branch of the plan and creates or
+// deletes its planned tags. Every branch and tag is checked against the SHAs recorded in the plan first and
+// nothing is changed if any of them moved. Each entry is checked again right before it is applied and fails
+// if it moved meanwhile.
+func (api *GitHubAPI) ApplyPlan(ctx context.Context, plan *Plan, opts SyncOptions) (*SyncReport, error) {
+	stale := make([]string, 0)
+
+	for _, entry := range plan.Entries {
+		if !entry.Actionable() {
+			continue
+		}
+
+		moved, err := api.planEntryMoved(ctx, entry)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(moved) > 0 {
+			stale = append(stale, fmt.Sprintf("%s/%s %s: %s", entry.Owner, entry.Repo, entry.Branch, moved))
+		}
+	}
+
+	if len(stale) > 0 {
+		return nil, fmt.Errorf("%w, re-run the dry-run and review again:\n  %s", ErrStalePlan, strings.Join(stale, "\n  "))
+	}
+
+	rpt := SyncReport{
+		Results: make([]*SyncResult, 0, len(plan.Entries)),
+	}
+
+	for _, entry := range plan.Entries {
+		if !entry.Actionable() {
+			if opts.Verbose {
+				api.printf("-> Repo '%s/%s %s' planned action is %s, skipping...\n",
+					entry.Owner, entry.Repo, entry.Branch, entry.Action)
+			}
+
+			continue
+		}
+
+		res := api.recheckPlanEntry(ctx, entry)
+		if res == nil {
+			res = api.applyPlanEntry(ctx, entry)
+		}
+
+		if opts.RecordHistory {
+			api.noteHead(ctx, res)
+		}
+
+		rpt.Results = append(rpt.Results, res)
+		res.write(api, opts.Verbose)
+
+		if res.Err != nil && !opts.ContinueOnError {
+			api.writeBackups(&rpt)
+
+			return &rpt, fmt.Errorf("applyPlanEntry error: %w", res.Err)
+		}
+	}
+
+	api.writeBackups(&rpt)
+
+	if ferr := rpt.Err(); ferr != nil {
+		api.writeSummary(&rpt)
+
+		return &rpt, ferr
+	}
+
+	return &rpt, nil
+}
+
+// recheckPlanEntry verifies the entry again right before it is applied, as earlier entries take time and
+// the branches may move meanwhile. It returns the failed result of an entry that moved, nil when the
+// entry can be applied.
+func (api *GitHubAPI) recheckPlanEntry(ctx context.Context, entry *PlanEntry) *SyncResult {
+	moved, err := api.planEntryMoved(ctx, entry)
+	if err == nil && len(moved) == 0 {
+		return nil
+	}
+
+	res := entry.result()
+	if err != nil {
+		res.Status, res.Message = errorStatus(err)
+		res.Err = err
+	} else {
+		res.Message = moved
+		res.Err = fmt.Errorf("%w: %s", ErrStalePlan, moved)
+	}
+
+	return res
+}
+
+// applyPlanEntry performs the planned action of a single, already verified, entry. Resets, created branches
+// and created tags point at the upstream SHA recorded in the plan, not at the current upstream head.
+func (api *GitHubAPI) applyPlanEntry(ctx context.Context, entry *PlanEntry) *SyncResult {
+	res := entry.result()
+
+	switch entry.Action {
+	case MergeTypeTagCreate:
+		api.createForkTag(ctx, res)
+	case MergeTypeTagDelete:
+		api.deleteForkTag(ctx, res)
+	case MergeTypeReset:
+		api.resetToUpstream(ctx, res) //nolint:errcheck  // the error is recorded in res
+	case MergeTypeCreate:
+		api.createUpstreamBranch(ctx, res)
+	default:
+		res, _ = api.mergeUpstreamFork(ctx, entry.Owner, entry.Repo, entry.Branch)
+		res.ForkSHA = entry.ForkSHA
+	}
+
+	return res
+}
+
+// result returns the result of the entry before its action is performed.
+func (entry *PlanEntry) result() *SyncResult {
+	return &SyncResult{
+		Owner:          entry.Owner,
+		Repo:           entry.Repo,
+		Branch:         entry.Branch,
+		UpstreamOwner:  entry.UpstreamOwner,
+		UpstreamRepo:   entry.UpstreamRepo,
+		UpstreamBranch: entry.UpstreamBranch,
+		UpstreamSHA:    entry.UpstreamSHA,
+		ForkSHA:        entry.ForkSHA,
+	}
+}
+
+// planEntryMoved describes which side of the entry no longer matches the plan, empty when neither moved.
+// A branch or tag planned for creation must still be missing from the fork and a tag planned for deletion
+// must still be missing from upstream.
+func (api *GitHubAPI) planEntryMoved(ctx context.Context, entry *PlanEntry) (string, error) {
+	forkSHA, err := api.planRefSHA(ctx, entry, entry.Owner, entry.Repo, entry.Branch,
+		entry.Action == MergeTypeCreate || entry.Action == MergeTypeTagCreate)
+	if err != nil {
+		return "", err
+	}
+
+	upSHA, err := api.planRefSHA(ctx, entry, entry.UpstreamOwner, entry.UpstreamRepo, entry.UpstreamBranch,
+		entry.Action == MergeTypeTagDelete)
+	if err != nil {
+		return "", err
+	}
+
+	moved := make([]string, 0, 2)
+
+	if forkSHA != entry.ForkSHA {
+		moved = append(moved, fmt.Sprintf("fork moved %s -> %s", entry.ForkSHA, forkSHA))
+	}
+
+	if upSHA != entry.UpstreamSHA {
+		moved = append(moved, fmt.Sprintf("upstream moved %s -> %s", entry.UpstreamSHA, upSHA))
+	}
+
+	return strings.Join(moved, ", "), nil
+}
+
+// planRefSHA returns the SHA of the branch or, for a tag entry, the tag ref of the repository. A missing
+// ref is reported as an empty SHA when missing is allowed.
+func (api *GitHubAPI) planRefSHA(ctx context.Context, entry *PlanEntry, owner string, repo string, ref string,
+	missing bool) (string, error) {
+	var (
+		sha string
+		err error
+	)
+
+	if entry.tag() {
+		if sha, err = api.GetTagSHA(ctx, owner, repo, strings.TrimPrefix(ref, tagBranchPrefix)); err != nil {
+			err = fmt.Errorf("GetTagSHA error: %w", err)
+		}
+	} else if sha, err = api.GetBranchSHA(ctx, owner, repo, ref); err != nil {
+		err = fmt.Errorf("GetBranchSHA error: %w", err)
+	}
+
+	if status, _ := errorStatus(err); missing && status == http.StatusNotFound {
+		return "", nil
+	}
+
+	return sha, err
+}
@@ -0,0 +1,279 @@
+package githubapi_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/http/httptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func testPlan() *githubapi.Plan {
+	return &githubapi.Plan{
+		Version: githubapi.PlanVersion,
+		Entries: []*githubapi.PlanEntry{
+			{
+				Owner: "Test_owner", Repo: "repo_ff", Branch: "main",
+				UpstreamOwner: "Test_upstream", UpstreamRepo: "repo_ff", UpstreamBranch: "main",
+				UpstreamSHA: "up1", ForkSHA: "fork1", Action: githubapi.MergeTypeFastForward,
+			},
+			{
+				Owner: "Test_owner", Repo: "repo_none", Branch: "main",
+				UpstreamOwner: "Test_upstream", UpstreamRepo: "repo_none", UpstreamBranch: "main",
+				UpstreamSHA: "up2", ForkSHA: "up2", Action: githubapi.MergeTypeNone,
+			},
+		},
+	}
+}
+
+func handleRef(t *testing.T, srvr *httptest.Server, owner string, repo string, branch string, sha string) {
+	t.Helper()
+
+	srvr.Mux.HandleFunc(fmt.Sprintf("/repos/%s/%s/git/ref/heads/%s", owner, repo, branch),
+		func(wtr http.ResponseWriter, req *http.Request) {
+			testMethod(t, req, http.MethodGet)
+			fmt.Fprintf(wtr, `{"ref":"refs/heads/%s","object":{"type":"commit","sha":%q}}`, branch, sha)
+		})
+}
+
+func TestSyncReportPlan(t *testing.T) {
+	rpt := githubapi.SyncReport{
+		Results: []*githubapi.SyncResult{
+			{
+				Owner: "o", Repo: "a", Branch: "main", Planned: true, MergeType: githubapi.MergeTypeMerge,
+				UpstreamOwner: "u", UpstreamRepo: "a", UpstreamBranch: "main", UpstreamSHA: "1", ForkSHA: "2",
+			},
+			{Owner: "o", Repo: "b", Branch: "main", Planned: true, Err: fmt.Errorf("boom")},
+			{Owner: "o", Repo: "c", Branch: "main", MergeType: githubapi.MergeTypeNone},
+		},
+	}
+
+	plan := rpt.Plan()
+
+	assert.Equal(t, githubapi.PlanVersion, plan.Version)
+
+	if assert.Len(t, plan.Entries, 1) {
+		assert.Equal(t, githubapi.PlanEntry{
+			Owner: "o", Repo: "a", Branch: "main", UpstreamOwner: "u", UpstreamRepo: "a", UpstreamBranch: "main",
+			UpstreamSHA: "1", ForkSHA: "2", Action: githubapi.MergeTypeMerge,
+		}, *plan.Entries[0])
+	}
+}
+
+func TestPlanFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	want := testPlan()
+
+	if err := githubapi.WritePlanFile(path, want); err != nil {
+		t.Fatalf("WritePlanFile returned error: %v", err)
+	}
+
+	got, err := githubapi.ReadPlanFile(path)
+	if err != nil {
+		t.Fatalf("ReadPlanFile returned error: %v", err)
+	}
+
+	assert.Equal(t, want.Entries, got.Entries)
+}
+
+func TestReadPlanFileErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := githubapi.ReadPlanFile(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+
+	badVersion := filepath.Join(dir, "version.json")
+	if werr := os.WriteFile(badVersion, []byte(`{"version":99}`), 0o600); werr != nil {
+		t.Fatalf("os.WriteFile returned error: %v", werr)
+	}
+
+	_, err = githubapi.ReadPlanFile(badVersion)
+	assert.ErrorContains(t, err, "unsupported plan version 99")
+
+	badJSON := filepath.Join(dir, "bad.json")
+	if werr := os.WriteFile(badJSON, []byte(`{`), 0o600); werr != nil {
+		t.Fatalf("os.WriteFile returned error: %v", werr)
+	}
+
+	_, err = githubapi.ReadPlanFile(badJSON)
+	assert.Error(t, err)
+}
+
+func TestApplyPlanSuccess(t *testing.T) {
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+	defer srvr.Close()
+
+	handleRef(t, srvr, "Test_owner", "repo_ff", "main", "fork1")
+	handleRef(t, srvr, "Test_upstream", "repo_ff", "main", "up1")
+
+	merged := make([]string, 0)
+	srvr.Mux.HandleFunc("/repos/Test_owner/", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodPost)
+		merged = append(merged, req.URL.Path)
+		fmt.Fprint(wtr, `{"message":"Successfully fetched and fast-forwarded","merge_type":"fast-forward"}`)
+	})
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	gha.Out = &bytes.Buffer{}
+
+	rpt, err := gha.ApplyPlan(ctx, testPlan(), githubapi.SyncOptions{})
+	if err != nil {
+		t.Fatalf("githubapi.ApplyPlan returned error: %v", err)
+	}
+
+	assert.Equal(t, []string{"/repos/Test_owner/repo_ff/merge-upstream"}, merged)
+	assert.Len(t, rpt.Results, 1)
+}
+
+func TestApplyPlanTags(t *testing.T) {
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+	defer srvr.Close()
+
+	tagRef := func(path string, typ string, sha string) {
+		srvr.Mux.HandleFunc(path, func(wtr http.ResponseWriter, req *http.Request) {
+			testMethod(t, req, http.MethodGet)
+			fmt.Fprintf(wtr, `{"object":{"type":%q,"sha":%q}}`, typ, sha)
+		})
+	}
+
+	// the upstream v2 is an annotated tag, the plan records the commit it points at
+	tagRef("/repos/Test_upstream/repo_t/git/ref/tags/v2", "tag", "tag-object")
+	srvr.Mux.HandleFunc("/repos/Test_upstream/repo_t/git/tags/tag-object", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `{"sha":"tag-object","object":{"type":"commit","sha":"up-v2"}}`)
+	})
+	tagRef("/repos/Test_owner/repo_t/git/ref/tags/v0", "commit", "fork-v0")
+
+	changed := make([]string, 0)
+	srvr.Mux.HandleFunc("/repos/Test_owner/repo_t/git/refs", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodPost)
+
+		body, _ := io.ReadAll(req.Body)
+		changed = append(changed, "create "+string(body))
+		fmt.Fprint(wtr, `{"ref":"refs/tags/v2"}`)
+	})
+	srvr.Mux.HandleFunc("/repos/Test_owner/repo_t/git/refs/tags/v0", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodDelete)
+		changed = append(changed, "delete v0")
+		wtr.WriteHeader(http.StatusNoContent)
+	})
+
+	plan := githubapi.Plan{
+		Version: githubapi.PlanVersion,
+		Entries: []*githubapi.PlanEntry{
+			{
+				Owner: "Test_owner", Repo: "repo_t", Branch: "tags/v2",
+				UpstreamOwner: "Test_upstream", UpstreamRepo: "repo_t", UpstreamBranch: "tags/v2",
+				UpstreamSHA: "up-v2", Action: githubapi.MergeTypeTagCreate,
+			},
+			{
+				Owner: "Test_owner", Repo: "repo_t", Branch: "tags/v0",
+				UpstreamOwner: "Test_upstream", UpstreamRepo: "repo_t", UpstreamBranch: "tags/v0",
+				ForkSHA: "fork-v0", Action: githubapi.MergeTypeTagDelete,
+			},
+		},
+	}
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	gha.Out = &bytes.Buffer{}
+
+	rpt, err := gha.ApplyPlan(ctx, &plan, githubapi.SyncOptions{})
+	if !assert.NoError(t, err) || !assert.Len(t, rpt.Results, 2) {
+		return
+	}
+
+	assert.Equal(t, []string{`create {"ref":"refs/tags/v2","sha":"up-v2"}` + "\n", "delete v0"}, changed)
+	assert.Equal(t, githubapi.MergeTypeTagCreated, rpt.Results[0].MergeType)
+	assert.Equal(t, githubapi.MergeTypeTagDeleted, rpt.Results[1].MergeType)
+
+	// once created the tag is no longer missing from the fork, the plan is stale
+	tagRef("/repos/Test_owner/repo_t/git/ref/tags/v2", "commit", "up-v2")
+
+	_, err = gha.ApplyPlan(ctx, &plan, githubapi.SyncOptions{})
+	assert.ErrorIs(t, err, githubapi.ErrStalePlan)
+	assert.ErrorContains(t, err, "Test_owner/repo_t tags/v2: fork moved  -> up-v2")
+}
+
+func TestApplyPlanStale(t *testing.T) {
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+	defer srvr.Close()
+
+	handleRef(t, srvr, "Test_owner", "repo_ff", "main", "fork1")
+	handleRef(t, srvr, "Test_upstream", "repo_ff", "main", "up-moved")
+
+	srvr.Mux.HandleFunc("/repos/Test_owner/repo_ff/merge-upstream", func(_ http.ResponseWriter, _ *http.Request) {
+		t.Errorf("merge-upstream should not be called for a stale plan")
+	})
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	_, err := gha.ApplyPlan(ctx, testPlan(), githubapi.SyncOptions{})
+
+	assert.ErrorIs(t, err, githubapi.ErrStalePlan)
+	assert.ErrorContains(t, err, "upstream moved up1 -> up-moved")
+}
+
+func TestApplyPlanMovedMeanwhile(t *testing.T) {
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+	defer srvr.Close()
+
+	handleRef(t, srvr, "Test_owner", "repo_ff", "main", "fork1")
+
+	// upstream moves after the plan as a whole was verified
+	upstream := []string{"up1", "up-moved"}
+	srvr.Mux.HandleFunc("/repos/Test_upstream/repo_ff/git/ref/heads/main", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(wtr, `{"object":{"type":"commit","sha":%q}}`, upstream[0])
+		upstream = upstream[1:]
+	})
+
+	srvr.Mux.HandleFunc("/repos/Test_owner/repo_ff/merge-upstream", func(_ http.ResponseWriter, _ *http.Request) {
+		t.Errorf("merge-upstream should not be called for an entry that moved")
+	})
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	gha.Out = &bytes.Buffer{}
+
+	rpt, err := gha.ApplyPlan(ctx, testPlan(), githubapi.SyncOptions{})
+
+	assert.ErrorIs(t, err, githubapi.ErrStalePlan)
+	if assert.Len(t, rpt.Results, 1) {
+		assert.Equal(t, "upstream moved up1 -> up-moved", rpt.Results[0].Message)
+	}
+}
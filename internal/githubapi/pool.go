@@ -0,0 +1,339 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/go-github/v53/github"
+	"golang.org/x/oauth2"
+)
+
+const (
+	headerRateLimit     = "X-RateLimit-Limit"
+	headerRateRemaining = "X-RateLimit-Remaining"
+	headerRateReset     = "X-RateLimit-Reset"
+	headerRateUsed      = "X-RateLimit-Used"
+	headerRateResource  = "X-RateLimit-Resource"
+)
+
+// PoolToken is one of the tokens given to NewGitHubAPIFromPool. Owners restricts the token to the
+// repositories of those users and organizations, a token without owners serves every other owner.
+type PoolToken struct {
+	Source TokenSource
+	Owners []string
+}
+
+// TokenUsage reports the requests one token of a pool sent and the rate limit it has left.
+type TokenUsage struct {
+	Name   string
+	Owners []string
+	Calls  int64
+
+	// Rate is zero until a response reported the rate limit of the token.
+	Rate github.Rate
+}
+
+type poolMember struct {
+	name   string
+	owners []string
+	src    oauth2.TokenSource
+	calls  atomic.Int64
+
+	// rate is guarded by tokenPool.mu
+	rate github.Rate
+}
+
+// tokenPool authenticates every request with the token that has the most requests left among those
+// that may act for the owner of the requested repository. A request rejected by the rate limit of its
+// token is sent again with another token that still has requests left, and the rate limit headers of
+// responses are replaced by the total of the pool so the client only stops when every token is exhausted.
+type tokenPool struct {
+	base    http.RoundTripper
+	members []*poolMember
+
+	mu sync.Mutex
+}
+
+// NewGitHubAPIFromPool returns a GitHubAPI that rotates between tokens, for github.com when baseURL is
+// empty and for the GitHub Enterprise Server at baseURL otherwise. The first token of every source is
+// fetched up front so a source that cannot supply one fails here.
+func NewGitHubAPIFromPool(ctx context.Context, tokens []PoolToken, baseURL string,
+	uploadURL string) (*GitHubAPI, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty token pool error")
+	}
+
+	pool := tokenPool{
+		base:    oauth2.NewClient(ctx, nil).Transport,
+		members: make([]*poolMember, 0, len(tokens)),
+	}
+
+	if pool.base == nil {
+		pool.base = http.DefaultTransport
+	}
+
+	for _, tok := range tokens {
+		if tok.Source == nil {
+			return nil, fmt.Errorf("empty token source error")
+		}
+
+		token, err := tok.Source.Token()
+		if err != nil {
+			return nil, fmt.Errorf("%s token error: %w", tok.Source.Name(), err)
+		}
+
+		owners := make([]string, 0, len(tok.Owners))
+		for _, owner := range tok.Owners {
+			owners = append(owners, strings.ToLower(owner))
+		}
+
+		//nolint:exhaustruct // calls and rate start at zero
+		pool.members = append(pool.members, &poolMember{
+			name:   tok.Source.Name(),
+			owners: owners,
+			src:    oauth2.ReuseTokenSource(token, tok.Source),
+		})
+	}
+
+	api, err := newAPI(&pool, baseURL, uploadURL)
+	if err != nil {
+		return nil, err
+	}
+
+	api.pool = &pool
+
+	return api, nil
+}
+
+// TokenUsage returns the usage of every token of a client made by NewGitHubAPIFromPool, in the order the
+// tokens were given, and nil for other clients.
+func (api *GitHubAPI) TokenUsage() []TokenUsage {
+	if api.pool == nil {
+		return nil
+	}
+
+	api.pool.mu.Lock()
+	defer api.pool.mu.Unlock()
+
+	usage := make([]TokenUsage, 0, len(api.pool.members))
+
+	for _, mem := range api.pool.members {
+		usage = append(usage, TokenUsage{Name: mem.name, Owners: mem.owners, Calls: mem.calls.Load(), Rate: mem.rate})
+	}
+
+	return usage
+}
+
+func (pool *tokenPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	owner := requestOwner(req.URL.Path)
+	tried := make(map[*poolMember]bool, len(pool.members))
+	send := req
+
+	for {
+		mem := pool.pick(owner, tried, time.Now())
+		tried[mem] = true
+
+		token, err := mem.src.Token()
+		if err != nil {
+			return nil, fmt.Errorf("%s token error: %w", mem.name, err)
+		}
+
+		areq := send.Clone(send.Context())
+		token.SetAuthHeader(areq)
+		mem.calls.Add(1)
+
+		resp, err := pool.base.RoundTrip(areq)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // errors pass through the transport unchanged
+		}
+
+		rate, ok := responseRate(resp)
+		if !ok {
+			return resp, nil
+		}
+
+		pool.observe(mem, rate)
+
+		// rate limited requests are rejected before GitHub acts on them, so they can be sent again
+		limited := rate.Remaining == 0 &&
+			(resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests)
+		if limited && pool.hasQuota(owner, tried, time.Now()) {
+			if next, ok := retryRequest(req); ok {
+				io.Copy(io.Discard, resp.Body) //nolint:errcheck // the response is dropped for the retry
+				resp.Body.Close()
+
+				send = next
+
+				continue
+			}
+		}
+
+		if !limited {
+			pool.setTotalRate(resp.Header, time.Now())
+		}
+
+		return resp, nil
+	}
+}
+
+// candidates returns the tokens given for owner, or else the tokens given for no owner in particular,
+// or else every token.
+func (pool *tokenPool) candidates(owner string) []*poolMember {
+	given := make([]*poolMember, 0, len(pool.members))
+	general := make([]*poolMember, 0, len(pool.members))
+
+	for _, mem := range pool.members {
+		switch {
+		case len(mem.owners) == 0:
+			general = append(general, mem)
+		case len(owner) > 0 && slices.Contains(mem.owners, owner):
+			given = append(given, mem)
+		}
+	}
+
+	switch {
+	case len(given) > 0:
+		return given
+	case len(general) > 0:
+		return general
+	default:
+		return pool.members
+	}
+}
+
+// pick returns the candidate for owner not yet tried with the most requests left, and of those the one
+// whose limit resets first. Tokens whose rate limit is not known yet are tried first.
+func (pool *tokenPool) pick(owner string, tried map[*poolMember]bool, now time.Time) *poolMember {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	cands := pool.candidates(owner)
+	best := cands[0]
+	bestLeft := -1
+
+	for _, mem := range cands {
+		if tried[mem] {
+			continue
+		}
+
+		left := requestsLeft(mem.rate, now)
+		if left > bestLeft || (left == bestLeft && mem.rate.Reset.Before(best.rate.Reset.Time)) {
+			best, bestLeft = mem, left
+		}
+	}
+
+	return best
+}
+
+// hasQuota reports whether a candidate for owner not yet tried has requests left.
+func (pool *tokenPool) hasQuota(owner string, tried map[*poolMember]bool, now time.Time) bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	for _, mem := range pool.candidates(owner) {
+		if !tried[mem] && requestsLeft(mem.rate, now) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (pool *tokenPool) observe(mem *poolMember, rate github.Rate) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	mem.rate = rate
+}
+
+// setTotalRate replaces the rate limit headers of a response by the total of every token whose rate
+// limit is known. The reset is the earliest one still to come, when the first requests free up again.
+func (pool *tokenPool) setTotalRate(header http.Header, now time.Time) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	var limit, left int
+
+	var reset time.Time
+
+	for _, mem := range pool.members {
+		if mem.rate.Limit == 0 {
+			continue
+		}
+
+		limit += mem.rate.Limit
+		left += requestsLeft(mem.rate, now)
+
+		if mem.rate.Reset.After(now) && (reset.IsZero() || mem.rate.Reset.Before(reset)) {
+			reset = mem.rate.Reset.Time
+		}
+	}
+
+	if limit == 0 {
+		return
+	}
+
+	if reset.IsZero() {
+		reset = now
+	}
+
+	header.Set(headerRateLimit, strconv.Itoa(limit))
+	header.Set(headerRateRemaining, strconv.Itoa(left))
+	header.Set(headerRateUsed, strconv.Itoa(limit-left))
+	header.Set(headerRateReset, strconv.FormatInt(reset.Unix(), 10))
+}
+
+// requestsLeft returns the requests left of rate, the full limit once it reset and as many as possible
+// when the limit is not known yet.
+func requestsLeft(rate github.Rate, now time.Time) int {
+	switch {
+	case rate.Limit == 0:
+		return math.MaxInt
+	case !rate.Reset.After(now):
+		return rate.Limit
+	default:
+		return rate.Remaining
+	}
+}
+
+// responseRate reads the core rate limit of resp, false when resp reports none or the limit of another
+// resource, such as search.
+func responseRate(resp *http.Response) (github.Rate, bool) {
+	if resource := resp.Header.Get(headerRateResource); len(resource) > 0 && resource != "core" {
+		return github.Rate{}, false
+	}
+
+	limit, err := strconv.Atoi(resp.Header.Get(headerRateLimit))
+	if err != nil {
+		return github.Rate{}, false
+	}
+
+	remaining, _ := strconv.Atoi(resp.Header.Get(headerRateRemaining))
+	reset, _ := strconv.ParseInt(resp.Header.Get(headerRateReset), 10, 64)
+
+	return github.Rate{Limit: limit, Remaining: remaining, Reset: github.Timestamp{Time: time.Unix(reset, 0)}}, true
+}
+
+// requestOwner returns the lower-cased user or organization a request path is about, empty for paths
+// about no owner in particular, such as /user.
+func requestOwner(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	for idx := 0; idx+1 < len(parts); idx++ {
+		switch parts[idx] {
+		case "repos", "users", "orgs":
+			return strings.ToLower(parts[idx+1])
+		}
+	}
+
+	return ""
+}
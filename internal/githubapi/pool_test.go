@@ -0,0 +1,151 @@
+package githubapi_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	nethttptest "net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v53/github"
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// quotaServer answers every request with the rate limit left of the token it was sent with, rejecting
+// requests of exhausted tokens, and records which token each repository was requested with.
+type quotaServer struct {
+	mu    sync.Mutex
+	left  map[string]int
+	reset time.Time
+	seen  map[string][]string
+}
+
+func newQuotaServer(left map[string]int) *quotaServer {
+	return &quotaServer{left: left, reset: time.Now().Add(time.Hour), seen: make(map[string][]string)}
+}
+
+func (qsr *quotaServer) ServeHTTP(wtr http.ResponseWriter, req *http.Request) {
+	qsr.mu.Lock()
+	defer qsr.mu.Unlock()
+
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	qsr.seen[req.URL.Path] = append(qsr.seen[req.URL.Path], token)
+
+	wtr.Header().Set("X-RateLimit-Limit", "100")
+	wtr.Header().Set("X-RateLimit-Reset", strconv.FormatInt(qsr.reset.Unix(), 10))
+
+	if qsr.left[token] == 0 {
+		wtr.Header().Set("X-RateLimit-Remaining", "0")
+		wtr.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(wtr, `{"message":"API rate limit exceeded"}`)
+
+		return
+	}
+
+	qsr.left[token]--
+	wtr.Header().Set("X-RateLimit-Remaining", strconv.Itoa(qsr.left[token]))
+	fmt.Fprint(wtr, `{"name":"app"}`)
+}
+
+func newPoolAPI(t *testing.T, url string, tokens ...githubapi.PoolToken) *githubapi.GitHubAPI {
+	t.Helper()
+
+	gha, err := githubapi.NewGitHubAPIFromPool(context.Background(), tokens, url, "")
+	if err != nil {
+		t.Fatalf("githubapi.NewGitHubAPIFromPool returned error: %v", err)
+	}
+
+	gha.MaxRateLimitWait = 0
+
+	return gha
+}
+
+func poolToken(token string, owners ...string) githubapi.PoolToken {
+	return githubapi.PoolToken{Source: githubapi.StaticTokenSource(token, "token "+token), Owners: owners}
+}
+
+func TestPoolRotation(t *testing.T) {
+	qsr := newQuotaServer(map[string]int{"a": 10, "b": 50})
+	srvr := nethttptest.NewServer(qsr)
+	defer srvr.Close()
+
+	gha := newPoolAPI(t, srvr.URL, poolToken("a"), poolToken("b"))
+
+	for range 4 {
+		_, err := gha.GetRepository(context.Background(), "o", "app")
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"a", "b", "b", "b"}, qsr.seen["/api/v3/repos/o/app"],
+		"each token is tried once, then the one with the most requests left is used")
+
+	usage := gha.TokenUsage()
+	if assert.Len(t, usage, 2) {
+		assert.Equal(t, "token a", usage[0].Name)
+		assert.Equal(t, int64(1), usage[0].Calls)
+		assert.Equal(t, 9, usage[0].Rate.Remaining)
+		assert.Equal(t, int64(3), usage[1].Calls)
+		assert.Equal(t, 47, usage[1].Rate.Remaining)
+	}
+
+	assert.Equal(t, 200, gha.Rate().Limit, "the client sees the limit of the whole pool")
+	assert.Equal(t, 56, gha.Rate().Remaining)
+}
+
+func TestPoolExhausted(t *testing.T) {
+	qsr := newQuotaServer(map[string]int{"a": 0, "b": 1})
+	srvr := nethttptest.NewServer(qsr)
+	defer srvr.Close()
+
+	gha := newPoolAPI(t, srvr.URL, poolToken("a"), poolToken("b"))
+
+	_, err := gha.GetRepository(context.Background(), "o", "app")
+	assert.NoError(t, err, "the request is sent again with the token that has requests left")
+	assert.Equal(t, []string{"a", "b"}, qsr.seen["/api/v3/repos/o/app"])
+
+	var rerr *github.RateLimitError
+
+	_, err = gha.GetRepository(context.Background(), "o", "app")
+	assert.True(t, errors.As(err, &rerr), "every token is exhausted")
+	assert.Equal(t, 200, rerr.Rate.Limit)
+}
+
+func TestPoolOwners(t *testing.T) {
+	qsr := newQuotaServer(map[string]int{"acme": 10, "bot": 10, "other": 50})
+	srvr := nethttptest.NewServer(qsr)
+	defer srvr.Close()
+
+	gha := newPoolAPI(t, srvr.URL, poolToken("acme", "Acme"), poolToken("bot"), poolToken("other", "other-org"))
+
+	for _, owner := range []string{"acme", "ACME", "someone"} {
+		_, err := gha.GetRepository(context.Background(), owner, "app")
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"acme"}, qsr.seen["/api/v3/repos/acme/app"])
+	assert.Equal(t, []string{"acme"}, qsr.seen["/api/v3/repos/ACME/app"])
+	assert.Equal(t, []string{"bot"}, qsr.seen["/api/v3/repos/someone/app"],
+		"owners without a token of their own use the tokens given for no owner")
+	assert.Equal(t, []string{"acme"}, gha.TokenUsage()[0].Owners)
+}
+
+func TestPoolErrors(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := githubapi.NewGitHubAPIFromPool(ctx, nil, "", "")
+	assert.ErrorContains(t, err, "empty token pool error")
+
+	_, err = githubapi.NewGitHubAPIFromPool(ctx, []githubapi.PoolToken{poolToken("a"), poolToken("")}, "", "")
+	assert.ErrorContains(t, err, "empty token error")
+
+	gha, err := githubapi.NewGitHubAPI(ctx, "token")
+	if assert.NoError(t, err) {
+		assert.Nil(t, gha.TokenUsage())
+	}
+}
@@ -0,0 +1,179 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v53/github"
+)
+
+const (
+	// MergeTypePullRequest is reported when a conflicting fork branch was handed to a sync pull request.
+	MergeTypePullRequest = "pull-request"
+
+	// SyncBranchPrefix starts the name of every branch created for a sync pull request.
+	SyncBranchPrefix = "sync/upstream-"
+)
+
+// syncBranchName returns the head branch for a sync pull request into branch, the default branch
+// gets the plain sync/upstream-<date> name.
+func syncBranchName(branch string, defaultBranch string, now time.Time) string {
+	date := now.UTC().Format("2006-01-02")
+	if branch == defaultBranch {
+		return SyncBranchPrefix + date
+	}
+
+	return SyncBranchPrefix + branch + "-" + date
+}
+
+// findSyncPullRequest returns the open sync pull request into branch, nil when there is none.
+func (api *GitHubAPI) findSyncPullRequest(ctx context.Context, owner string, name string,
+	branch string) (*github.PullRequest, error) {
+	page := 1
+
+	for {
+		//nolint:exhaustruct // defaults are desired except for state, base and paging
+		opts := github.PullRequestListOptions{
+			State: "open",
+			Base:  branch,
+			ListOptions: github.ListOptions{
+				Page:    page,
+				PerPage: 100,
+			},
+		}
+
+		prs, err := api.ListPullRequests(ctx, owner, name, &opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pr := range prs {
+			head := pr.GetHead()
+			if strings.HasPrefix(head.GetRef(), SyncBranchPrefix) && head.GetRepo().GetOwner().GetLogin() == owner {
+				return pr, nil
+			}
+		}
+
+		if len(prs) < opts.PerPage {
+			return nil, nil
+		}
+
+		page++
+	}
+}
+
+// OpenSyncPullRequest pushes the upstream head of branch into a sync/upstream-<date> branch of the
+// fork and opens a pull request from it into branch so the conflict can be resolved in the GitHub UI.
+// An open sync pull request is reused by fast-forwarding its head branch to the upstream head, one whose
+// head branch diverged from upstream is left as is and reported.
+func (api *GitHubAPI) OpenSyncPullRequest(ctx context.Context, owner string, name string,
+	branch string) (*SyncResult, error) {
+	res := SyncResult{Owner: owner, Repo: name, Branch: branch}
+
+	fail := func(format string, err error) (*SyncResult, error) {
+		res.Status, res.Message = errorStatus(err)
+		res.Err = fmt.Errorf(format, err)
+
+		return &res, res.Err
+	}
+
+	repo, err := api.GetRepository(ctx, owner, name)
+	if err != nil {
+		return fail("GetRepository error: %w", err)
+	}
+
+	res.UpstreamOwner, res.UpstreamRepo, res.UpstreamBranch, err = api.upstreamBranch(ctx, owner, name, branch)
+	if err != nil {
+		return fail("upstreamBranch error: %w", err)
+	}
+
+	res.UpstreamSHA, err = api.GetBranchSHA(ctx, res.UpstreamOwner, res.UpstreamRepo, res.UpstreamBranch)
+	if err != nil {
+		return fail("GetBranchSHA error: %w", err)
+	}
+
+	existing, err := api.findSyncPullRequest(ctx, owner, name, branch)
+	if err != nil {
+		return fail("findSyncPullRequest error: %w", err)
+	}
+
+	res.MergeType = MergeTypePullRequest
+
+	if existing != nil {
+		status, uerr := api.advanceSyncBranch(ctx, owner, name, existing.GetHead().GetRef(), res.UpstreamSHA)
+		if uerr != nil {
+			return fail("advanceSyncBranch error: %w", uerr)
+		}
+
+		switch status {
+		case compareStatusBehind:
+			res.Message = fmt.Sprintf("has merge conflicts, updated pull request #%d %s",
+				existing.GetNumber(), existing.GetHTMLURL())
+		case compareStatusDiverged:
+			// commits were added to the sync branch, such as a conflict resolution, and upstream moved on since
+			res.Message = fmt.Sprintf("has merge conflicts, pull request #%d %s has diverged from upstream %s, "+
+				"merge upstream into it to catch up", existing.GetNumber(), existing.GetHTMLURL(), shortSHA(res.UpstreamSHA))
+		default:
+			res.Message = fmt.Sprintf("has merge conflicts, pull request #%d %s already contains upstream",
+				existing.GetNumber(), existing.GetHTMLURL())
+		}
+
+		return &res, nil
+	}
+
+	head := syncBranchName(branch, repo.GetDefaultBranch(), time.Now())
+
+	cerr := api.CreateBranch(ctx, owner, name, head, res.UpstreamSHA)
+	if status, _ := errorStatus(cerr); status == http.StatusUnprocessableEntity {
+		// the branch is left over from an earlier, closed, sync pull request today
+		var status string
+		if status, cerr = api.advanceSyncBranch(ctx, owner, name, head, res.UpstreamSHA); status == compareStatusDiverged {
+			cerr = fmt.Errorf("sync branch %s left over from a closed pull request has diverged from upstream, "+
+				"delete it to open a new one", head)
+		}
+	}
+
+	if cerr != nil {
+		return fail("CreateBranch error: %w", cerr)
+	}
+
+	//nolint:exhaustruct // optional pull request fields are not needed
+	pull := github.NewPullRequest{
+		Title: github.String(fmt.Sprintf("Sync %s with upstream %s", branch, res.UpstreamFullName())),
+		Head:  github.String(head),
+		Base:  github.String(branch),
+		Body: github.String(fmt.Sprintf("Merging upstream %s into %s reported conflicts.\n\n"+
+			"This pull request brings in upstream at %s so the conflicts can be resolved here.",
+			res.UpstreamFullName(), branch, res.UpstreamSHA)),
+	}
+
+	pr, err := api.CreatePullRequest(ctx, owner, name, &pull)
+	if err != nil {
+		return fail("CreatePullRequest error: %w", err)
+	}
+
+	res.Message = fmt.Sprintf("has merge conflicts, opened pull request #%d %s", pr.GetNumber(), pr.GetHTMLURL())
+
+	return &res, nil
+}
+
+// advanceSyncBranch fast-forwards the sync branch head to the upstream sha and returns how head compared
+// to sha, compareStatusBehind when it moved. A branch that already contains sha, such as one the conflicts
+// were resolved on, is left alone, and so is one that diverged rather than losing its commits.
+func (api *GitHubAPI) advanceSyncBranch(ctx context.Context, owner string, name string, head string,
+	sha string) (string, error) {
+	comp, err := api.CompareCommits(ctx, owner, name, sha, head)
+	if err == nil && comp.GetStatus() != compareStatusBehind {
+		return comp.GetStatus(), nil
+	}
+
+	// without a comparison the update is still safe, it refuses anything but a fast-forward
+	if err := api.UpdateBranch(ctx, owner, name, head, sha, false); err != nil {
+		return "", err
+	}
+
+	return compareStatusBehind, nil
+}
@@ -0,0 +1,227 @@
+package githubapi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v53/github"
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/http/httptest"
+	"github.com/stretchr/testify/assert"
+)
+
+type prServer struct {
+	srvr    *httptest.Server
+	refs    []map[string]any
+	patches []string
+	pulls   []*github.NewPullRequest
+}
+
+// newPRServer serves a single conflicting fork, Test_owner/repo00, and records the ref and pull
+// request calls made by the conflict fallback. openPRs is the JSON list of open pull requests and
+// compare the status of sync/upstream-2024-01-01 against the upstream head.
+func newPRServer(t *testing.T, openPRs string, compare string) *prServer {
+	t.Helper()
+
+	psrv := prServer{}
+
+	psrv.srvr = newSyncTestServer(t, 1, func(wtr http.ResponseWriter, _ *http.Request, _ string) {
+		wtr.WriteHeader(http.StatusConflict)
+		fmt.Fprint(wtr, `{"message":"There are merge conflicts"}`)
+	})
+
+	psrv.srvr.Mux.HandleFunc("/repos/Test_owner/repo00", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `{"name":"repo00","fork":true,"default_branch":"main",`+
+			`"parent":{"name":"repo00","owner":{"login":"Test_upstream"},"default_branch":"main"}}`)
+	})
+	handleRef(t, psrv.srvr, "Test_upstream", "repo00", "main", "upstream-sha")
+
+	psrv.srvr.Mux.HandleFunc("/repos/Test_owner/repo00/pulls", func(wtr http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodGet {
+			testFormValues(t, req, values{"state": "open", "base": "main", "page": "1", "per_page": "100"})
+			fmt.Fprint(wtr, openPRs)
+
+			return
+		}
+
+		testMethod(t, req, http.MethodPost)
+
+		pull := new(github.NewPullRequest)
+		json.NewDecoder(req.Body).Decode(pull) //nolint:errcheck  // We don't care about the error here
+		psrv.pulls = append(psrv.pulls, pull)
+
+		fmt.Fprint(wtr, `{"number":7,"html_url":"https://github.com/Test_owner/repo00/pull/7"}`)
+	})
+
+	psrv.srvr.Mux.HandleFunc("/repos/Test_owner/repo00/git/refs", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodPost)
+
+		ref := map[string]any{}
+		json.NewDecoder(req.Body).Decode(&ref) //nolint:errcheck  // We don't care about the error here
+		psrv.refs = append(psrv.refs, ref)
+
+		fmt.Fprint(wtr, `{}`)
+	})
+
+	psrv.srvr.Mux.HandleFunc("/repos/Test_owner/repo00/compare/", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodGet)
+		assert.Equal(t, "/repos/Test_owner/repo00/compare/upstream-sha...sync%2Fupstream-2024-01-01",
+			req.URL.EscapedPath())
+		fmt.Fprintf(wtr, `{"status":%q}`, compare)
+	})
+
+	psrv.srvr.Mux.HandleFunc("/repos/Test_owner/repo00/git/refs/heads/", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodPatch)
+
+		ref := map[string]any{}
+		json.NewDecoder(req.Body).Decode(&ref) //nolint:errcheck  // We don't care about the error here
+		psrv.patches = append(psrv.patches, fmt.Sprintf("%s %v %v", req.URL.Path, ref["sha"], ref["force"]))
+
+		fmt.Fprint(wtr, `{}`)
+	})
+
+	return &psrv
+}
+
+func TestSyncForksConflictPullRequestOpened(t *testing.T) {
+	psrv := newPRServer(t, `[]`, "")
+	defer psrv.srvr.Close()
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", psrv.srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	rpt, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{ConflictPullRequest: true})
+	if err != nil {
+		t.Fatalf("githubapi.SyncForks returned error: %v", err)
+	}
+
+	head := githubapi.SyncBranchPrefix + time.Now().UTC().Format("2006-01-02")
+
+	assert.Equal(t, []map[string]any{{"ref": "refs/heads/" + head, "sha": "upstream-sha"}}, psrv.refs)
+
+	if assert.Len(t, psrv.pulls, 1) {
+		assert.Equal(t, head, psrv.pulls[0].GetHead())
+		assert.Equal(t, "main", psrv.pulls[0].GetBase())
+	}
+
+	if assert.Len(t, rpt.Results, 1) {
+		assert.Equal(t, githubapi.MergeTypePullRequest, rpt.Results[0].MergeType)
+		assert.NoError(t, rpt.Results[0].Err)
+	}
+
+	assert.Contains(t, out.String(), "has merge conflicts, opened pull request #7")
+}
+
+func TestSyncForksConflictPullRequestReused(t *testing.T) {
+	psrv := newPRServer(t, `[{"number":3,"html_url":"https://github.com/Test_owner/repo00/pull/3",`+
+		`"head":{"ref":"feature","repo":{"owner":{"login":"Test_owner"}}}},`+
+		`{"number":5,"html_url":"https://github.com/Test_owner/repo00/pull/5",`+
+		`"head":{"ref":"sync/upstream-2024-01-01","repo":{"owner":{"login":"Test_owner"}}}}]`, "behind")
+	defer psrv.srvr.Close()
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", psrv.srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	_, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{ConflictPullRequest: true})
+	if err != nil {
+		t.Fatalf("githubapi.SyncForks returned error: %v", err)
+	}
+
+	assert.Empty(t, psrv.refs)
+	assert.Empty(t, psrv.pulls)
+	assert.Equal(t, []string{"/repos/Test_owner/repo00/git/refs/heads/sync/upstream-2024-01-01 upstream-sha false"},
+		psrv.patches)
+	assert.Contains(t, out.String(), "has merge conflicts, updated pull request #5")
+}
+
+func TestSyncForksConflictPullRequestKeepsResolution(t *testing.T) {
+	// the conflicts were resolved with a commit on top of the upstream head
+	psrv := newPRServer(t, `[{"number":5,"html_url":"https://github.com/Test_owner/repo00/pull/5",`+
+		`"head":{"ref":"sync/upstream-2024-01-01","repo":{"owner":{"login":"Test_owner"}}}}]`, "ahead")
+	defer psrv.srvr.Close()
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", psrv.srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	_, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{ConflictPullRequest: true})
+	if err != nil {
+		t.Fatalf("githubapi.SyncForks returned error: %v", err)
+	}
+
+	assert.Empty(t, psrv.refs)
+	assert.Empty(t, psrv.pulls)
+	assert.Empty(t, psrv.patches, "the resolution commit must survive")
+	assert.Contains(t, out.String(), "has merge conflicts, pull request #5 "+
+		"https://github.com/Test_owner/repo00/pull/5 already contains upstream")
+}
+
+func TestSyncForksConflictPullRequestDiverged(t *testing.T) {
+	// the conflicts were resolved with a merge commit and upstream moved on since
+	psrv := newPRServer(t, `[{"number":5,"html_url":"https://github.com/Test_owner/repo00/pull/5",`+
+		`"head":{"ref":"sync/upstream-2024-01-01","repo":{"owner":{"login":"Test_owner"}}}}]`, "diverged")
+	defer psrv.srvr.Close()
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", psrv.srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	rpt, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{ConflictPullRequest: true})
+	if err != nil {
+		t.Fatalf("githubapi.SyncForks returned error: %v", err)
+	}
+
+	assert.Empty(t, psrv.refs)
+	assert.Empty(t, psrv.pulls)
+	assert.Empty(t, psrv.patches, "a diverged sync branch cannot be fast-forwarded")
+	assert.Equal(t, githubapi.MergeTypePullRequest, rpt.Results[0].MergeType)
+	assert.Contains(t, out.String(), "has merge conflicts, pull request #5 "+
+		"https://github.com/Test_owner/repo00/pull/5 has diverged from upstream upstrea, merge upstream into it")
+}
+
+func TestSyncForksConflictWithoutFallback(t *testing.T) {
+	psrv := newPRServer(t, `[]`, "")
+	defer psrv.srvr.Close()
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", psrv.srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	gha.Out = &bytes.Buffer{}
+
+	_, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{})
+	if err == nil {
+		t.Fatalf("githubapi.SyncForks should have returned an error")
+	}
+
+	assert.Empty(t, psrv.pulls)
+}
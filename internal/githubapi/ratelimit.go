@@ -0,0 +1,179 @@
+package githubapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/go-github/v53/github"
+)
+
+const (
+	// DefaultMaxRateLimitWait is the longest pause for a rate limit reset of clients made by NewGitHubAPI.
+	DefaultMaxRateLimitWait = time.Hour
+
+	// SecondaryRateLimitWait is the pause after a secondary rate limit response that has no Retry-After.
+	SecondaryRateLimitWait = time.Minute
+
+	// rateLimitProgress is how often the remaining pause is printed while waiting.
+	rateLimitProgress = time.Minute
+
+	// rateLimitMargin is added to the reset time GitHub reports, which has a one second resolution.
+	rateLimitMargin = time.Second
+)
+
+// withRateLimit runs call and, when it fails on the primary or secondary rate limit, pauses until GitHub
+// accepts requests again and runs it once more. Rate limited requests are rejected before GitHub acts on
+// them, so this is safe for requests that change the fork too. Errors are returned at once when
+// MaxRateLimitWait is zero.
+func withRateLimit[T any](ctx context.Context, api *GitHubAPI,
+	call func() (T, *github.Response, error)) (T, *github.Response, error) {
+	for {
+		val, resp, err := call()
+		api.observeRate(resp)
+
+		until, reason := rateLimitedUntil(err, time.Now())
+		if until.IsZero() {
+			return val, resp, err
+		}
+
+		if api.MaxRateLimitWait <= 0 {
+			return val, resp, err
+		}
+
+		if wait := time.Until(until); wait > api.MaxRateLimitWait {
+			return val, resp, fmt.Errorf("%w (the limit resets in %s, beyond the %s allowed to wait)",
+				err, wait.Round(time.Second), api.MaxRateLimitWait)
+		}
+
+		if werr := api.waitRateLimit(ctx, until, reason); werr != nil {
+			return val, resp, werr
+		}
+	}
+}
+
+// withRateLimitNoValue is withRateLimit for calls that only return the response.
+func withRateLimitNoValue(ctx context.Context, api *GitHubAPI, call func() (*github.Response, error)) (
+	*github.Response, error) {
+	_, resp, err := withRateLimit(ctx, api, func() (struct{}, *github.Response, error) {
+		resp, err := call()
+
+		return struct{}{}, resp, err
+	})
+
+	return resp, err
+}
+
+// rateLimitedUntil returns when a request that failed with err may be sent again, zero when err is not a
+// rate limit error, and why the request was limited.
+func rateLimitedUntil(err error, now time.Time) (time.Time, string) {
+	var rerr *github.RateLimitError
+	if errors.As(err, &rerr) {
+		return rerr.Rate.Reset.Add(rateLimitMargin),
+			fmt.Sprintf("Rate limit of %d requests exhausted", rerr.Rate.Limit)
+	}
+
+	var aerr *github.AbuseRateLimitError
+	if errors.As(err, &aerr) {
+		wait := SecondaryRateLimitWait
+		if aerr.RetryAfter != nil {
+			wait = *aerr.RetryAfter
+		}
+
+		return now.Add(wait + rateLimitMargin), "Secondary rate limit hit"
+	}
+
+	// go-github does not recognize 429 Too Many Requests, GitHub sends it for secondary limits too
+	var eresp *github.ErrorResponse
+	if errors.As(err, &eresp) && eresp.Response != nil && eresp.Response.StatusCode == http.StatusTooManyRequests {
+		wait := SecondaryRateLimitWait
+		if secs, perr := strconv.Atoi(eresp.Response.Header.Get("Retry-After")); perr == nil {
+			wait = time.Duration(secs) * time.Second
+		}
+
+		return now.Add(wait + rateLimitMargin), "Secondary rate limit hit"
+	}
+
+	return time.Time{}, ""
+}
+
+// waitRateLimit pauses until the rate limit resets. Only the worker that starts a pause reports it, the
+// others wait along silently.
+func (api *GitHubAPI) waitRateLimit(ctx context.Context, until time.Time, reason string) error {
+	api.rateMu.Lock()
+	announce := until.After(api.pausedUntil)
+
+	if announce {
+		api.pausedUntil = until
+	}
+	api.rateMu.Unlock()
+
+	if announce {
+		api.printf("-> %s, pausing %s until %s...\n", reason, time.Until(until).Round(time.Second),
+			until.Local().Format(time.TimeOnly))
+	}
+
+	for left := time.Until(until); left > 0; left = time.Until(until) {
+		timer := time.NewTimer(min(left, rateLimitProgress))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return fmt.Errorf("waiting for the rate limit reset error: %w", ctx.Err())
+		case <-timer.C:
+		}
+
+		if left = time.Until(until); announce && left > 0 {
+			api.printf("-> %s left until the rate limit resets...\n", left.Round(time.Second))
+		}
+	}
+
+	return nil
+}
+
+// observeRate keeps the core rate limit reported by the latest response.
+func (api *GitHubAPI) observeRate(resp *github.Response) {
+	if resp == nil || resp.Rate.Limit == 0 {
+		return
+	}
+
+	// the GraphQL and search limits are separate from the core one
+	if resource := resp.Header.Get(headerRateResource); len(resource) > 0 && resource != "core" {
+		return
+	}
+
+	api.rateMu.Lock()
+	defer api.rateMu.Unlock()
+
+	api.rate = resp.Rate
+}
+
+// Rate returns the rate limit reported by the latest response, zero before any response reported one.
+func (api *GitHubAPI) Rate() github.Rate {
+	api.rateMu.Lock()
+	defer api.rateMu.Unlock()
+
+	return api.rate
+}
+
+// APICalls returns the number of requests sent to the API by a client made by NewGitHubAPI.
+func (api *GitHubAPI) APICalls() int64 {
+	return api.calls.Load()
+}
+
+// countingTransport counts the requests that reach the network.
+type countingTransport struct {
+	base  http.RoundTripper
+	calls *atomic.Int64
+}
+
+func (trn *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trn.calls.Add(1)
+
+	return trn.base.RoundTrip(req) //nolint:wrapcheck // errors pass through the transport unchanged
+}
@@ -0,0 +1,168 @@
+package githubapi_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	nethttptest "net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v53/github"
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/http/httptest"
+	"github.com/stretchr/testify/assert"
+)
+
+// limitedOnce fails the first request of the handler with limit and answers the later ones with body.
+func limitedOnce(limit func(wtr http.ResponseWriter), body string) (http.HandlerFunc, *int) {
+	calls := 0
+
+	return func(wtr http.ResponseWriter, _ *http.Request) {
+		calls++
+
+		if calls == 1 {
+			limit(wtr)
+
+			return
+		}
+
+		fmt.Fprint(wtr, body)
+	}, &calls
+}
+
+func primaryLimit(reset time.Time) func(wtr http.ResponseWriter) {
+	return func(wtr http.ResponseWriter) {
+		wtr.Header().Set("X-RateLimit-Limit", "60")
+		wtr.Header().Set("X-RateLimit-Remaining", "0")
+		wtr.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		wtr.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(wtr, `{"message":"API rate limit exceeded"}`)
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    func(wtr http.ResponseWriter)
+		wantWait string
+	}{
+		{
+			name:     "primary",
+			limit:    primaryLimit(time.Now().Add(time.Second)),
+			wantWait: "-> Rate limit of 60 requests exhausted, pausing",
+		},
+		{
+			name: "secondary",
+			limit: func(wtr http.ResponseWriter) {
+				wtr.Header().Set("Retry-After", "1")
+				wtr.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(wtr, `{"message":"You have exceeded a secondary rate limit",`+
+					`"documentation_url":"https://docs.github.com/rest#secondary-rate-limits"}`)
+			},
+			wantWait: "-> Secondary rate limit hit, pausing",
+		},
+		{
+			name: "too many requests",
+			limit: func(wtr http.ResponseWriter) {
+				wtr.Header().Set("Retry-After", "0")
+				wtr.WriteHeader(http.StatusTooManyRequests)
+				fmt.Fprint(wtr, `{"message":"Too many requests"}`)
+			},
+			wantWait: "-> Secondary rate limit hit, pausing",
+		},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+			if serr != nil {
+				t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+			}
+			defer srvr.Close()
+
+			handler, calls := limitedOnce(tst.limit, `{"name":"app","fork":true}`)
+			srvr.Mux.HandleFunc("/repos/o/app", handler)
+
+			ctx := context.Background()
+			gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+			if nerr != nil {
+				t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+			}
+
+			out := bytes.Buffer{}
+			gha.Out = &out
+			gha.MaxRateLimitWait = 10 * time.Second
+
+			repo, err := gha.GetRepository(ctx, "o", "app")
+			if assert.NoError(t, err) {
+				assert.Equal(t, "app", repo.GetName())
+			}
+
+			assert.Equal(t, 2, *calls)
+			assert.Contains(t, out.String(), tst.wantWait)
+		})
+	}
+}
+
+func TestRateLimitNoWait(t *testing.T) {
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+	defer srvr.Close()
+
+	handler, calls := limitedOnce(primaryLimit(time.Now().Add(time.Hour)), `{"name":"app"}`)
+	srvr.Mux.HandleFunc("/repos/o/app", handler)
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	gha.Out = &bytes.Buffer{}
+
+	var rerr *github.RateLimitError
+
+	_, err := gha.GetRepository(ctx, "o", "app")
+	assert.True(t, errors.As(err, &rerr), "without MaxRateLimitWait the error is returned at once")
+
+	gha.MaxRateLimitWait = time.Minute
+
+	_, err = gha.GetRepository(ctx, "o", "app")
+	assert.True(t, errors.As(err, &rerr))
+	assert.ErrorContains(t, err, "allowed to wait")
+	assert.Equal(t, 1, *calls, "the client knows the limit is still exhausted")
+	assert.Equal(t, 60, gha.Rate().Limit)
+}
+
+func TestAPICalls(t *testing.T) {
+	srvr := nethttptest.NewServer(http.HandlerFunc(func(wtr http.ResponseWriter, _ *http.Request) {
+		wtr.Header().Set("X-RateLimit-Limit", "5000")
+		wtr.Header().Set("X-RateLimit-Remaining", "4998")
+		fmt.Fprint(wtr, `{"name":"app"}`)
+	}))
+	defer srvr.Close()
+
+	ctx := context.Background()
+
+	gha, err := githubapi.NewGitHubAPIFromSource(ctx, githubapi.StaticTokenSource("token", "test"), srvr.URL, "")
+	if err != nil {
+		t.Fatalf("githubapi.NewGitHubAPIFromSource returned error: %v", err)
+	}
+
+	assert.Equal(t, githubapi.DefaultMaxRateLimitWait, gha.MaxRateLimitWait)
+
+	for range 2 {
+		_, gerr := gha.GetRepository(ctx, "o", "app")
+		assert.NoError(t, gerr)
+	}
+
+	assert.Equal(t, int64(2), gha.APICalls())
+	assert.Equal(t, 4998, gha.Rate().Remaining)
+}
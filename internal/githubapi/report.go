@@ -0,0 +1,128 @@
+package githubapi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// ErrSyncFailures is wrapped by SyncForks when ContinueOnError is set and at least one fork failed.
+var ErrSyncFailures = errors.New("one or more forks failed to sync")
+
+// SyncReport holds the results of a SyncForks run in the order the forks were listed.
+type SyncReport struct {
+	Results []*SyncResult
+}
+
+// ForkError describes a single fork branch that could not be synced.
+type ForkError struct {
+	Owner   string
+	Repo    string
+	Branch  string
+	Status  int
+	Message string
+	Err     error
+}
+
+func (ferr *ForkError) Error() string {
+	if ferr.Status != 0 {
+		return fmt.Sprintf("%s/%s %s: HTTP %d: %s", ferr.Owner, ferr.Repo, ferr.Branch, ferr.Status, ferr.Message)
+	}
+
+	return fmt.Sprintf("%s/%s %s: %v", ferr.Owner, ferr.Repo, ferr.Branch, ferr.Err)
+}
+
+func (ferr *ForkError) Unwrap() error {
+	return ferr.Err
+}
+
+// Failed returns the results that ended in an error.
+func (rpt *SyncReport) Failed() []*SyncResult {
+	failed := make([]*SyncResult, 0)
+
+	for _, res := range rpt.Results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+
+	return failed
+}
+
+// WithMergeType returns the successful results whose MergeType is mergeType.
+func (rpt *SyncReport) WithMergeType(mergeType string) []*SyncResult {
+	matched := make([]*SyncResult, 0)
+
+	for _, res := range rpt.Results {
+		if res.Err == nil && res.MergeType == mergeType {
+			matched = append(matched, res)
+		}
+	}
+
+	return matched
+}
+
+// Err joins a ForkError for every failed result, wrapped in ErrSyncFailures, or nil when all succeeded.
+func (rpt *SyncReport) Err() error {
+	failed := rpt.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+
+	errs := make([]error, 0, len(failed))
+	for _, res := range failed {
+		errs = append(errs, &ForkError{
+			Owner:   res.Owner,
+			Repo:    res.Repo,
+			Branch:  res.Branch,
+			Status:  res.Status,
+			Message: res.Message,
+			Err:     res.Err,
+		})
+	}
+
+	return fmt.Errorf("%w: %w", ErrSyncFailures, errors.Join(errs...))
+}
+
+// WriteSummary writes a table of every failed fork branch with its HTTP status and message. A fork counts
+// as failed when any of its branches or tags failed.
+func (rpt *SyncReport) WriteSummary(out io.Writer) error {
+	failed := rpt.Failed()
+
+	twr := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(twr, "\n%d of %d fork(s) failed to sync:\n", countForks(failed), countForks(rpt.Results))
+	fmt.Fprintln(twr, "FORK\tBRANCH\tSTATUS\tMESSAGE")
+
+	for _, res := range failed {
+		status := "-"
+		if res.Status != 0 {
+			status = fmt.Sprintf("%d", res.Status)
+		}
+
+		fmt.Fprintf(twr, "%s\t%s\t%s\t%s\n", res.FullName(), res.Branch, status, res.Message)
+	}
+
+	if err := twr.Flush(); err != nil {
+		return fmt.Errorf("tabwriter.Flush error: %w", err)
+	}
+
+	return nil
+}
+
+// countForks returns the number of distinct forks among the results.
+func countForks(results []*SyncResult) int {
+	forks := make(map[string]bool, len(results))
+	for _, res := range results {
+		forks[res.FullName()] = true
+	}
+
+	return len(forks)
+}
+
+func (api *GitHubAPI) writeSummary(rpt *SyncReport) {
+	api.outMu.Lock()
+	defer api.outMu.Unlock()
+
+	rpt.WriteSummary(api.output()) //nolint:errcheck  // best effort console output
+}
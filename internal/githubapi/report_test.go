@@ -0,0 +1,66 @@
+package githubapi_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncReportErrNone(t *testing.T) {
+	rpt := githubapi.SyncReport{
+		Results: []*githubapi.SyncResult{{Owner: "o", Repo: "a", Branch: "main", MergeType: "none"}},
+	}
+
+	assert.NoError(t, rpt.Err())
+	assert.Empty(t, rpt.Failed())
+}
+
+func TestSyncReportErrJoined(t *testing.T) {
+	conflict := fmt.Errorf("merge conflict")
+	rpt := githubapi.SyncReport{
+		Results: []*githubapi.SyncResult{
+			{Owner: "o", Repo: "a", Branch: "main", Status: 409, Message: "There are merge conflicts", Err: conflict},
+			{Owner: "o", Repo: "b", Branch: "main", MergeType: "fast-forward"},
+			{Owner: "o", Repo: "c", Branch: "dev", Err: fmt.Errorf("connection reset")},
+		},
+	}
+
+	err := rpt.Err()
+	if err == nil {
+		t.Fatalf("SyncReport.Err should have returned an error")
+	}
+
+	assert.True(t, errors.Is(err, githubapi.ErrSyncFailures))
+	assert.True(t, errors.Is(err, conflict))
+	assert.Contains(t, err.Error(), "o/a main: HTTP 409: There are merge conflicts")
+	assert.Contains(t, err.Error(), "o/c dev: connection reset")
+
+	var ferr *githubapi.ForkError
+	if assert.True(t, errors.As(err, &ferr)) {
+		assert.Equal(t, "a", ferr.Repo)
+	}
+}
+
+func TestSyncReportWriteSummary(t *testing.T) {
+	rpt := githubapi.SyncReport{
+		Results: []*githubapi.SyncResult{
+			{Owner: "o", Repo: "a", Branch: "main", Status: 409, Message: "conflict", Err: fmt.Errorf("x")},
+			{Owner: "o", Repo: "a", Branch: "dev", Status: 409, Message: "conflict", Err: fmt.Errorf("x")},
+			{Owner: "o", Repo: "b", Branch: "main", MergeType: "none"},
+			{Owner: "o", Repo: "b", Branch: "dev", MergeType: "none"},
+		},
+	}
+
+	out := bytes.Buffer{}
+	if err := rpt.WriteSummary(&out); err != nil {
+		t.Fatalf("SyncReport.WriteSummary returned error: %v", err)
+	}
+
+	assert.Contains(t, out.String(), "1 of 2 fork(s) failed to sync", "forks are counted once whatever their branches")
+	assert.Contains(t, out.String(), "FORK  BRANCH  STATUS  MESSAGE")
+	assert.Contains(t, out.String(), "o/a   main    409     conflict")
+}
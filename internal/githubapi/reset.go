@@ -0,0 +1,151 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// StrategyMerge syncs a fork branch with merge-upstream, it is the default.
+	StrategyMerge = "merge"
+
+	// StrategyReset force-moves the fork branch to the upstream head after backing up the old head.
+	StrategyReset = "reset"
+
+	// MergeTypeReset is reported when a fork branch was, or in a dry-run would be, reset to upstream.
+	MergeTypeReset = "reset"
+
+	// BackupBranchPrefix starts the name of every backup branch created before a reset.
+	BackupBranchPrefix = "backup/"
+)
+
+// ValidStrategy reports whether name is a known sync strategy.
+func ValidStrategy(name string) bool {
+	return name == StrategyMerge || name == StrategyReset
+}
+
+// strategy returns the sync strategy for the fork, a per-repository entry replaces the per-run one.
+func (opts *SyncOptions) strategy(fullName string) string {
+	if strategy, ok := opts.RepoStrategies[strings.ToLower(fullName)]; ok {
+		return strategy
+	}
+
+	if len(opts.Strategy) == 0 {
+		return StrategyMerge
+	}
+
+	return opts.Strategy
+}
+
+// backupBranchName returns the backup/<branch>-<timestamp> branch that keeps the pre-reset head.
+func backupBranchName(branch string, now time.Time) string {
+	return BackupBranchPrefix + branch + "-" + now.UTC().Format("20060102T150405Z")
+}
+
+// ResetBranch force-aligns the fork branch with its upstream branch. The current fork head is first
+// saved to a backup/<branch>-<timestamp> branch, recorded in the result's BackupRef, so it can be
+// restored. Nothing is changed when dryRun is set or the branch already matches upstream.
+func (api *GitHubAPI) ResetBranch(ctx context.Context, owner string, name string, branch string,
+	dryRun bool) (*SyncResult, error) {
+	res := SyncResult{Owner: owner, Repo: name, Branch: branch, Planned: dryRun}
+
+	fail := func(format string, err error) (*SyncResult, error) {
+		res.Status, res.Message = errorStatus(err)
+		res.Err = fmt.Errorf(format, err)
+
+		return &res, res.Err
+	}
+
+	var err error
+
+	res.UpstreamOwner, res.UpstreamRepo, res.UpstreamBranch, err = api.upstreamBranch(ctx, owner, name, branch)
+	if err != nil {
+		return fail("upstreamBranch error: %w", err)
+	}
+
+	res.UpstreamSHA, err = api.GetBranchSHA(ctx, res.UpstreamOwner, res.UpstreamRepo, res.UpstreamBranch)
+	if err != nil {
+		return fail("GetBranchSHA error: %w", err)
+	}
+
+	res.ForkSHA, err = api.GetBranchSHA(ctx, owner, name, branch)
+	if err != nil {
+		return fail("GetBranchSHA error: %w", err)
+	}
+
+	if res.ForkSHA == res.UpstreamSHA {
+		res.MergeType = MergeTypeNone
+		res.Message = fmt.Sprintf("is identical to upstream %s", res.UpstreamFullName())
+
+		return &res, nil
+	}
+
+	res.MergeType = MergeTypeReset
+
+	if dryRun {
+		res.Message = fmt.Sprintf("would reset %s -> %s to upstream %s with a backup branch",
+			shortSHA(res.ForkSHA), shortSHA(res.UpstreamSHA), res.UpstreamFullName())
+
+		return &res, nil
+	}
+
+	return api.resetToUpstream(ctx, &res)
+}
+
+// resetToUpstream backs up res.ForkSHA and force-moves res.Branch to res.UpstreamSHA.
+func (api *GitHubAPI) resetToUpstream(ctx context.Context, res *SyncResult) (*SyncResult, error) {
+	backup := backupBranchName(res.Branch, time.Now())
+
+	if err := api.CreateBranch(ctx, res.Owner, res.Repo, backup, res.ForkSHA); err != nil {
+		res.MergeType = ""
+		res.Status, res.Message = errorStatus(err)
+		res.Err = fmt.Errorf("CreateBranch error: %w", err)
+
+		return res, res.Err
+	}
+
+	res.BackupRef = "refs/heads/" + backup
+
+	if err := api.UpdateBranch(ctx, res.Owner, res.Repo, res.Branch, res.UpstreamSHA, true); err != nil {
+		res.MergeType = ""
+		res.Status, res.Message = errorStatus(err)
+		res.Err = fmt.Errorf("UpdateBranch error: %w", err)
+
+		return res, res.Err
+	}
+
+	res.MergeType = MergeTypeReset
+	res.HeadSHA = res.UpstreamSHA
+	res.Message = fmt.Sprintf("reset %s -> %s to upstream %s, backup at %s",
+		shortSHA(res.ForkSHA), shortSHA(res.UpstreamSHA), res.UpstreamFullName(), backup)
+
+	return res, nil
+}
+
+// Backups returns the results that created a backup ref, including failed resets that got that far.
+func (rpt *SyncReport) Backups() []*SyncResult {
+	backups := make([]*SyncResult, 0)
+
+	for _, res := range rpt.Results {
+		if len(res.BackupRef) > 0 {
+			backups = append(backups, res)
+		}
+	}
+
+	return backups
+}
+
+func (api *GitHubAPI) writeBackups(rpt *SyncReport) {
+	backups := rpt.Backups()
+	if len(backups) == 0 {
+		return
+	}
+
+	api.printf("-> Created %d backup ref(s):\n", len(backups))
+
+	for _, res := range backups {
+		api.printf("   %s %s (%s)\n", res.FullName(), res.BackupRef, res.ForkSHA)
+	}
+}
@@ -0,0 +1,155 @@
+package githubapi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/http/httptest"
+	"github.com/stretchr/testify/assert"
+)
+
+type resetServer struct {
+	srvr    *httptest.Server
+	created []map[string]any
+	updated []map[string]any
+}
+
+// newResetServer serves Test_owner/repo00 whose main branch is at forkSHA while upstream is at upSHA.
+func newResetServer(t *testing.T, forkSHA string, upSHA string) *resetServer {
+	t.Helper()
+
+	rsrv := resetServer{}
+
+	rsrv.srvr = newSyncTestServer(t, 1, func(_ http.ResponseWriter, _ *http.Request, _ string) {
+		t.Errorf("merge-upstream should not be called for the reset strategy")
+	})
+
+	rsrv.srvr.Mux.HandleFunc("/repos/Test_owner/repo00", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `{"name":"repo00","fork":true,"default_branch":"main",`+
+			`"parent":{"name":"repo00","owner":{"login":"Test_upstream"},"default_branch":"main"}}`)
+	})
+	handleRef(t, rsrv.srvr, "Test_upstream", "repo00", "main", upSHA)
+
+	rsrv.srvr.Mux.HandleFunc("/repos/Test_owner/repo00/git/ref/heads/main", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(wtr, `{"ref":"refs/heads/main","object":{"sha":%q}}`, forkSHA)
+	})
+
+	rsrv.srvr.Mux.HandleFunc("/repos/Test_owner/repo00/git/refs", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodPost)
+
+		ref := map[string]any{}
+		json.NewDecoder(req.Body).Decode(&ref) //nolint:errcheck  // We don't care about the error here
+		rsrv.created = append(rsrv.created, ref)
+
+		fmt.Fprint(wtr, `{}`)
+	})
+
+	rsrv.srvr.Mux.HandleFunc("/repos/Test_owner/repo00/git/refs/heads/main", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodPatch)
+
+		if len(rsrv.created) == 0 {
+			t.Errorf("the branch was moved before the backup was created")
+		}
+
+		ref := map[string]any{}
+		json.NewDecoder(req.Body).Decode(&ref) //nolint:errcheck  // We don't care about the error here
+		rsrv.updated = append(rsrv.updated, ref)
+
+		fmt.Fprint(wtr, `{}`)
+	})
+
+	return &rsrv
+}
+
+func TestSyncForksResetStrategy(t *testing.T) {
+	rsrv := newResetServer(t, "fork-sha", "upstream-sha")
+	defer rsrv.srvr.Close()
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", rsrv.srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	rpt, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{
+		RepoStrategies: map[string]string{"test_owner/repo00": githubapi.StrategyReset},
+	})
+	if err != nil {
+		t.Fatalf("githubapi.SyncForks returned error: %v", err)
+	}
+
+	if assert.Len(t, rsrv.created, 1) {
+		assert.Equal(t, "fork-sha", rsrv.created[0]["sha"])
+		assert.True(t, strings.HasPrefix(rsrv.created[0]["ref"].(string), "refs/heads/backup/main-"))
+	}
+
+	assert.Equal(t, []map[string]any{{"sha": "upstream-sha", "force": true}}, rsrv.updated)
+
+	backups := rpt.Backups()
+	if assert.Len(t, backups, 1) {
+		assert.Equal(t, githubapi.MergeTypeReset, backups[0].MergeType)
+		assert.Equal(t, rsrv.created[0]["ref"], backups[0].BackupRef)
+		assert.Contains(t, out.String(), "-> Created 1 backup ref(s):\n   Test_owner/repo00 "+backups[0].BackupRef+" (fork-sha)")
+	}
+}
+
+func TestResetBranchIdentical(t *testing.T) {
+	rsrv := newResetServer(t, "same-sha", "same-sha")
+	defer rsrv.srvr.Close()
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", rsrv.srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	res, err := gha.ResetBranch(ctx, "Test_owner", "repo00", "main", false)
+	if err != nil {
+		t.Fatalf("githubapi.ResetBranch returned error: %v", err)
+	}
+
+	assert.Equal(t, githubapi.MergeTypeNone, res.MergeType)
+	assert.Empty(t, rsrv.created)
+	assert.Empty(t, rsrv.updated)
+}
+
+func TestResetBranchDryRun(t *testing.T) {
+	rsrv := newResetServer(t, "fork-sha", "upstream-sha")
+	defer rsrv.srvr.Close()
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", rsrv.srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	res, err := gha.ResetBranch(ctx, "Test_owner", "repo00", "main", true)
+	if err != nil {
+		t.Fatalf("githubapi.ResetBranch returned error: %v", err)
+	}
+
+	assert.True(t, res.Planned)
+	assert.Equal(t, githubapi.MergeTypeReset, res.MergeType)
+	assert.Empty(t, res.BackupRef)
+	assert.Empty(t, rsrv.created)
+	assert.Empty(t, rsrv.updated)
+
+	entry := (&githubapi.SyncReport{Results: []*githubapi.SyncResult{res}}).Plan().Entries[0]
+	assert.True(t, entry.Actionable())
+	assert.Equal(t, "fork-sha", entry.ForkSHA)
+}
+
+func TestValidStrategy(t *testing.T) {
+	assert.True(t, githubapi.ValidStrategy(githubapi.StrategyMerge))
+	assert.True(t, githubapi.ValidStrategy(githubapi.StrategyReset))
+	assert.False(t, githubapi.ValidStrategy("rebase"))
+}
@@ -0,0 +1,180 @@
+package githubapi
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultMaxRetries is how often clients made by NewGitHubAPI retry a request that failed transiently.
+	DefaultMaxRetries = 3
+
+	// retryBaseDelay is the backoff before the first retry, it doubles with every further retry.
+	retryBaseDelay = 500 * time.Millisecond
+
+	// retryMaxDelay caps the backoff, and any Retry-After of a 5xx response.
+	retryMaxDelay = 10 * time.Second
+)
+
+// retryTransport retries requests that failed on a 5xx response, a reset or refused connection or a
+// timeout, with exponential backoff and jitter. Requests that may change something on GitHub, such as
+// the merge-upstream POST, are only retried when the connection could not be made at all, as only then
+// is it certain GitHub never saw them.
+type retryTransport struct {
+	base http.RoundTripper
+	api  *GitHubAPI
+}
+
+func (trn *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	maxRetries := trn.api.MaxRetries
+	send := req
+
+	for attempt := 1; ; attempt++ {
+		resp, err := trn.base.RoundTrip(send)
+
+		reason := retryReason(req, resp, err)
+		if len(reason) == 0 || attempt > maxRetries {
+			return resp, err //nolint:wrapcheck // errors pass through the transport unchanged
+		}
+
+		next, ok := retryRequest(req)
+		if !ok {
+			return resp, err //nolint:wrapcheck // errors pass through the transport unchanged
+		}
+
+		send = next
+
+		delay := retryDelay(attempt, resp)
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body) //nolint:errcheck // the response is dropped for the retry
+			resp.Body.Close()
+		}
+
+		trn.api.retries.Add(1)
+
+		if trn.api.Debug {
+			trn.api.printf("-> Retrying %s %s after %s (attempt %d of %d) in %s\n", req.Method, req.URL.Path, reason,
+				attempt+1, maxRetries+1, delay.Round(time.Millisecond))
+		}
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+
+			return nil, req.Context().Err() //nolint:wrapcheck // the context error is what callers check for
+		case <-timer.C:
+		}
+	}
+}
+
+// retryReason describes why the attempt should be retried, empty when it should not be.
+func retryReason(req *http.Request, resp *http.Response, err error) string {
+	if err != nil {
+		if req.Context().Err() != nil {
+			return ""
+		}
+
+		if !idempotent(req.Method) && !notConnected(err) {
+			return ""
+		}
+
+		if transientError(err) {
+			return err.Error()
+		}
+
+		return ""
+	}
+
+	switch resp.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		if idempotent(req.Method) {
+			return "HTTP " + strconv.Itoa(resp.StatusCode)
+		}
+	}
+
+	return ""
+}
+
+// idempotent reports whether sending a request with method twice has the effect of sending it once.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// notConnected reports whether err happened before a connection to the server existed.
+func notConnected(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+
+	var opErr *net.OpError
+
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func transientError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return notConnected(err) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// retryRequest returns req to be sent again with a fresh body, false when the body cannot be read again.
+// A RoundTripper must not modify req, so a request with a body is cloned and the clone gets the new body.
+func retryRequest(req *http.Request) (*http.Request, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, true
+	}
+
+	if req.GetBody == nil {
+		return nil, false
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+
+	retry := req.Clone(req.Context())
+	retry.Body = body
+
+	return retry, true
+}
+
+// retryDelay returns the backoff before the retry following attempt: half the exponential delay plus a
+// random share of the other half, or the Retry-After of the response when that is longer.
+func retryDelay(attempt int, resp *http.Response) time.Duration {
+	delay := min(retryBaseDelay<<(attempt-1), retryMaxDelay)
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1)) //nolint:gosec // jitter needs no crypto
+
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			delay = max(delay, min(time.Duration(secs)*time.Second, retryMaxDelay))
+		}
+	}
+
+	return delay
+}
+
+// Retries returns the number of requests retried after a transient failure.
+func (api *GitHubAPI) Retries() int64 {
+	return api.retries.Load()
+}
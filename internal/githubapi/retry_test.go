@@ -0,0 +1,208 @@
+package githubapi_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	nethttptest "net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-github/v53/github"
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingOnce answers the first request with fail and the later ones with body, counting the requests.
+func failingOnce(fail func(wtr http.ResponseWriter), body string) (http.Handler, *atomic.Int32) {
+	var calls atomic.Int32
+
+	return http.HandlerFunc(func(wtr http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) == 1 {
+			fail(wtr)
+
+			return
+		}
+
+		fmt.Fprint(wtr, body)
+	}), &calls
+}
+
+func badGateway(wtr http.ResponseWriter) {
+	wtr.WriteHeader(http.StatusBadGateway)
+}
+
+// resetConnection drops the connection without answering.
+func resetConnection(wtr http.ResponseWriter) {
+	conn, _, err := wtr.(http.Hijacker).Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
+
+func newRetryAPI(t *testing.T, url string) (*githubapi.GitHubAPI, *bytes.Buffer) {
+	t.Helper()
+
+	gha, err := githubapi.NewGitHubAPIFromSource(context.Background(), githubapi.StaticTokenSource("token", "test"),
+		url, "")
+	if err != nil {
+		t.Fatalf("githubapi.NewGitHubAPIFromSource returned error: %v", err)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+	gha.Debug = true
+
+	return gha, &out
+}
+
+func TestRetryGet(t *testing.T) {
+	tests := []struct {
+		name       string
+		fail       func(wtr http.ResponseWriter)
+		wantReason string
+	}{
+		{name: "server error", fail: badGateway, wantReason: "after HTTP 502"},
+		{name: "connection reset", fail: resetConnection, wantReason: "EOF"},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			handler, calls := failingOnce(tst.fail, `{"name":"app"}`)
+			srvr := nethttptest.NewServer(handler)
+			defer srvr.Close()
+
+			gha, out := newRetryAPI(t, srvr.URL)
+			assert.Equal(t, githubapi.DefaultMaxRetries, gha.MaxRetries)
+
+			repo, err := gha.GetRepository(context.Background(), "o", "app")
+			if assert.NoError(t, err) {
+				assert.Equal(t, "app", repo.GetName())
+			}
+
+			assert.Equal(t, int32(2), calls.Load())
+			assert.Equal(t, int64(2), gha.APICalls(), "every attempt is counted")
+			assert.Equal(t, int64(1), gha.Retries())
+			assert.Contains(t, out.String(), "-> Retrying GET /api/v3/repos/o/app")
+			assert.Contains(t, out.String(), tst.wantReason)
+			assert.Contains(t, out.String(), "(attempt 2 of 4)")
+		})
+	}
+}
+
+func TestRetryKeepsRequest(t *testing.T) {
+	bodies := make([]string, 0, 2)
+	handler, calls := failingOnce(badGateway, `{}`)
+	srvr := nethttptest.NewServer(http.HandlerFunc(func(wtr http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		bodies = append(bodies, string(body))
+		handler.ServeHTTP(wtr, req)
+	}))
+	defer srvr.Close()
+
+	gha, _ := newRetryAPI(t, srvr.URL)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPut,
+		srvr.URL+"/api/v3/repos/o/app/subscription", strings.NewReader(`{"subscribed":true}`))
+	if err != nil {
+		t.Fatalf("http.NewRequestWithContext returned error: %v", err)
+	}
+
+	body := req.Body
+
+	resp, err := gha.Client.Client().Transport.RoundTrip(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, []string{`{"subscribed":true}`, `{"subscribed":true}`}, bodies, "the retry sends the whole body again")
+	assert.True(t, body == req.Body, "the caller's request must not be modified")
+}
+
+func TestRetryGiveUp(t *testing.T) {
+	srvr := nethttptest.NewServer(http.HandlerFunc(func(wtr http.ResponseWriter, _ *http.Request) {
+		badGateway(wtr)
+	}))
+	defer srvr.Close()
+
+	gha, out := newRetryAPI(t, srvr.URL)
+	gha.MaxRetries = 1
+
+	_, err := gha.GetRepository(context.Background(), "o", "app")
+	assert.ErrorContains(t, err, "502")
+	assert.Equal(t, int64(2), gha.APICalls())
+	assert.Contains(t, out.String(), "(attempt 2 of 2)")
+
+	gha.MaxRetries = 0
+
+	_, err = gha.GetRepository(context.Background(), "o", "app")
+	assert.Error(t, err)
+	assert.Equal(t, int64(3), gha.APICalls(), "zero MaxRetries never retries")
+}
+
+func TestRetryMergeUpstream(t *testing.T) {
+	tests := []struct {
+		name string
+		fail func(wtr http.ResponseWriter)
+	}{
+		{name: "server error", fail: badGateway},
+		{name: "connection reset", fail: resetConnection},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			handler, calls := failingOnce(tst.fail, `{"merge_type":"fast-forward"}`)
+			srvr := nethttptest.NewServer(handler)
+			defer srvr.Close()
+
+			gha, _ := newRetryAPI(t, srvr.URL)
+
+			_, _, err := gha.Client.Repositories.MergeUpstream(context.Background(), "o", "app",
+				&github.RepoMergeUpstreamRequest{Branch: github.String("main")})
+			assert.Error(t, err, "the merge may have happened, so it is not retried")
+			assert.Equal(t, int32(1), calls.Load())
+			assert.Equal(t, int64(0), gha.Retries())
+		})
+	}
+}
+
+func TestRetryMergeUpstreamNotConnected(t *testing.T) {
+	// a closed listener refuses the connection, so GitHub never saw the request
+	lsnr, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen returned error: %v", err)
+	}
+
+	url := "http://" + lsnr.Addr().String()
+	lsnr.Close()
+
+	gha, out := newRetryAPI(t, url)
+	gha.MaxRetries = 1
+
+	_, _, err = gha.Client.Repositories.MergeUpstream(context.Background(), "o", "app",
+		&github.RepoMergeUpstreamRequest{Branch: github.String("main")})
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), gha.Retries())
+	assert.Contains(t, out.String(), "-> Retrying POST /api/v3/repos/o/app/merge-upstream")
+}
+
+func TestRetryCancel(t *testing.T) {
+	srvr := nethttptest.NewServer(http.HandlerFunc(func(wtr http.ResponseWriter, _ *http.Request) {
+		badGateway(wtr)
+	}))
+	defer srvr.Close()
+
+	gha, _ := newRetryAPI(t, srvr.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := gha.GetRepository(ctx, "o", "app")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int64(0), gha.Retries())
+}
@@ -0,0 +1,191 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v53/github"
+)
+
+// MergeTypeRollback is reported when a fork branch was, or in a dry-run would be, moved back.
+const MergeTypeRollback = "rollback"
+
+// RollbackEntry is the pre-sync state of a fork branch changed by a run.
+type RollbackEntry struct {
+	Owner  string `json:"owner"`
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+
+	// SHA is the head before the run, empty for a branch the run created.
+	SHA string `json:"sha"`
+
+	// Action is the MergeType the run reported for the branch.
+	Action string `json:"action"`
+
+	// Head is the head the run left the branch at, empty when unknown. A branch that moved on since
+	// is only rolled back when forced.
+	Head string `json:"head,omitempty"`
+}
+
+// RollbackEntries returns the branches the report changed together with their pre-sync heads.
+// Branches without a recorded pre-sync head are left out since they cannot be restored.
+func (rpt *SyncReport) RollbackEntries() []*RollbackEntry {
+	entries := make([]*RollbackEntry, 0)
+
+	for _, res := range rpt.Results {
+		if res.Planned || res.Err != nil {
+			continue
+		}
+
+		switch res.MergeType {
+		case MergeTypeFastForward, MergeTypeMerge, MergeTypeReset:
+			if len(res.ForkSHA) == 0 {
+				continue
+			}
+		case MergeTypeCreated:
+		default:
+			continue
+		}
+
+		entries = append(entries, &RollbackEntry{
+			Owner:  res.Owner,
+			Repo:   res.Repo,
+			Branch: res.Branch,
+			SHA:    res.ForkSHA,
+			Action: res.MergeType,
+			Head:   res.HeadSHA,
+		})
+	}
+
+	return entries
+}
+
+// noteHead reads the head a merge left the branch at into res.HeadSHA. A head that cannot be read
+// is left empty, the branch can then only be rolled back when forced.
+func (api *GitHubAPI) noteHead(ctx context.Context, res *SyncResult) {
+	if res.Err != nil || (res.MergeType != MergeTypeFastForward && res.MergeType != MergeTypeMerge) {
+		return
+	}
+
+	if sha, err := api.GetBranchSHA(ctx, res.Owner, res.Repo, res.Branch); err == nil {
+		res.HeadSHA = sha
+	}
+}
+
+// DeleteBranch deletes the branch from the repository.
+func (api *GitHubAPI) DeleteBranch(ctx context.Context, owner string, repo string, branch string) error {
+	_, err := withRateLimitNoValue(ctx, api, func() (*github.Response, error) {
+		return api.Client.Git.DeleteRef(ctx, owner, repo, "heads/"+branch)
+	})
+	if err != nil {
+		return fmt.Errorf("api.client.Git.DeleteRef error: %w", err)
+	}
+
+	return nil
+}
+
+// Rollback force-moves every branch back to its recorded SHA and deletes branches the run created.
+// Branches no longer at the head the run left them at fail instead, unless opts.Force is set, so
+// commits pushed after the run are not lost. With opts.DryRun set only the current heads are read
+// and the intended moves are reported.
+func (api *GitHubAPI) Rollback(ctx context.Context, entries []*RollbackEntry, opts SyncOptions) (*SyncReport, error) {
+	rpt := SyncReport{
+		Results: make([]*SyncResult, 0, len(entries)),
+	}
+
+	for _, entry := range entries {
+		res := api.rollbackEntry(ctx, entry, opts.DryRun, opts.Force)
+		rpt.Results = append(rpt.Results, res)
+		res.write(api, opts.Verbose)
+
+		if res.Err != nil && !opts.ContinueOnError {
+			return &rpt, fmt.Errorf("rollbackEntry error: %w", res.Err)
+		}
+	}
+
+	if ferr := rpt.Err(); ferr != nil {
+		api.writeSummary(&rpt)
+
+		return &rpt, ferr
+	}
+
+	return &rpt, nil
+}
+
+func (api *GitHubAPI) rollbackEntry(ctx context.Context, entry *RollbackEntry, dryRun bool,
+	force bool) *SyncResult {
+	res := SyncResult{
+		Owner:   entry.Owner,
+		Repo:    entry.Repo,
+		Branch:  entry.Branch,
+		Planned: dryRun,
+	}
+
+	fail := func(format string, err error) *SyncResult {
+		res.Status, res.Message = errorStatus(err)
+		res.Err = fmt.Errorf(format, err)
+
+		return &res
+	}
+
+	current, err := api.GetBranchSHA(ctx, entry.Owner, entry.Repo, entry.Branch)
+	if err != nil {
+		return fail("GetBranchSHA error: %w", err)
+	}
+
+	res.ForkSHA = current
+	res.MergeType = MergeTypeRollback
+
+	if current != entry.SHA && current != entry.Head && !force {
+		res.MergeType = ""
+
+		if len(entry.Head) == 0 {
+			res.Err = fmt.Errorf("the run did not record the head it left %s at, pass -force to roll back anyway",
+				entry.Branch)
+		} else {
+			res.Err = fmt.Errorf("%s moved on to %s since the run left it at %s, pass -force to roll back anyway",
+				entry.Branch, shortSHA(current), shortSHA(entry.Head))
+		}
+
+		res.Message = res.Err.Error()
+
+		return &res
+	}
+
+	if len(entry.SHA) == 0 {
+		if dryRun {
+			res.Message = fmt.Sprintf("would delete branch created by the run (at %s)", shortSHA(current))
+
+			return &res
+		}
+
+		if derr := api.DeleteBranch(ctx, entry.Owner, entry.Repo, entry.Branch); derr != nil {
+			return fail("DeleteBranch error: %w", derr)
+		}
+
+		res.Message = fmt.Sprintf("deleted branch created by the run (was at %s)", shortSHA(current))
+
+		return &res
+	}
+
+	if current == entry.SHA {
+		res.MergeType = MergeTypeNone
+		res.Message = fmt.Sprintf("is already at %s", shortSHA(entry.SHA))
+
+		return &res
+	}
+
+	if dryRun {
+		res.Message = fmt.Sprintf("would move %s -> %s", shortSHA(current), shortSHA(entry.SHA))
+
+		return &res
+	}
+
+	if uerr := api.UpdateBranch(ctx, entry.Owner, entry.Repo, entry.Branch, entry.SHA, true); uerr != nil {
+		return fail("UpdateBranch error: %w", uerr)
+	}
+
+	res.Message = fmt.Sprintf("rolled back %s -> %s", shortSHA(current), shortSHA(entry.SHA))
+
+	return &res
+}
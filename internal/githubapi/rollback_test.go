@@ -0,0 +1,174 @@
+package githubapi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/http/httptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncReportRollbackEntries(t *testing.T) {
+	rpt := githubapi.SyncReport{
+		Results: []*githubapi.SyncResult{
+			{Owner: "o", Repo: "a", Branch: "main", MergeType: githubapi.MergeTypeMerge, ForkSHA: "1", HeadSHA: "11"},
+			{Owner: "o", Repo: "b", Branch: "main", MergeType: githubapi.MergeTypeMerge},
+			{Owner: "o", Repo: "c", Branch: "main", MergeType: githubapi.MergeTypeReset, ForkSHA: "3", Planned: true},
+			{Owner: "o", Repo: "d", Branch: "main", MergeType: githubapi.MergeTypeReset, ForkSHA: "4"},
+			{Owner: "o", Repo: "e", Branch: "dev", MergeType: githubapi.MergeTypeCreated},
+			{Owner: "o", Repo: "f", Branch: "main", ForkSHA: "6", Err: assert.AnError},
+		},
+	}
+
+	assert.Equal(t, []*githubapi.RollbackEntry{
+		{Owner: "o", Repo: "a", Branch: "main", SHA: "1", Action: githubapi.MergeTypeMerge, Head: "11"},
+		{Owner: "o", Repo: "d", Branch: "main", SHA: "4", Action: githubapi.MergeTypeReset},
+		{Owner: "o", Repo: "e", Branch: "dev", Action: githubapi.MergeTypeCreated},
+	}, rpt.RollbackEntries())
+}
+
+func newRollbackTestServer(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+
+	handleRef(t, srvr, "Test_owner", "repo_a", "main", "merged")
+	handleRef(t, srvr, "Test_owner", "repo_b", "main", "same")
+	handleRef(t, srvr, "Test_owner", "repo_c", "dev", "created")
+
+	calls := make([]string, 0)
+
+	srvr.Mux.HandleFunc("/repos/Test_owner/repo_a/git/refs/heads/main", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodPatch)
+
+		body := map[string]interface{}{}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Errorf("decode error: %v", err)
+		}
+
+		assert.Equal(t, "before", body["sha"])
+		assert.Equal(t, true, body["force"])
+
+		calls = append(calls, req.Method+" "+req.URL.Path)
+		wtr.Write([]byte(`{"ref":"refs/heads/main","object":{"sha":"before"}}`)) //nolint:errcheck  // test
+	})
+
+	srvr.Mux.HandleFunc("/repos/Test_owner/repo_c/git/refs/heads/dev", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodDelete)
+
+		calls = append(calls, req.Method+" "+req.URL.Path)
+		wtr.WriteHeader(http.StatusNoContent)
+	})
+
+	return srvr, &calls
+}
+
+func rollbackTestEntries() []*githubapi.RollbackEntry {
+	return []*githubapi.RollbackEntry{
+		{Owner: "Test_owner", Repo: "repo_a", Branch: "main", SHA: "before", Action: githubapi.MergeTypeMerge, Head: "merged"},
+		{Owner: "Test_owner", Repo: "repo_b", Branch: "main", SHA: "same", Action: githubapi.MergeTypeFastForward, Head: "up"},
+		{Owner: "Test_owner", Repo: "repo_c", Branch: "dev", Action: githubapi.MergeTypeCreated, Head: "created"},
+	}
+}
+
+func TestRollback(t *testing.T) {
+	srvr, calls := newRollbackTestServer(t)
+	defer srvr.Close()
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	rpt, err := gha.Rollback(ctx, rollbackTestEntries(), githubapi.SyncOptions{})
+	if err != nil {
+		t.Fatalf("githubapi.Rollback returned error: %v", err)
+	}
+
+	assert.Equal(t, []string{
+		"PATCH /repos/Test_owner/repo_a/git/refs/heads/main",
+		"DELETE /repos/Test_owner/repo_c/git/refs/heads/dev",
+	}, *calls)
+
+	if assert.Len(t, rpt.Results, 3) {
+		assert.Equal(t, githubapi.MergeTypeRollback, rpt.Results[0].MergeType)
+		assert.Equal(t, githubapi.MergeTypeNone, rpt.Results[1].MergeType)
+		assert.Equal(t, githubapi.MergeTypeRollback, rpt.Results[2].MergeType)
+	}
+
+	assert.Contains(t, out.String(), "rolled back merged -> before")
+	assert.Contains(t, out.String(), "deleted branch created by the run")
+}
+
+func TestRollbackDryRun(t *testing.T) {
+	srvr, calls := newRollbackTestServer(t)
+	defer srvr.Close()
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	_, err := gha.Rollback(ctx, rollbackTestEntries(), githubapi.SyncOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("githubapi.Rollback returned error: %v", err)
+	}
+
+	assert.Empty(t, *calls)
+	assert.Contains(t, out.String(), "[dry-run] would move merged -> before")
+	assert.Contains(t, out.String(), "[dry-run] would delete branch created by the run")
+}
+
+func TestRollbackMovedOn(t *testing.T) {
+	srvr, calls := newRollbackTestServer(t)
+	defer srvr.Close()
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	gha.Out = &bytes.Buffer{}
+
+	// a commit was pushed to main after the run left it at "synced"
+	entries := []*githubapi.RollbackEntry{
+		{Owner: "Test_owner", Repo: "repo_a", Branch: "main", SHA: "before", Action: githubapi.MergeTypeMerge, Head: "synced"},
+		{Owner: "Test_owner", Repo: "repo_c", Branch: "dev", Action: githubapi.MergeTypeCreated},
+	}
+
+	rpt, err := gha.Rollback(ctx, entries, githubapi.SyncOptions{ContinueOnError: true})
+	assert.ErrorIs(t, err, githubapi.ErrSyncFailures)
+	assert.Empty(t, *calls, "branches that moved on are left alone")
+
+	if assert.Len(t, rpt.Results, 2) {
+		assert.EqualError(t, rpt.Results[0].Err,
+			"main moved on to merged since the run left it at synced, pass -force to roll back anyway")
+		assert.EqualError(t, rpt.Results[1].Err,
+			"the run did not record the head it left dev at, pass -force to roll back anyway")
+	}
+
+	_, err = gha.Rollback(ctx, entries, githubapi.SyncOptions{Force: true})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			"PATCH /repos/Test_owner/repo_a/git/refs/heads/main",
+			"DELETE /repos/Test_owner/repo_c/git/refs/heads/dev",
+		}, *calls)
+	}
+}
@@ -0,0 +1,173 @@
+package githubapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v53/github"
+)
+
+// ErrNoForks is returned by FirstFork when the token sees no fork to sync.
+var ErrNoForks = errors.New("no forks found")
+
+// forkSource is an account whose repositories are listed for forks. The authenticated user's own
+// are listed through /user/repos, as /users/{login}/repos returns public repositories only.
+type forkSource struct {
+	owner         string
+	org           bool
+	authenticated bool
+}
+
+// listSources returns the accounts whose forks are listed for userName, the authenticated user when
+// empty, and opts. The user is left out with opts.OrgsOnly.
+func (api *GitHubAPI) listSources(ctx context.Context, userName string, opts SyncOptions) ([]forkSource, error) {
+	if opts.OrgsOnly {
+		return api.forkSources(ctx, "", opts)
+	}
+
+	user, _, err := withRateLimit(ctx, api, func() (*github.User, *github.Response, error) {
+		return api.Client.Users.Get(ctx, userName)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("api.client.Users.Get error: %w", err)
+	}
+
+	sources, err := api.forkSources(ctx, user.GetLogin(), opts)
+	if err == nil && len(userName) == 0 {
+		sources[0].authenticated = true
+	}
+
+	return sources, err
+}
+
+// forkSources returns the user, unless login is empty, followed by the selected organizations, each
+// listed once.
+func (api *GitHubAPI) forkSources(ctx context.Context, login string, opts SyncOptions) ([]forkSource, error) {
+	sources := make([]forkSource, 0, 1+len(opts.Orgs))
+	seen := map[string]bool{}
+
+	if len(login) > 0 {
+		sources = append(sources, forkSource{owner: login, org: false, authenticated: false})
+	}
+
+	add := func(org string) {
+		if key := strings.ToLower(org); !seen[key] {
+			seen[key] = true
+			sources = append(sources, forkSource{owner: org, org: true, authenticated: false})
+		}
+	}
+
+	for _, org := range opts.Orgs {
+		add(org)
+	}
+
+	if !opts.AllOrgs {
+		return sources, nil
+	}
+
+	for page := 1; ; page++ {
+		orgs, err := api.ListOrganizations(ctx, "", &github.ListOptions{Page: page, PerPage: 100})
+		if err != nil {
+			return nil, fmt.Errorf("ListOrganizations error: %w", err)
+		}
+
+		if len(orgs) == 0 {
+			return sources, nil
+		}
+
+		for _, org := range orgs {
+			add(org.GetLogin())
+		}
+	}
+}
+
+// listSourcePage returns a single page of the source's repositories, organizations are asked for forks only.
+func (api *GitHubAPI) listSourcePage(ctx context.Context, src forkSource, page int,
+	perPage int) ([]*github.Repository, error) {
+	lopts := github.ListOptions{
+		Page:    page,
+		PerPage: perPage,
+	}
+
+	if src.org {
+		repos, err := api.ListOrgRepositories(ctx, src.owner, &github.RepositoryListByOrgOptions{
+			Type:        "forks",
+			ListOptions: lopts,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("ListOrgRepositories error: %w", err)
+		}
+
+		return repos, nil
+	}
+
+	if src.authenticated {
+		//nolint:exhaustruct // defaults are desired except for affiliation and paging
+		repos, err := api.ListRepositories(ctx, "", &github.RepositoryListOptions{
+			Affiliation: "owner",
+			ListOptions: lopts,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("ListRepositories error: %w", err)
+		}
+
+		return repos, nil
+	}
+
+	//nolint:exhaustruct // defaults are desired except for paging
+	repos, err := api.ListRepositories(ctx, src.owner, &github.RepositoryListOptions{ListOptions: lopts})
+	if err != nil {
+		return nil, fmt.Errorf("ListRepositories error: %w", err)
+	}
+
+	return repos, nil
+}
+
+// FirstFork returns the first fork SyncForks would list for userName, before any filter is applied. It
+// returns ErrNoForks when no fork is visible to the token.
+func (api *GitHubAPI) FirstFork(ctx context.Context, userName string, opts SyncOptions) (*github.Repository, error) {
+	for _, name := range opts.Repos {
+		owner, repo, _ := strings.Cut(name, "/")
+
+		repository, err := api.GetRepository(ctx, owner, repo)
+		if err != nil {
+			return nil, fmt.Errorf("GetRepository %s error: %w", name, err)
+		}
+
+		if repository.GetFork() {
+			return repository, nil
+		}
+	}
+
+	if len(opts.Repos) > 0 {
+		return nil, ErrNoForks
+	}
+
+	sources, err := api.listSources(ctx, userName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, src := range sources {
+		for page := 1; ; page++ {
+			repos, lerr := api.listSourcePage(ctx, src, page, 100)
+			if lerr != nil {
+				return nil, lerr
+			}
+
+			if len(repos) == 0 {
+				break
+			}
+
+			for _, repo := range repos {
+				if repo.GetFork() {
+					return repo, nil
+				}
+			}
+		}
+	}
+
+	return nil, ErrNoForks
+}
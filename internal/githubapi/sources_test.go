@@ -0,0 +1,352 @@
+package githubapi_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/http/httptest"
+	"github.com/stretchr/testify/assert"
+)
+
+// newOrgTestServer serves the user's own fork plus forks in the Acme and Widgets organizations,
+// Widgets/readonly is not writable. Merged repositories are recorded as owner/name.
+func newOrgTestServer(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+
+	repo := func(owner string, name string, push bool) string {
+		return fmt.Sprintf(`{"owner":{"login":%q},"name":%q,"fork":true,"default_branch":"main",`+
+			`"permissions":{"pull":true,"push":%t}}`, owner, name, push)
+	}
+
+	pages := map[string]string{
+		"/user/repos":         "[" + repo("Test_owner", "mine", true) + "]",
+		"/orgs/Acme/repos":    "[" + repo("Acme", "tool", true) + "]",
+		"/orgs/Widgets/repos": "[" + repo("Widgets", "readonly", false) + "," + repo("Widgets", "gadget", true) + "]",
+	}
+
+	srvr.Mux.HandleFunc("/user", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `{"login":"Test_owner"}`)
+	})
+
+	srvr.Mux.HandleFunc("/user/orgs", func(wtr http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("page") == "1" {
+			fmt.Fprint(wtr, `[{"login":"Acme"},{"login":"Widgets"}]`)
+		} else {
+			fmt.Fprint(wtr, `[]`)
+		}
+	})
+
+	for path, page := range pages {
+		page := page
+		org := strings.HasPrefix(path, "/orgs/")
+
+		srvr.Mux.HandleFunc(path, func(wtr http.ResponseWriter, req *http.Request) {
+			testMethod(t, req, http.MethodGet)
+
+			if org {
+				assert.Equal(t, "forks", req.URL.Query().Get("type"))
+			}
+
+			if req.URL.Query().Get("page") == "1" {
+				fmt.Fprint(wtr, page)
+			} else {
+				fmt.Fprint(wtr, `[]`)
+			}
+		})
+	}
+
+	merged := make([]string, 0)
+
+	srvr.Mux.HandleFunc("/repos/", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodPost)
+
+		parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/repos/"), "/")
+		merged = append(merged, parts[0]+"/"+parts[1])
+
+		// the forks of Acme are up to date, only verbose output shows them
+		mergeType := "merge"
+		if parts[0] == "Acme" {
+			mergeType = "none"
+		}
+
+		fmt.Fprintf(wtr, `{"message":"ok","merge_type":%q}`, mergeType)
+	})
+
+	return srvr, &merged
+}
+
+func TestSyncForksOrgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       githubapi.SyncOptions
+		wantMerged []string
+	}{
+		{
+			name:       "user only",
+			wantMerged: []string{"Test_owner/mine"},
+		},
+		{
+			name:       "org",
+			opts:       githubapi.SyncOptions{Orgs: []string{"Widgets"}},
+			wantMerged: []string{"Test_owner/mine", "Widgets/gadget"},
+		},
+		{
+			name:       "all orgs",
+			opts:       githubapi.SyncOptions{Orgs: []string{"Widgets"}, AllOrgs: true},
+			wantMerged: []string{"Test_owner/mine", "Widgets/gadget", "Acme/tool"},
+		},
+		{
+			name:       "orgs only",
+			opts:       githubapi.SyncOptions{Orgs: []string{"Widgets"}, OrgsOnly: true},
+			wantMerged: []string{"Widgets/gadget"},
+		},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			srvr, merged := newOrgTestServer(t)
+			defer srvr.Close()
+
+			ctx := context.Background()
+			gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+			if nerr != nil {
+				t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+			}
+
+			gha.Out = &bytes.Buffer{}
+
+			_, err := gha.SyncForks(ctx, "", tst.opts)
+			if err != nil {
+				t.Fatalf("githubapi.SyncForks returned error: %v", err)
+			}
+
+			assert.Equal(t, tst.wantMerged, *merged)
+		})
+	}
+}
+
+func TestSyncForksOrgsGroupedOutput(t *testing.T) {
+	srvr, _ := newOrgTestServer(t)
+	defer srvr.Close()
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	_, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{Orgs: []string{"Acme", "Widgets"}, Verbose: true})
+	if err != nil {
+		t.Fatalf("githubapi.SyncForks returned error: %v", err)
+	}
+
+	assert.Equal(t, strings.Join([]string{
+		"-> Repo 'Widgets/readonly main' is not writable with this token, skipping...",
+		"== Test_owner ==",
+		"-> Repo 'Test_owner/mine main' ok",
+		"== Acme ==",
+		"-> Repo 'Acme/tool main' ok",
+		"== Widgets ==",
+		"-> Repo 'Widgets/gadget main' ok",
+	}, "\n")+"\n", out.String())
+
+	out.Reset()
+
+	_, err = gha.SyncForks(ctx, "", githubapi.SyncOptions{Orgs: []string{"Acme", "Widgets"}})
+	if err != nil {
+		t.Fatalf("githubapi.SyncForks returned error: %v", err)
+	}
+
+	assert.Equal(t, strings.Join([]string{
+		"== Test_owner ==",
+		"-> Repo 'Test_owner/mine main' ok",
+		"== Widgets ==",
+		"-> Repo 'Widgets/gadget main' ok",
+	}, "\n")+"\n", out.String(), "an owner without any line printed gets no header")
+}
+
+func TestSyncForksOrgsListError(t *testing.T) {
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+	defer srvr.Close()
+
+	srvr.Mux.HandleFunc("/user", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `{"login":"Test_owner"}`)
+	})
+
+	srvr.Mux.HandleFunc("/user/orgs", func(wtr http.ResponseWriter, _ *http.Request) {
+		wtr.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(wtr, `{"message":"Resource not accessible by integration"}`)
+	})
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	_, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{AllOrgs: true})
+	assert.ErrorContains(t, err, "ListOrganizations error")
+}
+
+func TestSyncForksNamedRepos(t *testing.T) {
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+	defer srvr.Close()
+
+	srvr.Mux.HandleFunc("/user", func(_ http.ResponseWriter, _ *http.Request) {
+		t.Errorf("the user should not be looked up for named repositories")
+	})
+
+	merged := make([]string, 0)
+
+	srvr.Mux.HandleFunc("/repos/", func(wtr http.ResponseWriter, req *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/repos/"), "/")
+
+		switch {
+		case len(parts) == 2 && parts[1] == "missing":
+			http.NotFound(wtr, req)
+		case len(parts) == 2:
+			testMethod(t, req, http.MethodGet)
+			fmt.Fprintf(wtr, `{"owner":{"login":%q},"name":%q,"fork":%t,"default_branch":"trunk"}`,
+				parts[0], parts[1], parts[1] != "original")
+		default:
+			testMethod(t, req, http.MethodPost)
+			merged = append(merged, parts[0]+"/"+parts[1])
+			fmt.Fprint(wtr, `{"message":"ok","merge_type":"merge"}`)
+		}
+	})
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	rpt, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{
+		Repos:   []string{"Other/lib", "Test_owner/original", "Test_owner/app", "other/LIB"},
+		Verbose: true,
+	})
+	if err != nil {
+		t.Fatalf("githubapi.SyncForks returned error: %v", err)
+	}
+
+	assert.Equal(t, []string{"Other/lib", "Test_owner/app"}, merged)
+
+	if assert.Len(t, rpt.Results, 2) {
+		assert.Equal(t, "trunk", rpt.Results[0].Branch)
+	}
+
+	assert.Contains(t, out.String(), "-> Repo 'Test_owner/original trunk' is not a fork, skipping...")
+
+	_, err = gha.SyncForks(ctx, "", githubapi.SyncOptions{Repos: []string{"Test_owner/missing"}})
+	assert.ErrorContains(t, err, "GetRepository Test_owner/missing error")
+}
+
+func TestSyncForksOtherUser(t *testing.T) {
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+	defer srvr.Close()
+
+	srvr.Mux.HandleFunc("/users/bot", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `{"login":"Bot"}`)
+	})
+
+	srvr.Mux.HandleFunc("/users/Bot/repos", func(wtr http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("page") == "1" {
+			fmt.Fprint(wtr, `[{"owner":{"login":"Bot"},"name":"fork","fork":true,"default_branch":"main"}]`)
+		} else {
+			fmt.Fprint(wtr, `[]`)
+		}
+	})
+
+	srvr.Mux.HandleFunc("/repos/Bot/fork/merge-upstream", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodPost)
+		fmt.Fprint(wtr, `{"message":"ok","merge_type":"none"}`)
+	})
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	gha.Out = &bytes.Buffer{}
+
+	rpt, err := gha.SyncForks(ctx, "bot", githubapi.SyncOptions{})
+	if err != nil {
+		t.Fatalf("githubapi.SyncForks returned error: %v", err)
+	}
+
+	if assert.Len(t, rpt.Results, 1) {
+		assert.Equal(t, "Bot/fork", rpt.Results[0].FullName())
+	}
+}
+
+func TestFirstFork(t *testing.T) {
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+	defer srvr.Close()
+
+	srvr.Mux.HandleFunc("/users/octocat", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `{"login":"octocat"}`)
+	})
+	srvr.Mux.HandleFunc("/users/octocat/repos", func(wtr http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("page") == "1" {
+			fmt.Fprint(wtr, `[{"name":"own","fork":false}]`)
+
+			return
+		}
+
+		fmt.Fprint(wtr, `[]`)
+	})
+	srvr.Mux.HandleFunc("/orgs/Widgets/repos", func(wtr http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "forks", req.URL.Query().Get("type"))
+		fmt.Fprint(wtr, `[{"name":"lib","fork":true,"owner":{"login":"Widgets"}}]`)
+	})
+	srvr.Mux.HandleFunc("/repos/octocat/own", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `{"name":"own","fork":false}`)
+	})
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	fork, err := gha.FirstFork(ctx, "octocat", githubapi.SyncOptions{Orgs: []string{"Widgets"}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "lib", fork.GetName())
+	}
+
+	_, err = gha.FirstFork(ctx, "octocat", githubapi.SyncOptions{})
+	assert.ErrorIs(t, err, githubapi.ErrNoForks)
+
+	_, err = gha.FirstFork(ctx, "", githubapi.SyncOptions{Repos: []string{"octocat/own"}})
+	assert.ErrorIs(t, err, githubapi.ErrNoForks)
+}
@@ -0,0 +1,532 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v53/github"
+	"github.com/mjdusa/github-fork-update/internal/pattern"
+)
+
+const (
+	// MergeTypeNone is the merge type GitHub reports when the fork is already up to date.
+	MergeTypeNone = "none"
+)
+
+// SyncOptions controls how SyncForks walks and updates the forks.
+type SyncOptions struct {
+	// Concurrency is the number of forks merged in parallel, values below 1 merge one fork at a time.
+	Concurrency int
+
+	// ContinueOnError records failed forks and keeps going instead of stopping at the first failure.
+	ContinueOnError bool
+
+	// DryRun plans every fork with the compare API instead of calling merge-upstream.
+	DryRun bool
+
+	// Branches selects additional branches, present in both fork and upstream, to sync after the
+	// default branch. RepoBranches replaces it for the lower-cased owner/name keys it contains.
+	Branches     pattern.List
+	RepoBranches map[string]pattern.List
+
+	// CreateBranches opts in to creating upstream branches matching these patterns that the fork lacks.
+	CreateBranches pattern.List
+
+	// ConflictPullRequest opens, or updates, a sync pull request when merge-upstream reports a conflict.
+	ConflictPullRequest bool
+
+	// Strategy is the per-run sync strategy, StrategyMerge when empty. RepoStrategies replaces it
+	// for the lower-cased owner/name keys it contains.
+	Strategy       string
+	RepoStrategies map[string]string
+
+	// Tags controls mirroring upstream tags into the forks.
+	Tags TagOptions
+
+	// Orgs lists organizations whose forks are synced after the user's own, AllOrgs adds every
+	// organization the user belongs to. Only forks the token can push to are synced. OrgsOnly leaves
+	// out the user, for tokens that belong to none such as GitHub App installation tokens.
+	Orgs     []string
+	AllOrgs  bool
+	OrgsOnly bool
+
+	// Repos names the owner/name forks to sync instead of listing the user's and organizations' repositories.
+	Repos []string
+
+	// Discovery selects how the forks of the user and organizations are listed, DiscoveryREST when empty.
+	// DiscoveryGraphQL falls back to REST when the GraphQL API fails.
+	Discovery string
+
+	// SkipRepos holds the lower-cased owner/name of forks that are never synced, Filter selects
+	// among the remaining forks.
+	SkipRepos map[string]bool
+	Filter    ForkFilter
+
+	// RecordHistory reads every branch head before merging, and after, so the run can later be rolled back.
+	RecordHistory bool
+
+	// Force rolls back branches that moved on since the run, discarding the commits pushed after it.
+	Force bool
+
+	Verbose bool
+	Debug   bool
+}
+
+// SyncResult is the outcome of syncing a single fork branch.
+type SyncResult struct {
+	Owner     string
+	Repo      string
+	Branch    string
+	MergeType string
+	Message   string
+
+	// Planned is set when the result comes from a dry-run rather than a merge.
+	Planned bool
+
+	UpstreamOwner  string
+	UpstreamRepo   string
+	UpstreamBranch string
+	UpstreamSHA    string
+	ForkSHA        string
+	AheadBy        int
+	BehindBy       int
+
+	// BackupRef is the ref holding the previous fork head when a reset moved the branch.
+	BackupRef string
+
+	// HeadSHA is the head the branch was left at, empty when unknown.
+	HeadSHA string
+
+	// Status is the HTTP status code of a failed API call, zero when unknown or successful.
+	Status int
+	Err    error
+}
+
+type forkJob struct {
+	index  int
+	owner  string
+	name   string
+	branch string
+
+	// level is the head the default branch shares with the parent's, when discovery saw them level.
+	level         string
+	upstreamOwner string
+	upstreamName  string
+}
+
+type forkResult struct {
+	index   int
+	results []*SyncResult
+}
+
+func (opts *SyncOptions) workers() int {
+	if opts.Concurrency < 1 {
+		return 1
+	}
+
+	return opts.Concurrency
+}
+
+// FullName returns the owner/name form of the fork.
+func (res *SyncResult) FullName() string {
+	return res.Owner + "/" + res.Repo
+}
+
+// UpstreamFullName returns the owner/name:branch form of the upstream branch.
+func (res *SyncResult) UpstreamFullName() string {
+	return res.UpstreamOwner + "/" + res.UpstreamRepo + ":" + res.UpstreamBranch
+}
+
+// visible reports whether write prints a line for the result, branches with nothing to do are only
+// shown when verbose.
+func (res *SyncResult) visible(verbose bool) bool {
+	return verbose || res.Err != nil || res.Planned || (len(res.MergeType) > 0 && res.MergeType != MergeTypeNone)
+}
+
+func (res *SyncResult) write(api *GitHubAPI, verbose bool) {
+	if !res.visible(verbose) {
+		return
+	}
+
+	if res.Err != nil {
+		api.printf("-> Repo '%s %s' error: %v\n", res.FullName(), res.Branch, res.Err)
+		return
+	}
+
+	if res.Planned {
+		api.printf("-> Repo '%s %s' [dry-run] %s\n", res.FullName(), res.Branch, res.Message)
+		return
+	}
+
+	api.printf("-> Repo '%s %s' %s\n", res.FullName(), res.Branch, res.Message)
+}
+
+// SyncForks merges upstream into every fork owned by userName (the authenticated user when empty),
+// or into the forks named by opts.Repos without listing any account.
+// Repositories are listed sequentially while merges are fanned out to opts.Concurrency workers,
+// the collected results are printed in listing order once every worker has finished.
+func (api *GitHubAPI) SyncForks(ctx context.Context, userName string, opts SyncOptions) (*SyncReport, error) {
+	var sources []forkSource
+
+	if len(opts.Repos) == 0 {
+		srcs, serr := api.listSources(ctx, userName, opts)
+		if serr != nil {
+			return nil, serr
+		}
+
+		sources = srcs
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan forkJob)
+	results := make(chan forkResult)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < opts.workers(); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for job := range jobs {
+				results <- forkResult{index: job.index, results: api.syncFork(ctx, job, opts)}
+			}
+		}()
+	}
+
+	collected := make([][]*SyncResult, 0)
+	var firstErr error
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for fres := range results {
+			for len(collected) <= fres.index {
+				collected = append(collected, nil)
+			}
+
+			collected[fres.index] = fres.results
+
+			for _, res := range fres.results {
+				if res.Err != nil && firstErr == nil && !opts.ContinueOnError {
+					firstErr = res.Err
+					cancel()
+				}
+			}
+		}
+	}()
+
+	lerr := api.listForks(ctx, sources, opts, jobs)
+
+	close(jobs)
+	wg.Wait()
+	close(results)
+	<-done
+
+	rpt := SyncReport{
+		Results: make([]*SyncResult, 0, len(collected)),
+	}
+
+	grouped := len(opts.Orgs) > 0 || opts.AllOrgs
+	owner := ""
+
+	for _, fork := range collected {
+		for _, res := range fork {
+			// owners whose forks print nothing get no header either
+			if grouped && res.Owner != owner && res.visible(opts.Verbose) {
+				owner = res.Owner
+				api.printf("== %s ==\n", owner)
+			}
+
+			rpt.Results = append(rpt.Results, res)
+			res.write(api, opts.Verbose)
+		}
+	}
+
+	if created := rpt.WithMergeType(MergeTypeCreated); len(created) > 0 {
+		api.printf("-> Created %d branch(es) from upstream\n", len(created))
+	}
+
+	api.writeBackups(&rpt)
+
+	if opts.Tags.Enabled && !opts.DryRun {
+		api.printf("-> Tags: %d created, %d deleted\n", len(rpt.WithMergeType(MergeTypeTagCreated)),
+			len(rpt.WithMergeType(MergeTypeTagDeleted)))
+	}
+
+	if firstErr != nil {
+		return &rpt, fmt.Errorf("MergeUpstreamFork error: %w", firstErr)
+	}
+
+	if lerr != nil {
+		return &rpt, lerr
+	}
+
+	if ferr := rpt.Err(); ferr != nil {
+		api.writeSummary(&rpt)
+
+		return &rpt, ferr
+	}
+
+	return &rpt, nil
+}
+
+// listForks queues each selected fork on jobs until the listing is exhausted or ctx is canceled. The
+// forks are the named opts.Repos when set, otherwise the repositories of every source.
+func (api *GitHubAPI) listForks(ctx context.Context, sources []forkSource, opts SyncOptions,
+	jobs chan<- forkJob) error {
+	queue := forkQueue{
+		api:  api,
+		opts: opts,
+		jobs: jobs,
+		now:  time.Now(),
+		seen: map[string]bool{},
+	}
+
+	for _, fullName := range opts.Repos {
+		owner, name, _ := strings.Cut(fullName, "/")
+
+		repo, gerr := api.GetRepository(ctx, owner, name)
+		if gerr != nil {
+			return fmt.Errorf("GetRepository %s error: %w", fullName, gerr)
+		}
+
+		if qerr := queue.add(ctx, repo, ""); qerr != nil {
+			return qerr
+		}
+	}
+
+	perPage := 30
+	graphQL := opts.Discovery == DiscoveryGraphQL
+
+	for _, src := range sources {
+		if graphQL {
+			gerr := api.listSourceGraphQL(ctx, src, &queue)
+			if gerr == nil {
+				continue
+			}
+
+			if ctx.Err() != nil {
+				return gerr
+			}
+
+			// forks queued before the failure are seen already and not queued twice
+			api.printf("-> GraphQL discovery failed (%v), listing forks with REST...\n", gerr)
+
+			graphQL = false
+		}
+
+		for page := 1; ; page++ {
+			repos, gerr := api.listSourcePage(ctx, src, page, perPage)
+			if gerr != nil {
+				return gerr
+			}
+
+			if len(repos) == 0 {
+				break
+			}
+
+			for _, repo := range repos {
+				if qerr := queue.add(ctx, repo, ""); qerr != nil {
+					return qerr
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// forkQueue numbers the selected forks in listing order and hands them to the workers.
+type forkQueue struct {
+	api   *GitHubAPI
+	opts  SyncOptions
+	jobs  chan<- forkJob
+	now   time.Time
+	seen  map[string]bool
+	index int
+}
+
+// add queues the repository unless it was seen before, is not a fork or is filtered out. level is the
+// head the default branch shares with the parent's, empty when unknown.
+func (queue *forkQueue) add(ctx context.Context, repo *github.Repository, level string) error {
+	fullName := strings.ToLower(repo.GetOwner().GetLogin() + "/" + repo.GetName())
+	if queue.seen[fullName] {
+		return nil
+	}
+
+	queue.seen[fullName] = true
+
+	reason := "is not a fork"
+	if repo.GetFork() {
+		reason = queue.opts.skipReason(repo, queue.now)
+	}
+
+	if len(reason) > 0 {
+		if queue.opts.Verbose || queue.opts.Debug {
+			queue.api.printf("-> Repo '%s/%s %s' %s, skipping...\n",
+				repo.GetOwner().GetLogin(), repo.GetName(), repo.GetDefaultBranch(), reason)
+		}
+
+		return nil
+	}
+
+	job := forkJob{
+		index:         queue.index,
+		owner:         repo.GetOwner().GetLogin(),
+		name:          repo.GetName(),
+		branch:        repo.GetDefaultBranch(),
+		level:         level,
+		upstreamOwner: repo.GetParent().GetOwner().GetLogin(),
+		upstreamName:  repo.GetParent().GetName(),
+	}
+
+	select {
+	case queue.jobs <- job:
+		queue.index++
+	case <-ctx.Done():
+		return fmt.Errorf("SyncForks canceled: %w", ctx.Err())
+	}
+
+	return nil
+}
+
+// syncFork runs on a worker goroutine and syncs every selected branch of a single fork.
+func (api *GitHubAPI) syncFork(ctx context.Context, job forkJob, opts SyncOptions) []*SyncResult {
+	if err := ctx.Err(); err != nil {
+		return []*SyncResult{{
+			Owner:  job.owner,
+			Repo:   job.name,
+			Branch: job.branch,
+			Err:    fmt.Errorf("SyncForks canceled: %w", err),
+		}}
+	}
+
+	branches := []string{job.branch}
+	pats := opts.branchPatterns(job.owner + "/" + job.name)
+
+	var sets *branchSets
+
+	if len(pats) > 0 || len(opts.CreateBranches) > 0 {
+		var err error
+
+		sets, err = api.listBranchSets(ctx, job.owner, job.name)
+		if err != nil {
+			res := SyncResult{Owner: job.owner, Repo: job.name, Branch: job.branch}
+			res.Status, res.Message = errorStatus(err)
+			res.Err = fmt.Errorf("listBranchSets error: %w", err)
+
+			return []*SyncResult{&res}
+		}
+
+		branches = sets.matching(job.branch, pats)
+	}
+
+	results := make([]*SyncResult, 0, len(branches))
+
+	for _, branch := range branches {
+		var res *SyncResult
+
+		if branch == job.branch && len(job.level) > 0 && !opts.DryRun &&
+			opts.strategy(job.owner+"/"+job.name) == StrategyMerge {
+			res = job.levelResult()
+		} else {
+			res = api.syncBranch(ctx, job.owner, job.name, branch, opts)
+		}
+
+		results = append(results, res)
+
+		if res.Err != nil && !opts.ContinueOnError {
+			return results
+		}
+	}
+
+	if len(opts.CreateBranches) > 0 {
+		results = append(results, api.createMissingBranches(ctx, job.owner, job.name, sets,
+			opts.CreateBranches, opts.DryRun)...)
+	}
+
+	if opts.Tags.Enabled {
+		tags, err := api.SyncTags(ctx, job.owner, job.name, opts.Tags, opts.DryRun)
+		if err != nil {
+			res := SyncResult{Owner: job.owner, Repo: job.name, Branch: tagBranchPrefix + "*"}
+			res.Status, res.Message = errorStatus(err)
+			res.Err = fmt.Errorf("SyncTags error: %w", err)
+			tags = []*SyncResult{&res}
+		}
+
+		results = append(results, tags...)
+	}
+
+	return results
+}
+
+// levelResult reports the default branch up to date without asking merge-upstream, as discovery saw it
+// point at the head of the parent's default branch.
+func (job *forkJob) levelResult() *SyncResult {
+	return &SyncResult{
+		Owner:          job.owner,
+		Repo:           job.name,
+		Branch:         job.branch,
+		MergeType:      MergeTypeNone,
+		Message:        fmt.Sprintf("This branch is not behind the upstream %s:%s.", job.upstreamOwner, job.branch),
+		UpstreamOwner:  job.upstreamOwner,
+		UpstreamRepo:   job.upstreamName,
+		UpstreamBranch: job.branch,
+		UpstreamSHA:    job.level,
+		ForkSHA:        job.level,
+	}
+}
+
+// syncBranch merges upstream into, or plans, a single fork branch.
+func (api *GitHubAPI) syncBranch(ctx context.Context, owner string, name string, branch string,
+	opts SyncOptions) *SyncResult {
+	if opts.strategy(owner+"/"+name) == StrategyReset {
+		res, _ := api.ResetBranch(ctx, owner, name, branch, opts.DryRun)
+
+		return res
+	}
+
+	if opts.DryRun {
+		res, _ := api.PlanFork(ctx, owner, name, branch)
+
+		return res
+	}
+
+	if opts.Debug {
+		api.printf("-> Repo '%s/%s %s' merging upstream...\n", owner, name, branch)
+	}
+
+	forkSHA := ""
+
+	if opts.RecordHistory {
+		// an empty repository has no head yet, the sync goes on but the run cannot roll the branch back
+		sha, err := api.GetBranchSHA(ctx, owner, name, branch)
+		if err != nil && opts.Verbose {
+			api.printf("-> Repo '%s/%s %s' head unknown (%v), it will not be rolled back...\n", owner, name, branch, err)
+		}
+
+		forkSHA = sha
+	}
+
+	res, _ := api.mergeUpstreamFork(ctx, owner, name, branch)
+	res.ForkSHA = forkSHA
+
+	if res.Err != nil && res.Status == http.StatusConflict && opts.ConflictPullRequest {
+		res, _ = api.OpenSyncPullRequest(ctx, owner, name, branch)
+	}
+
+	if opts.RecordHistory {
+		api.noteHead(ctx, res)
+	}
+
+	return res
+}
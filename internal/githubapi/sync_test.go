@@ -0,0 +1,313 @@
+package githubapi_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/http/httptest"
+	"github.com/stretchr/testify/assert"
+)
+
+const syncTestOwner = "Test_owner"
+
+// newSyncTestServer serves a single page of repoCount forks owned by syncTestOwner and routes
+// every merge-upstream call to merge.
+func newSyncTestServer(t *testing.T, repoCount int,
+	merge func(wtr http.ResponseWriter, req *http.Request, repo string)) *httptest.Server {
+	t.Helper()
+
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+
+	userJSON := `{"login":"` + syncTestOwner + `","id":666}`
+
+	repos := make([]string, 0, repoCount)
+	for i := 0; i < repoCount; i++ {
+		repos = append(repos, fmt.Sprintf(`{"id":%d,"owner":%s,"name":"repo%02d","fork":true,"default_branch":"main"}`,
+			i, userJSON, i))
+	}
+
+	srvr.Mux.HandleFunc("/user", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodGet)
+		fmt.Fprint(wtr, userJSON)
+	})
+
+	srvr.Mux.HandleFunc("/user/repos", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodGet)
+
+		if req.URL.Query().Get("page") == "1" {
+			fmt.Fprint(wtr, "["+strings.Join(repos, ",")+"]")
+		} else {
+			fmt.Fprint(wtr, `[]`)
+		}
+	})
+
+	srvr.Mux.HandleFunc("/repos/"+syncTestOwner+"/", func(wtr http.ResponseWriter, req *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/repos/"), "/")
+		if len(parts) < 3 || parts[2] != "merge-upstream" {
+			http.NotFound(wtr, req)
+			return
+		}
+
+		testMethod(t, req, http.MethodPost)
+		merge(wtr, req, parts[1])
+	})
+
+	return srvr
+}
+
+func TestSyncForksConcurrentOrdered(t *testing.T) {
+	var inFlight, maxInFlight int32
+
+	srvr := newSyncTestServer(t, 12, func(wtr http.ResponseWriter, _ *http.Request, repo string) {
+		cur := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+
+		for {
+			peak := atomic.LoadInt32(&maxInFlight)
+			if cur <= peak || atomic.CompareAndSwapInt32(&maxInFlight, peak, cur) {
+				break
+			}
+		}
+
+		fmt.Fprint(wtr, `{"message":"merged `+repo+`","merge_type":"merge"}`)
+	})
+	defer srvr.Close()
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	rpt, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{Concurrency: 4})
+	if err != nil {
+		t.Fatalf("githubapi.SyncForks returned error: %v", err)
+	}
+
+	assert.Len(t, rpt.Results, 12)
+	assert.LessOrEqual(t, maxInFlight, int32(4))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 12)
+
+	for i, res := range rpt.Results {
+		name := fmt.Sprintf("repo%02d", i)
+		assert.Equal(t, name, res.Repo)
+		assert.Equal(t, "merge", res.MergeType)
+		assert.Equal(t, fmt.Sprintf("-> Repo '%s/%s main' merged %s", syncTestOwner, name, name), lines[i])
+	}
+}
+
+func TestSyncForksConcurrentStopsOnError(t *testing.T) {
+	var calls int32
+
+	srvr := newSyncTestServer(t, 20, func(wtr http.ResponseWriter, _ *http.Request, repo string) {
+		atomic.AddInt32(&calls, 1)
+
+		if repo == "repo00" {
+			wtr.WriteHeader(http.StatusConflict)
+			return
+		}
+
+		fmt.Fprint(wtr, `{"message":"ok","merge_type":"none"}`)
+	})
+	defer srvr.Close()
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	gha.Out = &bytes.Buffer{}
+
+	_, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{Concurrency: 1})
+	if err == nil {
+		t.Fatalf("githubapi.SyncForks should have returned an error")
+	}
+
+	assert.Contains(t, err.Error(), "409")
+	assert.Less(t, atomic.LoadInt32(&calls), int32(20))
+}
+
+func TestSyncForksCanceledContext(t *testing.T) {
+	srvr := newSyncTestServer(t, 3, func(wtr http.ResponseWriter, _ *http.Request, _ string) {
+		fmt.Fprint(wtr, `{"message":"ok","merge_type":"none"}`)
+	})
+	defer srvr.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	gha.Out = &bytes.Buffer{}
+
+	cancel()
+
+	_, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{Concurrency: 2})
+	if err == nil {
+		t.Errorf("githubapi.SyncForks should have returned an error")
+	}
+}
+
+func TestSyncForksContinueOnError(t *testing.T) {
+	var calls int32
+
+	srvr := newSyncTestServer(t, 5, func(wtr http.ResponseWriter, _ *http.Request, repo string) {
+		atomic.AddInt32(&calls, 1)
+
+		if repo == "repo01" || repo == "repo03" {
+			wtr.WriteHeader(http.StatusConflict)
+			fmt.Fprint(wtr, `{"message":"There are merge conflicts"}`)
+
+			return
+		}
+
+		fmt.Fprint(wtr, `{"message":"ok","merge_type":"none"}`)
+	})
+	defer srvr.Close()
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	rpt, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{Concurrency: 2, ContinueOnError: true})
+	if err == nil {
+		t.Fatalf("githubapi.SyncForks should have returned an error")
+	}
+
+	assert.ErrorIs(t, err, githubapi.ErrSyncFailures)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&calls))
+	assert.Len(t, rpt.Results, 5)
+
+	failed := rpt.Failed()
+	if assert.Len(t, failed, 2) {
+		assert.Equal(t, "repo01", failed[0].Repo)
+		assert.Equal(t, http.StatusConflict, failed[0].Status)
+		assert.Equal(t, "There are merge conflicts", failed[0].Message)
+		assert.Equal(t, "repo03", failed[1].Repo)
+	}
+
+	assert.Contains(t, out.String(), "2 of 5 fork(s) failed to sync")
+}
+
+func TestSyncForksRecordHistory(t *testing.T) {
+	srvr := newSyncTestServer(t, 1, func(wtr http.ResponseWriter, _ *http.Request, _ string) {
+		fmt.Fprint(wtr, `{"message":"ok","merge_type":"fast-forward"}`)
+	})
+	defer srvr.Close()
+
+	heads := []string{"before", "after"}
+
+	srvr.Mux.HandleFunc("/repos/"+syncTestOwner+"/repo00/git/ref/heads/main",
+		func(wtr http.ResponseWriter, req *http.Request) {
+			testMethod(t, req, http.MethodGet)
+			fmt.Fprintf(wtr, `{"ref":"refs/heads/main","object":{"type":"commit","sha":%q}}`, heads[0])
+			heads = heads[1:]
+		})
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	gha.Out = &bytes.Buffer{}
+
+	rpt, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{RecordHistory: true})
+	if err != nil {
+		t.Fatalf("githubapi.SyncForks returned error: %v", err)
+	}
+
+	assert.Equal(t, []*githubapi.RollbackEntry{
+		{
+			Owner: syncTestOwner, Repo: "repo00", Branch: "main", SHA: "before", Action: githubapi.MergeTypeFastForward,
+			Head: "after",
+		},
+	}, rpt.RollbackEntries())
+}
+
+func TestSyncForksRecordHistoryUnknownHead(t *testing.T) {
+	srvr := newSyncTestServer(t, 1, func(wtr http.ResponseWriter, _ *http.Request, _ string) {
+		fmt.Fprint(wtr, `{"message":"ok","merge_type":"fast-forward"}`)
+	})
+	defer srvr.Close()
+
+	srvr.Mux.HandleFunc("/repos/"+syncTestOwner+"/repo00/git/ref/heads/main",
+		func(wtr http.ResponseWriter, _ *http.Request) {
+			wtr.WriteHeader(http.StatusConflict)
+			fmt.Fprint(wtr, `{"message":"Git Repository is empty."}`)
+		})
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	rpt, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{RecordHistory: true, Verbose: true})
+	if err != nil {
+		t.Fatalf("githubapi.SyncForks returned error: %v", err)
+	}
+
+	if assert.Len(t, rpt.Results, 1) {
+		assert.Equal(t, githubapi.MergeTypeFastForward, rpt.Results[0].MergeType)
+	}
+
+	assert.Empty(t, rpt.RollbackEntries(), "a branch whose head was unknown cannot be rolled back")
+	assert.Contains(t, out.String(), "head unknown")
+}
+
+func TestSyncForksSkipRepos(t *testing.T) {
+	srvr := newSyncTestServer(t, 3, func(wtr http.ResponseWriter, _ *http.Request, repo string) {
+		if repo == "repo01" {
+			t.Errorf("merge-upstream should not be called for a skipped fork")
+		}
+
+		fmt.Fprint(wtr, `{"message":"ok","merge_type":"none"}`)
+	})
+	defer srvr.Close()
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	rpt, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{
+		SkipRepos: map[string]bool{"test_owner/repo01": true},
+		Verbose:   true,
+	})
+	if err != nil {
+		t.Fatalf("githubapi.SyncForks returned error: %v", err)
+	}
+
+	assert.Len(t, rpt.Results, 2)
+	assert.Contains(t, out.String(), "-> Repo 'Test_owner/repo01 main' is skipped by configuration, skipping...")
+}
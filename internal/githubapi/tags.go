@@ -0,0 +1,217 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/google/go-github/v53/github"
+	"github.com/mjdusa/github-fork-update/internal/pattern"
+)
+
+const (
+	// MergeTypeTagCreate is reported by a dry-run for an upstream tag that would be created in the fork.
+	MergeTypeTagCreate = "tag-create"
+
+	// MergeTypeTagCreated is reported when an upstream tag was created in the fork.
+	MergeTypeTagCreated = "tag-created"
+
+	// MergeTypeTagDelete is reported by a dry-run for a fork tag that would be pruned.
+	MergeTypeTagDelete = "tag-delete"
+
+	// MergeTypeTagDeleted is reported when a fork tag missing upstream was pruned.
+	MergeTypeTagDeleted = "tag-deleted"
+
+	// tagBranchPrefix marks SyncResult.Branch as a tag rather than a branch.
+	tagBranchPrefix = "tags/"
+)
+
+// TagOptions controls the tag mirroring phase of SyncForks.
+type TagOptions struct {
+	// Enabled turns on tag mirroring.
+	Enabled bool
+
+	// Filter restricts mirroring to tags matching any of the patterns.
+	Filter pattern.List
+
+	// Range restricts mirroring to tags that parse as semantic versions within the constraints.
+	Range *semver.Constraints
+
+	// Prune deletes selected fork tags that no longer exist upstream.
+	Prune bool
+}
+
+// selected reports whether the tag passes the glob and semver filters.
+func (opts *TagOptions) selected(tag string) bool {
+	if len(opts.Filter) > 0 && !opts.Filter.Match(tag) {
+		return false
+	}
+
+	if opts.Range != nil {
+		ver, err := semver.NewVersion(tag)
+		if err != nil || !opts.Range.Check(ver) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// listAllTags pages through every tag of the repository and returns their commit SHAs by name.
+func (api *GitHubAPI) listAllTags(ctx context.Context, owner string, repo string) (map[string]string, error) {
+	names := map[string]string{}
+	page := 1
+	perPage := 100
+
+	for {
+		//nolint:exhaustruct // defaults are desired except for paging
+		opts := github.ListOptions{
+			Page:    page,
+			PerPage: perPage,
+		}
+
+		tags, err := api.ListTags(ctx, owner, repo, &opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tag := range tags {
+			names[tag.GetName()] = tag.GetCommit().GetSHA()
+		}
+
+		if len(tags) < perPage {
+			return names, nil
+		}
+
+		page++
+	}
+}
+
+// SyncTags creates the selected upstream tags missing from the fork as lightweight tags and, when
+// opts.Prune is set, deletes selected fork tags that upstream no longer has. Nothing is changed when
+// dryRun is set.
+func (api *GitHubAPI) SyncTags(ctx context.Context, owner string, name string, opts TagOptions,
+	dryRun bool) ([]*SyncResult, error) {
+	repo, err := api.GetRepository(ctx, owner, name)
+	if err != nil {
+		return nil, err
+	}
+
+	parent := repo.GetParent()
+	if parent == nil {
+		return nil, fmt.Errorf("repository %s/%s has no parent", owner, name)
+	}
+
+	upOwner := parent.GetOwner().GetLogin()
+	upName := parent.GetName()
+
+	forkTags, err := api.listAllTags(ctx, owner, name)
+	if err != nil {
+		return nil, fmt.Errorf("listAllTags error: %w", err)
+	}
+
+	upTags, err := api.listAllTags(ctx, upOwner, upName)
+	if err != nil {
+		return nil, fmt.Errorf("listAllTags error: %w", err)
+	}
+
+	results := make([]*SyncResult, 0)
+
+	for _, tag := range sortedKeys(upTags) {
+		if _, ok := forkTags[tag]; ok || !opts.selected(tag) {
+			continue
+		}
+
+		res := api.tagResult(owner, name, upOwner, upName, tag, upTags[tag], dryRun)
+		if dryRun {
+			res.MergeType = MergeTypeTagCreate
+			res.Message = fmt.Sprintf("would create tag from upstream %s/%s at %s", upOwner, upName, shortSHA(res.UpstreamSHA))
+		} else {
+			api.createForkTag(ctx, res)
+		}
+
+		results = append(results, res)
+	}
+
+	if !opts.Prune {
+		return results, nil
+	}
+
+	for _, tag := range sortedKeys(forkTags) {
+		if _, ok := upTags[tag]; ok || !opts.selected(tag) {
+			continue
+		}
+
+		res := api.tagResult(owner, name, upOwner, upName, tag, "", dryRun)
+		res.ForkSHA = forkTags[tag]
+
+		if dryRun {
+			res.MergeType = MergeTypeTagDelete
+			res.Message = fmt.Sprintf("would delete tag missing from upstream %s/%s", upOwner, upName)
+		} else {
+			api.deleteForkTag(ctx, res)
+		}
+
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+func (api *GitHubAPI) tagResult(owner string, name string, upOwner string, upName string, tag string,
+	sha string, dryRun bool) *SyncResult {
+	return &SyncResult{
+		Owner:          owner,
+		Repo:           name,
+		Branch:         tagBranchPrefix + tag,
+		Planned:        dryRun,
+		UpstreamOwner:  upOwner,
+		UpstreamRepo:   upName,
+		UpstreamBranch: tagBranchPrefix + tag,
+		UpstreamSHA:    sha,
+	}
+}
+
+// createForkTag creates the tag of res in the fork at res.UpstreamSHA and records the outcome in res.
+func (api *GitHubAPI) createForkTag(ctx context.Context, res *SyncResult) {
+	tag := strings.TrimPrefix(res.Branch, tagBranchPrefix)
+
+	if err := api.CreateTag(ctx, res.Owner, res.Repo, tag, res.UpstreamSHA); err != nil {
+		res.Status, res.Message = errorStatus(err)
+		res.Err = fmt.Errorf("CreateTag error: %w", err)
+
+		return
+	}
+
+	res.MergeType = MergeTypeTagCreated
+	res.Message = fmt.Sprintf("created tag from upstream %s/%s at %s", res.UpstreamOwner, res.UpstreamRepo,
+		shortSHA(res.UpstreamSHA))
+}
+
+// deleteForkTag deletes the tag of res from the fork and records the outcome in res.
+func (api *GitHubAPI) deleteForkTag(ctx context.Context, res *SyncResult) {
+	tag := strings.TrimPrefix(res.Branch, tagBranchPrefix)
+
+	if err := api.DeleteTag(ctx, res.Owner, res.Repo, tag); err != nil {
+		res.Status, res.Message = errorStatus(err)
+		res.Err = fmt.Errorf("DeleteTag error: %w", err)
+
+		return
+	}
+
+	res.MergeType = MergeTypeTagDeleted
+	res.Message = fmt.Sprintf("deleted tag missing from upstream %s/%s", res.UpstreamOwner, res.UpstreamRepo)
+}
+
+func sortedKeys(names map[string]string) []string {
+	keys := make([]string, 0, len(names))
+	for key := range names {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
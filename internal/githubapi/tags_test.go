@@ -0,0 +1,179 @@
+package githubapi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/http/httptest"
+	"github.com/mjdusa/github-fork-update/internal/pattern"
+	"github.com/stretchr/testify/assert"
+)
+
+type tagServer struct {
+	srvr    *httptest.Server
+	created []map[string]string
+	deleted []string
+}
+
+// newTagServer serves Test_owner/Test_repo, a fork of Test_upstream/Test_repo, with the given tags.
+func newTagServer(t *testing.T, forkTags []string, upTags []string) *tagServer {
+	t.Helper()
+
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+
+	tsrv := tagServer{srvr: srvr}
+
+	srvr.Mux.HandleFunc("/repos/Test_owner/Test_repo", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `{"name":"Test_repo","fork":true,"parent":{"name":"Test_repo","owner":{"login":"Test_upstream"}}}`)
+	})
+
+	tagsJSON := func(names []string) string {
+		tags := make([]map[string]any, 0, len(names))
+		for _, n := range names {
+			tags = append(tags, map[string]any{"name": n, "commit": map[string]string{"sha": "sha-" + n}})
+		}
+
+		data, _ := json.Marshal(tags)
+
+		return string(data)
+	}
+
+	srvr.Mux.HandleFunc("/repos/Test_owner/Test_repo/tags", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, tagsJSON(forkTags))
+	})
+
+	srvr.Mux.HandleFunc("/repos/Test_upstream/Test_repo/tags", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, tagsJSON(upTags))
+	})
+
+	srvr.Mux.HandleFunc("/repos/Test_owner/Test_repo/git/refs", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodPost)
+
+		ref := map[string]string{}
+		json.NewDecoder(req.Body).Decode(&ref) //nolint:errcheck  // We don't care about the error here
+		tsrv.created = append(tsrv.created, ref)
+
+		fmt.Fprint(wtr, `{"ref":"`+ref["ref"]+`"}`)
+	})
+
+	srvr.Mux.HandleFunc("/repos/Test_owner/Test_repo/git/refs/tags/", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodDelete)
+		tsrv.deleted = append(tsrv.deleted, req.URL.Path)
+		wtr.WriteHeader(http.StatusNoContent)
+	})
+
+	return &tsrv
+}
+
+func TestSyncTags(t *testing.T) {
+	tsrv := newTagServer(t, []string{"v1.0.0", "local", "v0.9.0"}, []string{"v1.0.0", "v1.1.0", "v2.0.0", "nightly"})
+	defer tsrv.srvr.Close()
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", tsrv.srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	results, err := gha.SyncTags(ctx, "Test_owner", "Test_repo", githubapi.TagOptions{Enabled: true, Prune: true}, false)
+	if err != nil {
+		t.Fatalf("githubapi.SyncTags returned error: %v", err)
+	}
+
+	assert.Equal(t, []map[string]string{
+		{"ref": "refs/tags/nightly", "sha": "sha-nightly"},
+		{"ref": "refs/tags/v1.1.0", "sha": "sha-v1.1.0"},
+		{"ref": "refs/tags/v2.0.0", "sha": "sha-v2.0.0"},
+	}, tsrv.created)
+	assert.Equal(t, []string{
+		"/repos/Test_owner/Test_repo/git/refs/tags/local",
+		"/repos/Test_owner/Test_repo/git/refs/tags/v0.9.0",
+	}, tsrv.deleted)
+
+	if assert.Len(t, results, 5) {
+		assert.Equal(t, "tags/nightly", results[0].Branch)
+		assert.Equal(t, githubapi.MergeTypeTagCreated, results[0].MergeType)
+		assert.Equal(t, githubapi.MergeTypeTagDeleted, results[4].MergeType)
+	}
+}
+
+func TestSyncTagsFiltered(t *testing.T) {
+	tsrv := newTagServer(t, []string{"v0.9.0"}, []string{"v1.0.0", "v1.1.0", "v2.0.0", "nightly"})
+	defer tsrv.srvr.Close()
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", tsrv.srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	constraints, cerr := semver.NewConstraint(">= 1.1, < 2")
+	if cerr != nil {
+		t.Fatalf("semver.NewConstraint returned error: %v", cerr)
+	}
+
+	filter, _ := pattern.Parse("v*")
+
+	results, err := gha.SyncTags(ctx, "Test_owner", "Test_repo",
+		githubapi.TagOptions{Enabled: true, Filter: filter, Range: constraints, Prune: true}, true)
+	if err != nil {
+		t.Fatalf("githubapi.SyncTags returned error: %v", err)
+	}
+
+	assert.Empty(t, tsrv.created)
+	assert.Empty(t, tsrv.deleted)
+
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "tags/v1.1.0", results[0].Branch)
+		assert.Equal(t, githubapi.MergeTypeTagCreate, results[0].MergeType)
+		assert.True(t, results[0].Planned)
+	}
+}
+
+func TestSyncForksTags(t *testing.T) {
+	srvr := newSyncTestServer(t, 1, func(wtr http.ResponseWriter, _ *http.Request, _ string) {
+		fmt.Fprint(wtr, `{"message":"ok","merge_type":"none"}`)
+	})
+	defer srvr.Close()
+
+	srvr.Mux.HandleFunc("/repos/Test_owner/repo00", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `{"name":"repo00","fork":true,"parent":{"name":"repo00","owner":{"login":"Test_upstream"}}}`)
+	})
+	srvr.Mux.HandleFunc("/repos/Test_owner/repo00/tags", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `[]`)
+	})
+	srvr.Mux.HandleFunc("/repos/Test_upstream/repo00/tags", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `[{"name":"v1","commit":{"sha":"abc"}}]`)
+	})
+	srvr.Mux.HandleFunc("/repos/Test_owner/repo00/git/refs", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `{"ref":"refs/tags/v1"}`)
+	})
+
+	ctx := context.Background()
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	out := bytes.Buffer{}
+	gha.Out = &out
+
+	rpt, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{Tags: githubapi.TagOptions{Enabled: true}})
+	if err != nil {
+		t.Fatalf("githubapi.SyncForks returned error: %v", err)
+	}
+
+	assert.Len(t, rpt.Results, 2)
+	assert.Contains(t, out.String(), "-> Repo 'Test_owner/repo00 tags/v1' created tag from upstream Test_upstream/repo00 at abc")
+	assert.Contains(t, out.String(), "-> Tags: 1 created, 0 deleted")
+}
@@ -0,0 +1,166 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v53/github"
+)
+
+const (
+	// ScopesHeader lists the OAuth scopes of a classic token, fine-grained tokens and apps do not send it.
+	ScopesHeader = "X-OAuth-Scopes"
+
+	// TokenExpirationHeader reports when a token with an expiry date expires.
+	TokenExpirationHeader = "github-authentication-token-expiration"
+
+	// TokenExpiryWarning is how long before a token expires CheckToken starts warning about it.
+	TokenExpiryWarning = 7 * 24 * time.Hour
+)
+
+// tokenExpirationLayouts are the formats GitHub has used for TokenExpirationHeader.
+var tokenExpirationLayouts = []string{"2006-01-02 15:04:05 MST", "2006-01-02 15:04:05 -0700", time.RFC3339}
+
+// TokenInfo describes the token the client is authenticated with.
+type TokenInfo struct {
+	Login string
+
+	// Scopes are the OAuth scopes of a classic token, nil when the server reported none, as for
+	// fine-grained tokens.
+	Scopes []string
+
+	// Expiration is when the token expires, zero when it does not or the server did not say.
+	Expiration time.Time
+}
+
+// HasScope reports whether the token was granted scope, directly or through the repo scope that
+// includes public_repo.
+func (info *TokenInfo) HasScope(scope string) bool {
+	for _, granted := range info.Scopes {
+		if granted == scope || (granted == "repo" && scope == "public_repo") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Warnings lists the problems of the token that do not stop a run but may fail some forks.
+func (info *TokenInfo) Warnings(now time.Time) []string {
+	warnings := make([]string, 0)
+
+	if !info.Expiration.IsZero() && info.Expiration.Sub(now) < TokenExpiryWarning {
+		days := int(info.Expiration.Sub(now).Hours() / 24)
+		warnings = append(warnings, fmt.Sprintf("token expires in %d day(s), on %s, renew it soon",
+			days, info.Expiration.Format(time.DateOnly)))
+	}
+
+	if info.Scopes == nil {
+		return warnings
+	}
+
+	switch {
+	case !info.HasScope("public_repo"):
+		warnings = append(warnings, "token lacks repo scope, forks cannot be synced")
+	case !info.HasScope("repo"):
+		warnings = append(warnings, "token lacks repo scope, private forks cannot be synced")
+	}
+
+	if !info.HasScope("workflow") {
+		warnings = append(warnings,
+			"token lacks workflow scope, forks whose upstream changed .github/workflows will fail to sync")
+	}
+
+	return warnings
+}
+
+// CheckToken asks the user endpoint who the token belongs to and reads its scopes and expiry from the
+// response headers. The result is kept to explain later merge failures.
+func (api *GitHubAPI) CheckToken(ctx context.Context) (*TokenInfo, error) {
+	user, resp, err := withRateLimit(ctx, api, func() (*github.User, *github.Response, error) {
+		return api.Client.Users.Get(ctx, "")
+	})
+	if status, _ := errorStatus(err); status == http.StatusUnauthorized {
+		return nil, fmt.Errorf("token is invalid, expired or revoked: %w", err)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("api.client.Users.Get error: %w", err)
+	}
+
+	info := TokenInfo{
+		Login:      user.GetLogin(),
+		Scopes:     parseScopes(resp.Response),
+		Expiration: parseTokenExpiration(resp.Header.Get(TokenExpirationHeader)),
+	}
+
+	api.tokenMu.Lock()
+	defer api.tokenMu.Unlock()
+
+	api.tokenInfo = &info
+
+	return &info, nil
+}
+
+// TokenInfo returns what CheckToken found out about the token, nil before it ran.
+func (api *GitHubAPI) TokenInfo() *TokenInfo {
+	api.tokenMu.Lock()
+	defer api.tokenMu.Unlock()
+
+	return api.tokenInfo
+}
+
+func parseScopes(resp *http.Response) []string {
+	values, ok := resp.Header[http.CanonicalHeaderKey(ScopesHeader)]
+	if !ok {
+		return nil
+	}
+
+	scopes := make([]string, 0)
+
+	for _, value := range values {
+		for _, scope := range strings.Split(value, ",") {
+			if scope = strings.TrimSpace(scope); len(scope) > 0 {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	return scopes
+}
+
+func parseTokenExpiration(value string) time.Time {
+	for _, layout := range tokenExpirationLayouts {
+		if expiration, err := time.Parse(layout, value); err == nil {
+			return expiration
+		}
+	}
+
+	return time.Time{}
+}
+
+// mergeFailureHint turns a merge-upstream failure GitHub is known to describe poorly into an actionable
+// message, empty when there is nothing to add.
+func (api *GitHubAPI) mergeFailureHint(status int, message string) string {
+	lower := strings.ToLower(message)
+	info := api.TokenInfo()
+
+	switch {
+	case strings.Contains(lower, "workflow") && (status == http.StatusForbidden ||
+		status == http.StatusUnprocessableEntity || strings.Contains(lower, "scope")):
+		return "token lacks workflow scope, needed because upstream changed .github/workflows"
+	case status == http.StatusUnauthorized:
+		return "token is invalid, expired or revoked"
+	case status == http.StatusForbidden && strings.Contains(lower, "not accessible"):
+		return "token cannot write to this fork, grant it Contents (and Workflows) write access"
+	case status == http.StatusForbidden && info != nil && info.Scopes != nil && !info.HasScope("public_repo"):
+		return "token lacks repo scope"
+	case status == http.StatusNotFound && info != nil && info.Scopes != nil && !info.HasScope("repo"):
+		return "fork not found, the token lacks repo scope needed for private forks"
+	default:
+		return ""
+	}
+}
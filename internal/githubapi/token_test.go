@@ -0,0 +1,184 @@
+package githubapi_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/http/httptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckToken(t *testing.T) {
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+	defer srvr.Close()
+
+	srvr.Mux.HandleFunc("/user", func(wtr http.ResponseWriter, req *http.Request) {
+		testMethod(t, req, http.MethodGet)
+
+		switch req.Header.Get("Authorization") {
+		case "Bearer classic":
+			wtr.Header().Set(githubapi.ScopesHeader, "repo, read:org")
+			wtr.Header().Set(githubapi.TokenExpirationHeader, "2026-10-20 09:30:00 UTC")
+		case "Bearer revoked":
+			wtr.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(wtr, `{"message":"Bad credentials"}`)
+
+			return
+		}
+
+		fmt.Fprint(wtr, `{"login":"octocat"}`)
+	})
+
+	ctx := context.Background()
+
+	gha, nerr := NewTestGitHubAPI(ctx, "classic", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	assert.Nil(t, gha.TokenInfo())
+
+	info, err := gha.CheckToken(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "octocat", info.Login)
+		assert.Equal(t, []string{"repo", "read:org"}, info.Scopes)
+		assert.Equal(t, time.Date(2026, 10, 20, 9, 30, 0, 0, time.UTC), info.Expiration.UTC())
+		assert.Same(t, info, gha.TokenInfo())
+	}
+
+	gha, _ = NewTestGitHubAPI(ctx, "fine-grained", srvr.Server.URL)
+
+	info, err = gha.CheckToken(ctx)
+	if assert.NoError(t, err) {
+		assert.Nil(t, info.Scopes)
+		assert.True(t, info.Expiration.IsZero())
+	}
+
+	gha, _ = NewTestGitHubAPI(ctx, "revoked", srvr.Server.URL)
+
+	_, err = gha.CheckToken(ctx)
+	assert.ErrorContains(t, err, "token is invalid, expired or revoked")
+}
+
+func TestTokenInfoWarnings(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		info githubapi.TokenInfo
+		want []string
+	}{
+		{
+			name: "all scopes",
+			info: githubapi.TokenInfo{Scopes: []string{"repo", "workflow"}, Expiration: now.Add(30 * 24 * time.Hour)},
+			want: []string{},
+		},
+		{
+			name: "fine-grained",
+			info: githubapi.TokenInfo{},
+			want: []string{},
+		},
+		{
+			name: "expiring",
+			info: githubapi.TokenInfo{Expiration: now.Add(50 * time.Hour)},
+			want: []string{"token expires in 2 day(s), on 2026-10-20, renew it soon"},
+		},
+		{
+			name: "public repositories only",
+			info: githubapi.TokenInfo{Scopes: []string{"public_repo", "workflow"}},
+			want: []string{"token lacks repo scope, private forks cannot be synced"},
+		},
+		{
+			name: "no scopes",
+			info: githubapi.TokenInfo{Scopes: []string{}},
+			want: []string{
+				"token lacks repo scope, forks cannot be synced",
+				"token lacks workflow scope, forks whose upstream changed .github/workflows will fail to sync",
+			},
+		},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			assert.Equal(t, tst.want, tst.info.Warnings(now))
+		})
+	}
+}
+
+func TestMergeUpstreamFailureHints(t *testing.T) {
+	srvr, serr := httptest.NewHTTPTestServer(githubapi.GitHubAPIBaseURLPath, os.Stderr)
+	if serr != nil {
+		t.Fatalf("NewHTTPTestServer returned error: %v", serr)
+	}
+	defer srvr.Close()
+
+	failures := map[string]struct {
+		status  int
+		message string
+	}{
+		"workflows": {http.StatusUnprocessableEntity,
+			"refusing to allow a Personal Access Token to create or update workflow `.github/workflows/ci.yml` " +
+				"without `workflow` scope"},
+		"integration": {http.StatusForbidden, "Resource not accessible by integration"},
+		"private":     {http.StatusNotFound, "Not Found"},
+		"conflict":    {http.StatusConflict, "merge conflict"},
+	}
+
+	srvr.Mux.HandleFunc("/user", func(wtr http.ResponseWriter, _ *http.Request) {
+		wtr.Header().Set(githubapi.ScopesHeader, "public_repo")
+		fmt.Fprint(wtr, `{"login":"octocat"}`)
+	})
+
+	for name, failure := range failures {
+		srvr.Mux.HandleFunc("/repos/Test_owner/"+name, func(wtr http.ResponseWriter, _ *http.Request) {
+			fmt.Fprintf(wtr, `{"owner":{"login":"Test_owner"},"name":%q,"fork":true,"default_branch":"main"}`, name)
+		})
+		srvr.Mux.HandleFunc("/repos/Test_owner/"+name+"/merge-upstream", func(wtr http.ResponseWriter, _ *http.Request) {
+			wtr.WriteHeader(failure.status)
+			fmt.Fprintf(wtr, `{"message":%q}`, failure.message)
+		})
+	}
+
+	ctx := context.Background()
+
+	gha, nerr := NewTestGitHubAPI(ctx, "auth", srvr.Server.URL)
+	if nerr != nil {
+		t.Fatalf("githubapi.NewGitHubAPI error: %v", nerr)
+	}
+
+	gha.Out = &bytes.Buffer{}
+
+	_, err := gha.CheckToken(ctx)
+	if err != nil {
+		t.Fatalf("githubapi.CheckToken returned error: %v", err)
+	}
+
+	rpt, err := gha.SyncForks(ctx, "", githubapi.SyncOptions{
+		Repos:           []string{"Test_owner/workflows", "Test_owner/integration", "Test_owner/private", "Test_owner/conflict"},
+		ContinueOnError: true,
+	})
+	assert.ErrorIs(t, err, githubapi.ErrSyncFailures)
+
+	messages := make([]string, 0, len(rpt.Results))
+	for _, res := range rpt.Results {
+		messages = append(messages, res.Message)
+	}
+
+	assert.Equal(t, []string{
+		"token lacks workflow scope, needed because upstream changed .github/workflows: " +
+			failures["workflows"].message,
+		"token cannot write to this fork, grant it Contents (and Workflows) write access: " +
+			"Resource not accessible by integration",
+		"fork not found, the token lacks repo scope needed for private forks: Not Found",
+		"merge conflict",
+	}, messages)
+}
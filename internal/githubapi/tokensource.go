@@ -0,0 +1,99 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/google/go-github/v53/github"
+	"golang.org/x/oauth2"
+)
+
+// TokenSource supplies the token requests are authenticated with. Token is asked again once the
+// returned token expires, so sources of short-lived tokens can refresh them.
+type TokenSource interface {
+	Token() (*oauth2.Token, error)
+
+	// Name describes where the token comes from without revealing it.
+	Name() string
+}
+
+type staticTokenSource struct {
+	name  string
+	token string
+}
+
+// StaticTokenSource returns a TokenSource that always supplies token, name describes its origin.
+func StaticTokenSource(token string, name string) TokenSource {
+	return &staticTokenSource{name: name, token: token}
+}
+
+func (src *staticTokenSource) Token() (*oauth2.Token, error) {
+	if len(src.token) == 0 {
+		return nil, fmt.Errorf("empty token error")
+	}
+
+	return &oauth2.Token{AccessToken: src.token}, nil
+}
+
+func (src *staticTokenSource) Name() string {
+	return src.name
+}
+
+// String keeps the token out of formatted output.
+func (src *staticTokenSource) String() string {
+	return src.name
+}
+
+// NewGitHubAPIFromSource returns a GitHubAPI authenticated by src, for github.com when baseURL is empty
+// and for the GitHub Enterprise Server at baseURL otherwise. The first token is fetched up front so a
+// source that cannot supply one fails here rather than on the first request.
+func NewGitHubAPIFromSource(ctx context.Context, src TokenSource, baseURL string,
+	uploadURL string) (*GitHubAPI, error) {
+	if src == nil {
+		return nil, fmt.Errorf("empty token source error")
+	}
+
+	token, err := src.Token()
+	if err != nil {
+		return nil, fmt.Errorf("%s token error: %w", src.Name(), err)
+	}
+
+	return newAPI(oauth2.NewClient(ctx, oauth2.ReuseTokenSource(token, src)).Transport, baseURL, uploadURL)
+}
+
+// newAPI returns a GitHubAPI whose requests are authenticated by auth, retried and counted.
+func newAPI(auth http.RoundTripper, baseURL string, uploadURL string) (*GitHubAPI, error) {
+	//nolint:exhaustruct // the client is set below
+	api := GitHubAPI{
+		Out:              os.Stdout,
+		MaxRateLimitWait: DefaultMaxRateLimitWait,
+		MaxRetries:       DefaultMaxRetries,
+	}
+
+	// every attempt of a retried request is counted
+	httpClient := &http.Client{
+		Transport: &retryTransport{
+			base: &countingTransport{base: auth, calls: &api.calls},
+			api:  &api,
+		},
+	}
+
+	api.Client = github.NewClient(httpClient)
+
+	if len(baseURL) > 0 {
+		if len(uploadURL) == 0 {
+			uploadURL = baseURL
+		}
+
+		var err error
+
+		api.Client, err = github.NewEnterpriseClient(baseURL, uploadURL, httpClient)
+		if err != nil {
+			return nil, fmt.Errorf("github.NewEnterpriseClient error: %w", err)
+		}
+	}
+
+	return &api, nil
+}
@@ -0,0 +1,61 @@
+package githubapi_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+type failingTokenSource struct{}
+
+func (failingTokenSource) Token() (*oauth2.Token, error) {
+	return nil, errors.New("no credential")
+}
+
+func (failingTokenSource) Name() string {
+	return "failing"
+}
+
+func TestNewGitHubAPIFromSource(t *testing.T) {
+	srvr := httptest.NewServer(http.HandlerFunc(func(wtr http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/api/v3/user", req.URL.Path)
+		assert.Equal(t, "Bearer source_token", req.Header.Get("Authorization"))
+		fmt.Fprint(wtr, `{"login":"octocat"}`)
+	}))
+	defer srvr.Close()
+
+	ctx := context.Background()
+	src := githubapi.StaticTokenSource("source_token", "test")
+
+	gha, err := githubapi.NewGitHubAPIFromSource(ctx, src, srvr.URL, "")
+	if err != nil {
+		t.Fatalf("githubapi.NewGitHubAPIFromSource returned error: %v", err)
+	}
+
+	user, _, err := gha.Client.Users.Get(ctx, "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "octocat", user.GetLogin())
+	}
+
+	assert.Equal(t, "test", fmt.Sprint(src), "formatting shows the name, not the token")
+}
+
+func TestNewGitHubAPIFromSourceErrors(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := githubapi.NewGitHubAPIFromSource(ctx, nil, "", "")
+	assert.ErrorContains(t, err, "empty token source error")
+
+	_, err = githubapi.NewGitHubAPIFromSource(ctx, failingTokenSource{}, "", "")
+	assert.ErrorContains(t, err, "failing token error: no credential")
+
+	_, err = githubapi.NewGitHubAPIFromSource(ctx, githubapi.StaticTokenSource("", "empty"), "", "")
+	assert.ErrorContains(t, err, "empty token error")
+}
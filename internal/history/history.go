@@ -0,0 +1,192 @@
+package history
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+)
+
+const (
+	// RunIDFormat is the time layout used for run identifiers, Save adds a suffix to keep them unique.
+	RunIDFormat = "20060102T150405Z"
+
+	runFileSuffix = ".json"
+
+	// maxRunsPerID bounds the runs recorded in the same second, which get a -2, -3... suffix.
+	maxRunsPerID = 100
+)
+
+// ErrRunNotFound is returned by Load when no run with the requested id was recorded.
+var ErrRunNotFound = errors.New("run not found")
+
+// Store keeps one JSON file per recorded run in Dir.
+type Store struct {
+	Dir string
+}
+
+// Run is the record of the branches a single sync changed.
+type Run struct {
+	ID        string                     `json:"id"`
+	CreatedAt time.Time                  `json:"created_at"`
+	Entries   []*githubapi.RollbackEntry `json:"entries"`
+}
+
+// DefaultDir returns the per-user directory runs are recorded in.
+func DefaultDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("os.UserConfigDir error: %w", err)
+	}
+
+	return filepath.Join(dir, "github-fork-update", "history"), nil
+}
+
+func NewStore(dir string) (*Store, error) {
+	if len(dir) == 0 {
+		return nil, fmt.Errorf("empty history directory")
+	}
+
+	return &Store{Dir: dir}, nil
+}
+
+// NewRun records the pre-sync SHA of every branch the report shows as changed.
+func NewRun(rpt *githubapi.SyncReport, now time.Time) *Run {
+	return &Run{
+		ID:        now.UTC().Format(RunIDFormat),
+		CreatedAt: now.UTC(),
+		Entries:   rpt.RollbackEntries(),
+	}
+}
+
+func (store *Store) path(id string) string {
+	return filepath.Join(store.Dir, id+runFileSuffix)
+}
+
+// Save writes the run to the store, creating the directory when needed. A run is never overwritten:
+// when another run was recorded with the same id, run.ID gets a -2, -3... suffix.
+func (store *Store) Save(run *Run) (string, error) {
+	if err := os.MkdirAll(store.Dir, 0o700); err != nil {
+		return "", fmt.Errorf("os.MkdirAll error: %w", err)
+	}
+
+	base := run.ID
+
+	for try := 1; try <= maxRunsPerID; try++ {
+		if try > 1 {
+			run.ID = fmt.Sprintf("%s-%d", base, try)
+		}
+
+		path, err := store.create(run)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+
+		return path, err
+	}
+
+	return "", fmt.Errorf("more than %d runs recorded as %s", maxRunsPerID, base)
+}
+
+// create writes the run to a new file, it fails with os.ErrExist when the id is taken.
+func (store *Store) create(run *Run) (string, error) {
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("json.MarshalIndent error: %w", err)
+	}
+
+	path := store.path(run.ID)
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("os.OpenFile error: %w", err)
+	}
+
+	if _, werr := file.Write(append(data, '\n')); werr != nil {
+		file.Close()
+
+		return "", fmt.Errorf("write error: %w", werr)
+	}
+
+	if cerr := file.Close(); cerr != nil {
+		return "", fmt.Errorf("close error: %w", cerr)
+	}
+
+	return path, nil
+}
+
+// Load reads the run with the given id.
+func (store *Store) Load(id string) (*Run, error) {
+	if len(id) == 0 || strings.ContainsAny(id, `/\`) {
+		return nil, fmt.Errorf("invalid run id %q", id)
+	}
+
+	data, err := os.ReadFile(store.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrRunNotFound, id)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile error: %w", err)
+	}
+
+	run := Run{}
+	if uerr := json.Unmarshal(data, &run); uerr != nil {
+		return nil, fmt.Errorf("json.Unmarshal error: %w", uerr)
+	}
+
+	return &run, nil
+}
+
+// List returns the ids of the recorded runs, oldest first.
+func (store *Store) List() ([]string, error) {
+	files, err := os.ReadDir(store.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadDir error: %w", err)
+	}
+
+	ids := make([]string, 0, len(files))
+
+	for _, file := range files {
+		if id, ok := strings.CutSuffix(file.Name(), runFileSuffix); ok && !file.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+
+	slices.SortFunc(ids, func(left string, right string) int {
+		leftTime, leftTry := splitRunID(left)
+		rightTime, rightTry := splitRunID(right)
+
+		return cmp.Or(strings.Compare(leftTime, rightTime), cmp.Compare(leftTry, rightTry))
+	})
+
+	return ids, nil
+}
+
+// splitRunID returns the timestamp of a run id and the suffix Save added to it, 1 when it has none. The
+// suffix is compared as a number so the run recorded as -10 sorts after -9.
+func splitRunID(id string) (string, int) {
+	stamp, suffix, ok := strings.Cut(id, "-")
+	if !ok {
+		return id, 1
+	}
+
+	try, err := strconv.Atoi(suffix)
+	if err != nil {
+		return id, 0
+	}
+
+	return stamp, try
+}
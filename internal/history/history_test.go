@@ -0,0 +1,137 @@
+package history_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/history"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRun(t *testing.T) {
+	rpt := githubapi.SyncReport{
+		Results: []*githubapi.SyncResult{
+			{Owner: "o", Repo: "a", Branch: "main", MergeType: githubapi.MergeTypeFastForward, ForkSHA: "1"},
+			{Owner: "o", Repo: "b", Branch: "main", MergeType: githubapi.MergeTypeNone, ForkSHA: "2"},
+			{Owner: "o", Repo: "c", Branch: "dev", MergeType: githubapi.MergeTypeCreated},
+		},
+	}
+
+	run := history.NewRun(&rpt, time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC))
+
+	assert.Equal(t, "20261018T093000Z", run.ID)
+
+	if assert.Len(t, run.Entries, 2) {
+		assert.Equal(t, "a", run.Entries[0].Repo)
+		assert.Equal(t, "c", run.Entries[1].Repo)
+	}
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	store, err := history.NewStore(filepath.Join(t.TempDir(), "history"))
+	if err != nil {
+		t.Fatalf("history.NewStore returned error: %v", err)
+	}
+
+	ids, err := store.List()
+	if err != nil {
+		t.Fatalf("store.List returned error: %v", err)
+	}
+
+	assert.Empty(t, ids)
+
+	want := &history.Run{
+		ID: "20261018T093000Z",
+		Entries: []*githubapi.RollbackEntry{
+			{Owner: "o", Repo: "a", Branch: "main", SHA: "1", Action: githubapi.MergeTypeMerge},
+		},
+	}
+
+	path, err := store.Save(want)
+	if err != nil {
+		t.Fatalf("store.Save returned error: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("os.Stat returned error: %v", err)
+	}
+
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	got, err := store.Load(want.ID)
+	if err != nil {
+		t.Fatalf("store.Load returned error: %v", err)
+	}
+
+	assert.Equal(t, want.Entries, got.Entries)
+
+	ids, err = store.List()
+	if err != nil {
+		t.Fatalf("store.List returned error: %v", err)
+	}
+
+	assert.Equal(t, []string{want.ID}, ids)
+}
+
+func TestStoreSaveSameSecond(t *testing.T) {
+	store, err := history.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("history.NewStore returned error: %v", err)
+	}
+
+	now := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	rpt := githubapi.SyncReport{
+		Results: []*githubapi.SyncResult{
+			{Owner: "o", Repo: "a", Branch: "main", MergeType: githubapi.MergeTypeMerge, ForkSHA: "1"},
+		},
+	}
+
+	want := []string{"20261018T093000Z"}
+	for try := 2; try <= 11; try++ {
+		want = append(want, fmt.Sprintf("20261018T093000Z-%d", try))
+	}
+
+	for range want {
+		if _, serr := store.Save(history.NewRun(&rpt, now)); serr != nil {
+			t.Fatalf("store.Save returned error: %v", serr)
+		}
+	}
+
+	if _, serr := store.Save(history.NewRun(&rpt, now.Add(time.Second))); serr != nil {
+		t.Fatalf("store.Save returned error: %v", serr)
+	}
+
+	ids, err := store.List()
+	if err != nil {
+		t.Fatalf("store.List returned error: %v", err)
+	}
+
+	assert.Equal(t, append(want, "20261018T093001Z"), ids,
+		"a run recorded in the same second must not overwrite the earlier one and is listed after it")
+
+	run, err := store.Load("20261018T093000Z-2")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "20261018T093000Z-2", run.ID)
+	}
+}
+
+func TestStoreLoadErrors(t *testing.T) {
+	store, err := history.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("history.NewStore returned error: %v", err)
+	}
+
+	_, err = store.Load("20200101T000000Z")
+	assert.ErrorIs(t, err, history.ErrRunNotFound)
+
+	_, err = store.Load("../escape")
+	assert.ErrorContains(t, err, "invalid run id")
+
+	_, err = history.NewStore("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,331 @@
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultMaxBytes is the size the cache is trimmed to when no other is given.
+	DefaultMaxBytes = 50 << 20
+
+	entrySuffix = ".json"
+)
+
+// Cache keeps the responses of GET requests in Dir, one JSON file per URL and token, so they can be
+// revalidated with If-None-Match and If-Modified-Since. GitHub answers an unchanged resource with
+// 304 Not Modified, which does not count against the rate limit. Once the files exceed MaxBytes the
+// least recently used are removed.
+type Cache struct {
+	Dir      string
+	MaxBytes int64
+
+	hits atomic.Int64
+
+	mu      sync.Mutex
+	size    int64
+	scanned bool
+}
+
+// entry is a cached response.
+type entry struct {
+	URL          string      `json:"url"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+}
+
+// DefaultDir returns the per-user directory responses are cached in.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("os.UserCacheDir error: %w", err)
+	}
+
+	return filepath.Join(dir, "github-fork-update", "http"), nil
+}
+
+func NewCache(dir string, maxBytes int64) (*Cache, error) {
+	if len(dir) == 0 {
+		return nil, fmt.Errorf("empty cache directory")
+	}
+
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("cache size must be positive, got %d", maxBytes)
+	}
+
+	//nolint:exhaustruct // the counters start at zero
+	return &Cache{Dir: dir, MaxBytes: maxBytes}, nil
+}
+
+// Hits returns the number of requests answered from the cache after GitHub reported them unchanged.
+func (cache *Cache) Hits() int64 {
+	return cache.hits.Load()
+}
+
+// Transport returns a RoundTripper that revalidates the GET requests accepted by cacheable against the
+// cache before sending them through base. It must sit beneath the transport that authenticates requests,
+// as the token is part of the key: a response is never served to a token other than the one that got it.
+func (cache *Cache) Transport(base http.RoundTripper, cacheable func(req *http.Request) bool) http.RoundTripper {
+	return &transport{base: base, cache: cache, cacheable: cacheable}
+}
+
+type transport struct {
+	base      http.RoundTripper
+	cache     *Cache
+	cacheable func(req *http.Request) bool
+}
+
+func (trn *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || !trn.cacheable(req) {
+		return trn.base.RoundTrip(req) //nolint:wrapcheck // errors pass through the transport unchanged
+	}
+
+	key := cacheKey(req)
+	cached := trn.cache.load(key)
+
+	creq := req
+
+	if cached != nil && len(req.Header.Get("If-None-Match")) == 0 && len(req.Header.Get("If-Modified-Since")) == 0 {
+		creq = req.Clone(req.Context())
+
+		if len(cached.ETag) > 0 {
+			creq.Header.Set("If-None-Match", cached.ETag)
+		}
+
+		if len(cached.LastModified) > 0 {
+			creq.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := trn.base.RoundTrip(creq)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // errors pass through the transport unchanged
+	}
+
+	if resp.StatusCode == http.StatusNotModified && creq != req {
+		io.Copy(io.Discard, resp.Body) //nolint:errcheck // a 304 has no body worth reading
+		resp.Body.Close()
+
+		trn.cache.hits.Add(1)
+		trn.cache.touch(key)
+
+		return cached.response(req, resp), nil
+	}
+
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode != http.StatusOK || (len(etag) == 0 && len(lastModified) == 0) {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if err != nil {
+		return nil, fmt.Errorf("reading response body error: %w", err)
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	// a cache that cannot be written only costs the revalidation of the next run
+	trn.cache.store(key, &entry{
+		URL:          req.URL.String(),
+		ETag:         etag,
+		LastModified: lastModified,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+	})
+
+	return resp, nil
+}
+
+// cacheKey identifies a response by URL, media type and token, only a hash of the token is kept.
+func cacheKey(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.URL.String() + "\n" + req.Header.Get("Accept") + "\n" +
+		req.Header.Get("Authorization")))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// response turns the cached entry into the 200 OK the 304 Not Modified resp stands for, with the
+// headers of resp, such as the rate limit, replacing the cached ones.
+func (ent *entry) response(req *http.Request, resp *http.Response) *http.Response {
+	header := ent.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	for name, values := range resp.Header {
+		header[name] = values
+	}
+
+	header.Set("Content-Length", strconv.Itoa(len(ent.Body)))
+
+	//nolint:exhaustruct // the remaining fields do not apply to a cached response
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         resp.Proto,
+		ProtoMajor:    resp.ProtoMajor,
+		ProtoMinor:    resp.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(ent.Body)),
+		ContentLength: int64(len(ent.Body)),
+		Request:       req,
+	}
+}
+
+func (cache *Cache) path(key string) string {
+	return filepath.Join(cache.Dir, key+entrySuffix)
+}
+
+// load returns the entry for key, nil when there is none or it cannot be read.
+func (cache *Cache) load(key string) *entry {
+	data, err := os.ReadFile(cache.path(key))
+	if err != nil {
+		return nil
+	}
+
+	var ent entry
+	if err := json.Unmarshal(data, &ent); err != nil {
+		return nil
+	}
+
+	return &ent
+}
+
+// touch marks the entry for key as used, so it is trimmed last.
+func (cache *Cache) touch(key string) {
+	now := time.Now()
+	os.Chtimes(cache.path(key), now, now) //nolint:errcheck // only the trim order depends on it
+}
+
+// store writes the entry for key and trims the cache when it grew beyond MaxBytes.
+func (cache *Cache) store(key string, ent *entry) {
+	data, err := json.Marshal(ent)
+	if err != nil {
+		return
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if err := os.MkdirAll(cache.Dir, 0o700); err != nil {
+		return
+	}
+
+	if !cache.scanned {
+		cache.size, cache.scanned = cache.scan(), true
+	}
+
+	path := cache.path(key)
+
+	if info, err := os.Stat(path); err == nil {
+		cache.size -= info.Size()
+	}
+
+	if err := writeFile(path, data); err != nil {
+		return
+	}
+
+	// stamped like touch, as the file system clock may lag behind time.Now
+	cache.touch(key)
+
+	cache.size += int64(len(data))
+
+	if cache.size > cache.MaxBytes {
+		cache.trim()
+	}
+}
+
+// scan returns the size of every entry.
+func (cache *Cache) scan() int64 {
+	var size int64
+
+	for _, info := range cache.entries() {
+		size += info.Size()
+	}
+
+	return size
+}
+
+// trim removes the least recently used entries until the cache fits MaxBytes.
+func (cache *Cache) trim() {
+	infos := cache.entries()
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ModTime().Before(infos[j].ModTime()) })
+
+	cache.size = 0
+	for _, info := range infos {
+		cache.size += info.Size()
+	}
+
+	for _, info := range infos {
+		if cache.size <= cache.MaxBytes {
+			return
+		}
+
+		if err := os.Remove(filepath.Join(cache.Dir, info.Name())); err == nil {
+			cache.size -= info.Size()
+		}
+	}
+}
+
+func (cache *Cache) entries() []os.FileInfo {
+	dirents, err := os.ReadDir(cache.Dir)
+	if err != nil {
+		return nil
+	}
+
+	infos := make([]os.FileInfo, 0, len(dirents))
+
+	for _, dirent := range dirents {
+		if dirent.IsDir() || filepath.Ext(dirent.Name()) != entrySuffix {
+			continue
+		}
+
+		if info, err := dirent.Info(); err == nil {
+			infos = append(infos, info)
+		}
+	}
+
+	return infos
+}
+
+// writeFile replaces path with data through a temporary file, so readers never see half an entry.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".entry-*")
+	if err != nil {
+		return fmt.Errorf("os.CreateTemp error: %w", err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+
+		return fmt.Errorf("write error: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close error: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("os.Rename error: %w", err)
+	}
+
+	return nil
+}
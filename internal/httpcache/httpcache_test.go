@@ -0,0 +1,187 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjdusa/github-fork-update/internal/httpcache"
+	"github.com/stretchr/testify/assert"
+)
+
+// etagServer answers every path with a body and ETag derived from the path and version, and with 304 Not
+// Modified when the request already has that ETag. It records the If-None-Match of every request.
+type etagServer struct {
+	version    int
+	conditions []string
+}
+
+func (esr *etagServer) ServeHTTP(wtr http.ResponseWriter, req *http.Request) {
+	etag := fmt.Sprintf(`"%s-%d"`, req.URL.Path, esr.version)
+	esr.conditions = append(esr.conditions, req.Header.Get("If-None-Match"))
+
+	wtr.Header().Set("X-RateLimit-Remaining", fmt.Sprint(100-len(esr.conditions)))
+
+	if req.Header.Get("If-None-Match") == etag {
+		wtr.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
+	wtr.Header().Set("ETag", etag)
+	wtr.Header().Set("Link", `<`+req.URL.Path+`?page=2>; rel="next"`)
+	fmt.Fprintf(wtr, `[{"path":%q,"version":%d}]`, req.URL.Path, esr.version)
+}
+
+func newCache(t *testing.T, maxBytes int64) *httpcache.Cache {
+	t.Helper()
+
+	cache, err := httpcache.NewCache(filepath.Join(t.TempDir(), "http"), maxBytes)
+	if err != nil {
+		t.Fatalf("httpcache.NewCache returned error: %v", err)
+	}
+
+	return cache
+}
+
+func all(*http.Request) bool { return true }
+
+func get(t *testing.T, client *http.Client, url string, token string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil) //nolint:noctx // test request
+	if err != nil {
+		t.Fatalf("http.NewRequest returned error: %v", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("client.Do returned error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("io.ReadAll returned error: %v", err)
+	}
+
+	return resp, string(body)
+}
+
+func TestTransport(t *testing.T) {
+	esr := etagServer{version: 1}
+	srvr := httptest.NewServer(&esr)
+	defer srvr.Close()
+
+	cache := newCache(t, httpcache.DefaultMaxBytes)
+	client := &http.Client{Transport: cache.Transport(http.DefaultTransport, all)}
+
+	_, first := get(t, client, srvr.URL+"/users/o/repos", "a")
+
+	resp, second := get(t, client, srvr.URL+"/users/o/repos", "a")
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "the 304 is answered from the cache")
+	assert.Equal(t, first, second)
+	assert.Equal(t, "98", resp.Header.Get("X-RateLimit-Remaining"), "headers of the 304 replace the cached ones")
+	assert.Contains(t, resp.Header.Get("Link"), `rel="next"`)
+	assert.Equal(t, int64(1), cache.Hits())
+
+	get(t, client, srvr.URL+"/users/o/repos", "b")
+
+	esr.version = 2
+	_, third := get(t, client, srvr.URL+"/users/o/repos", "a")
+	assert.Contains(t, third, `"version":2`)
+
+	assert.Equal(t, []string{"", `"/users/o/repos-1"`, "", `"/users/o/repos-1"`}, esr.conditions,
+		"responses are only revalidated for the token that got them")
+	assert.Equal(t, int64(1), cache.Hits())
+
+	entries, err := os.ReadDir(cache.Dir)
+	if assert.NoError(t, err) {
+		assert.Len(t, entries, 2)
+
+		data, rerr := os.ReadFile(filepath.Join(cache.Dir, entries[0].Name()))
+		assert.NoError(t, rerr)
+		assert.NotContains(t, string(data), "Bearer")
+	}
+}
+
+func TestTransportNotCacheable(t *testing.T) {
+	esr := etagServer{version: 1}
+	srvr := httptest.NewServer(&esr)
+	defer srvr.Close()
+
+	cache := newCache(t, httpcache.DefaultMaxBytes)
+	client := &http.Client{Transport: cache.Transport(http.DefaultTransport, func(req *http.Request) bool {
+		return strings.HasSuffix(req.URL.Path, "/repos")
+	})}
+
+	for range 2 {
+		get(t, client, srvr.URL+"/repos/o/app", "a")
+	}
+
+	assert.Equal(t, []string{"", ""}, esr.conditions)
+	assert.Equal(t, int64(0), cache.Hits())
+}
+
+func TestTrim(t *testing.T) {
+	esr := etagServer{version: 1}
+	srvr := httptest.NewServer(&esr)
+	defer srvr.Close()
+
+	cache := newCache(t, 1)
+	client := &http.Client{Transport: cache.Transport(http.DefaultTransport, all)}
+
+	get(t, client, srvr.URL+"/users/a/repos", "a")
+
+	entries, err := os.ReadDir(cache.Dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries, "entries beyond MaxBytes are removed")
+
+	cache.MaxBytes = 1 << 20
+
+	get(t, client, srvr.URL+"/users/b/repos", "a")
+	time.Sleep(10 * time.Millisecond)
+	get(t, client, srvr.URL+"/users/c/repos", "a")
+
+	entries, err = os.ReadDir(cache.Dir)
+	if !assert.NoError(t, err) || !assert.Len(t, entries, 2) {
+		return
+	}
+
+	info, err := entries[0].Info()
+	assert.NoError(t, err)
+
+	cache.MaxBytes = info.Size() + 1
+
+	time.Sleep(10 * time.Millisecond)
+	get(t, client, srvr.URL+"/users/b/repos", "a")
+	get(t, client, srvr.URL+"/users/d/repos", "a")
+
+	esr.conditions = nil
+
+	get(t, client, srvr.URL+"/users/d/repos", "a")
+	get(t, client, srvr.URL+"/users/b/repos", "a")
+	assert.Equal(t, []string{`"/users/d/repos-1"`, ""}, esr.conditions, "the least recently used are removed first")
+}
+
+func TestNewCache(t *testing.T) {
+	_, err := httpcache.NewCache("", 1)
+	assert.ErrorContains(t, err, "empty cache directory")
+
+	_, err = httpcache.NewCache(t.TempDir(), 0)
+	assert.ErrorContains(t, err, "cache size must be positive")
+
+	dir, err := httpcache.DefaultDir()
+	if assert.NoError(t, err) {
+		assert.Equal(t, filepath.Join("github-fork-update", "http"), filepath.Join(filepath.Base(filepath.Dir(dir)),
+			filepath.Base(dir)))
+	}
+}
@@ -0,0 +1,146 @@
+package pattern
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// RegexpPrefix marks a pattern as a regular expression rather than a glob.
+const RegexpPrefix = "re:"
+
+// Pattern matches names either with path.Match glob syntax or, when prefixed with RegexpPrefix,
+// with a regular expression.
+type Pattern struct {
+	raw string
+	re  *regexp.Regexp
+}
+
+// List is a set of patterns, it matches a name when any of its patterns does. It implements
+// flag.Value so it can back a repeatable, comma separated command line flag.
+type List []*Pattern
+
+// Compile parses a single glob or "re:" prefixed regular expression.
+func Compile(raw string) (*Pattern, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	if expr, ok := strings.CutPrefix(raw, RegexpPrefix); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("regexp.Compile error: %w", err)
+		}
+
+		return &Pattern{raw: raw, re: re}, nil
+	}
+
+	if _, err := path.Match(raw, ""); err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %w", raw, err)
+	}
+
+	return &Pattern{raw: raw, re: nil}, nil
+}
+
+// Parse compiles a comma separated list of patterns.
+func Parse(csv string) (List, error) {
+	list := List{}
+
+	if err := list.Set(csv); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Match reports whether name matches the pattern.
+func (pat *Pattern) Match(name string) bool {
+	if pat.re != nil {
+		return pat.re.MatchString(name)
+	}
+
+	ok, _ := path.Match(pat.raw, name)
+
+	return ok
+}
+
+func (pat *Pattern) String() string {
+	return pat.raw
+}
+
+// Match reports whether any pattern in the list matches name.
+func (list List) Match(name string) bool {
+	return list.Find(name) != nil
+}
+
+// Find returns the first pattern in the list that matches name, nil when none does.
+func (list List) Find(name string) *Pattern {
+	for _, pat := range list {
+		if pat.Match(name) {
+			return pat
+		}
+	}
+
+	return nil
+}
+
+// Set appends the comma separated patterns in value to the list. Commas inside (), [] or {}, such as
+// the one of re:^release-[0-9]{1,2}$, belong to the pattern and do not separate it from the next.
+func (list *List) Set(value string) error {
+	for _, raw := range splitTopLevel(value) {
+		raw = strings.TrimSpace(raw)
+		if len(raw) == 0 {
+			continue
+		}
+
+		pat, err := Compile(raw)
+		if err != nil {
+			return err
+		}
+
+		*list = append(*list, pat)
+	}
+
+	return nil
+}
+
+// splitTopLevel splits value on the commas outside any bracket, a backslash escapes the next character.
+func splitTopLevel(value string) []string {
+	parts := make([]string, 0, 1)
+	depth := 0
+	start := 0
+
+	for idx := 0; idx < len(value); idx++ {
+		switch value[idx] {
+		case '\\':
+			idx++
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, value[start:idx])
+				start = idx + 1
+			}
+		}
+	}
+
+	return append(parts, value[start:])
+}
+
+func (list *List) String() string {
+	if list == nil {
+		return ""
+	}
+
+	raws := make([]string, 0, len(*list))
+	for _, pat := range *list {
+		raws = append(raws, pat.raw)
+	}
+
+	return strings.Join(raws, ",")
+}
@@ -0,0 +1,80 @@
+package pattern_test
+
+import (
+	"testing"
+
+	"github.com/mjdusa/github-fork-update/internal/pattern"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		input   string
+		want    bool
+		wantErr bool
+	}{
+		{name: "glob match", raw: "release-*", input: "release-1.2", want: true},
+		{name: "glob no match", raw: "release-*", input: "main", want: false},
+		{name: "glob does not cross slash", raw: "release*", input: "release/1.2", want: false},
+		{name: "glob with slash", raw: "owner/*", input: "owner/repo", want: true},
+		{name: "regexp match", raw: "re:^v[0-9]+$", input: "v2", want: true},
+		{name: "regexp no match", raw: "re:^v[0-9]+$", input: "v2-beta", want: false},
+		{name: "bad regexp", raw: "re:(", wantErr: true},
+		{name: "bad glob", raw: "[", wantErr: true},
+		{name: "empty", raw: "", wantErr: true},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			pat, err := pattern.Compile(tst.raw)
+			if tst.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			if assert.NoError(t, err) {
+				assert.Equal(t, tst.want, pat.Match(tst.input))
+				assert.Equal(t, tst.raw, pat.String())
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	list, err := pattern.Parse("release-*, re:^v[0-9]+$,,")
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	assert.Len(t, list, 2)
+	assert.True(t, list.Match("release-2024"))
+	assert.True(t, list.Match("v3"))
+	assert.False(t, list.Match("main"))
+	assert.Equal(t, "re:^v[0-9]+$", list.Find("v3").String())
+	assert.Nil(t, list.Find("main"))
+
+	assert.NoError(t, list.Set("main"))
+	assert.True(t, list.Match("main"))
+	assert.Equal(t, "release-*,re:^v[0-9]+$,main", list.String())
+
+	assert.False(t, pattern.List{}.Match("main"))
+
+	_, err = pattern.Parse("ok,re:[")
+	assert.Error(t, err)
+}
+
+func TestListCommasInPatterns(t *testing.T) {
+	list, err := pattern.Parse(`re:^release-[0-9]{1,2}$, v[0-9,.]*, re:^(main|dev),x\,y`)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	assert.Equal(t, `re:^release-[0-9]{1,2}$,v[0-9,.]*,re:^(main|dev),x\,y`, list.String())
+	assert.Len(t, list, 4)
+	assert.True(t, list.Match("release-12"))
+	assert.False(t, list.Match("release-123"))
+	assert.True(t, list.Match("v1,2"))
+	assert.True(t, list.Match("x,y"))
+}
@@ -0,0 +1,242 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/mjdusa/github-fork-update/internal/environment"
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/version"
+)
+
+const (
+	checkPass = "PASS"
+	checkWarn = "WARN"
+	checkFail = "FAIL"
+	checkSkip = "SKIP"
+
+	// defaultAPIURL is the API the proxy check resolves when no -base-url is given.
+	defaultAPIURL = "https://api.github.com/"
+
+	appName = "github-fork-update"
+)
+
+// check is a single line of the doctor checklist, hint tells how to fix a warning or failure.
+type check struct {
+	name   string
+	status string
+	detail string
+	hint   string
+}
+
+// doctor runs every check, writes the checklist to out and fails when any check failed. Checks that
+// need the API are skipped once the token, the API itself or the rate limit failed.
+func doctor(ctx context.Context, out io.Writer, params *environment.Parameters, opts githubapi.SyncOptions) error {
+	checks := []*check{configCheck(params), tokenCheck(params), proxyCheck(params)}
+
+	var gapi *githubapi.GitHubAPI
+
+	apiCheck := check{name: "api", status: checkSkip, detail: "no token"}
+
+	if params.AuthErr == nil {
+		gapi = doctorAPI(ctx, params, &apiCheck)
+	}
+
+	checks = append(checks, &apiCheck)
+
+	if gapi == nil {
+		for _, name := range []string{"rate limit", "scopes", "forks"} {
+			checks = append(checks, &check{name: name, status: checkSkip, detail: "API not reachable"})
+		}
+	} else {
+		rateCheck := rateLimitCheck(ctx, gapi)
+		checks = append(checks, rateCheck, scopesCheck(gapi, params))
+
+		if rateCheck.status == checkFail {
+			checks = append(checks, &check{name: "forks", status: checkSkip, detail: "rate limit exhausted"})
+		} else {
+			checks = append(checks, forksCheck(ctx, gapi, params, opts))
+		}
+	}
+
+	fmt.Fprintf(out, "%s doctor\n", appName)
+	fmt.Fprint(out, version.GetVersion())
+	fmt.Fprintf(out, "- OS/Arch:    [%s/%s]\n\n", runtime.GOOS, runtime.GOARCH)
+
+	failed := 0
+
+	for _, chk := range checks {
+		fmt.Fprintf(out, "[%s] %-10s  %s\n", chk.status, chk.name, chk.detail)
+
+		if len(chk.hint) > 0 {
+			fmt.Fprintf(out, "       %-10s  -> %s\n", "", chk.hint)
+		}
+
+		if chk.status == checkFail {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("doctor found %d problem(s)", failed)
+	}
+
+	return nil
+}
+
+func configCheck(params *environment.Parameters) *check {
+	switch {
+	case params.ConfigErr != nil:
+		return &check{name: "config", status: checkFail, detail: params.ConfigErr.Error(),
+			hint: "fix the reported line, or run without -config"}
+	case len(params.ConfigFile) == 0:
+		return &check{name: "config", status: checkPass, detail: "no config file"}
+	default:
+		return &check{name: "config", status: checkPass, detail: params.ConfigFile + " parsed"}
+	}
+}
+
+func tokenCheck(params *environment.Parameters) *check {
+	if params.AuthErr != nil {
+		return &check{name: "token", status: checkFail, detail: params.AuthErr.Error(),
+			hint: "set GITHUB_TOKEN, give -auth-file or -auth-stdin, or run 'gh auth login'"}
+	}
+
+	if len(params.TokenPool) > 1 {
+		return &check{name: "token", status: checkPass,
+			detail: fmt.Sprintf("%d tokens from %s", len(params.TokenPool), params.AuthSource)}
+	}
+
+	return &check{name: "token", status: checkPass, detail: "from " + params.AuthSource}
+}
+
+// proxyCheck reports the proxy requests to the API go through, as configured by HTTPS_PROXY,
+// HTTP_PROXY and NO_PROXY.
+func proxyCheck(params *environment.Parameters) *check {
+	target := defaultAPIURL
+	if len(params.BaseURL) > 0 {
+		target = params.BaseURL
+	}
+
+	req, err := http.NewRequest(http.MethodGet, target, nil) //nolint:noctx // the request is never sent
+	if err != nil {
+		return &check{name: "proxy", status: checkFail, detail: err.Error(), hint: "check -base-url"}
+	}
+
+	proxy, err := http.ProxyFromEnvironment(req)
+	if err != nil {
+		return &check{name: "proxy", status: checkFail, detail: err.Error(),
+			hint: "fix HTTPS_PROXY or HTTP_PROXY, they must be URLs"}
+	}
+
+	if proxy == nil {
+		return &check{name: "proxy", status: checkPass, detail: "none, " + req.URL.Host + " is reached directly"}
+	}
+
+	return &check{name: "proxy", status: checkPass, detail: req.URL.Host + " via " + proxy.Redacted()}
+}
+
+// doctorAPI connects to the API and fills chk, the returned client is nil when the API cannot be used.
+func doctorAPI(ctx context.Context, params *environment.Parameters, chk *check) *githubapi.GitHubAPI {
+	hint := "check the network, the proxy and -base-url"
+
+	gapi, err := newGitHubAPI(ctx, params)
+	if err != nil {
+		chk.status, chk.detail, chk.hint = checkFail, err.Error(), hint
+
+		return nil
+	}
+
+	// the rate limit is reported, not waited for
+	gapi.MaxRateLimitWait = 0
+
+	if params.AppID != 0 {
+		chk.status, chk.detail = checkPass, gapi.Client.BaseURL.String()+" as "+params.AuthSource
+
+		// installation tokens cannot read the user endpoint, whose response carries the version otherwise
+		if len(params.BaseURL) > 0 {
+			if version, verr := gapi.DetectServerVersion(ctx); verr == nil && len(version) > 0 {
+				chk.detail += ", GitHub Enterprise Server " + version
+			}
+		}
+
+		return gapi
+	}
+
+	info, err := gapi.CheckToken(ctx)
+	if err != nil {
+		chk.status, chk.detail, chk.hint = checkFail, err.Error(), hint
+
+		return nil
+	}
+
+	chk.status, chk.detail = checkPass, gapi.Client.BaseURL.String()+" as "+info.Login
+
+	if version := gapi.ServerVersion(); len(version) > 0 {
+		chk.detail += ", GitHub Enterprise Server " + version
+	}
+
+	return gapi
+}
+
+func rateLimitCheck(ctx context.Context, gapi *githubapi.GitHubAPI) *check {
+	limits, err := gapi.RateLimits(ctx)
+	if err != nil {
+		return &check{name: "rate limit", status: checkWarn, detail: err.Error(),
+			hint: "the server may have rate limiting disabled"}
+	}
+
+	core := limits.GetCore()
+	detail := fmt.Sprintf("%d of %d requests left, resets at %s", core.Remaining, core.Limit,
+		core.Reset.Local().Format(time.Kitchen))
+
+	switch {
+	case core.Remaining == 0:
+		return &check{name: "rate limit", status: checkFail, detail: detail, hint: "wait for the reset"}
+	case core.Remaining*10 < core.Limit:
+		return &check{name: "rate limit", status: checkWarn, detail: detail,
+			hint: "large runs may pause until the reset"}
+	default:
+		return &check{name: "rate limit", status: checkPass, detail: detail}
+	}
+}
+
+func scopesCheck(gapi *githubapi.GitHubAPI, params *environment.Parameters) *check {
+	info := gapi.TokenInfo()
+	if params.AppID != 0 || info == nil {
+		return &check{name: "scopes", status: checkSkip, detail: "GitHub App installation tokens have no scopes"}
+	}
+
+	detail := "not reported, fine-grained token"
+	if info.Scopes != nil {
+		detail = strings.Join(info.Scopes, ", ")
+	}
+
+	if warnings := info.Warnings(time.Now()); len(warnings) > 0 {
+		return &check{name: "scopes", status: checkWarn, detail: detail, hint: strings.Join(warnings, "; ")}
+	}
+
+	return &check{name: "scopes", status: checkPass, detail: detail}
+}
+
+func forksCheck(ctx context.Context, gapi *githubapi.GitHubAPI, params *environment.Parameters,
+	opts githubapi.SyncOptions) *check {
+	fork, err := gapi.FirstFork(ctx, params.User, opts)
+	if errors.Is(err, githubapi.ErrNoForks) {
+		return &check{name: "forks", status: checkFail, detail: err.Error(),
+			hint: "check -user, -org and -repos, and that the token can see the forks"}
+	}
+
+	if err != nil {
+		return &check{name: "forks", status: checkFail, detail: err.Error()}
+	}
+
+	return &check{name: "forks", status: checkPass,
+		detail: fmt.Sprintf("found %s/%s", fork.GetOwner().GetLogin(), fork.GetName())}
+}
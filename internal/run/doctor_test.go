@@ -0,0 +1,69 @@
+package run_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mjdusa/github-fork-update/internal/environment"
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func newDoctorServer(t *testing.T, remaining int) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/user", func(wtr http.ResponseWriter, _ *http.Request) {
+		wtr.Header().Set(githubapi.ScopesHeader, "repo, workflow")
+		fmt.Fprint(wtr, `{"login":"octocat"}`)
+	})
+	mux.HandleFunc("/api/v3/users/octocat", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `{"login":"octocat"}`)
+	})
+	mux.HandleFunc("/api/v3/rate_limit", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(wtr, `{"resources":{"core":{"limit":5000,"remaining":%d,"reset":%d}}}`,
+			remaining, time.Now().Add(time.Hour).Unix())
+	})
+	mux.HandleFunc("/api/v3/user/repos", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `[{"name":"app","fork":true,"owner":{"login":"octocat"}}]`)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestProcessDoctor(t *testing.T) {
+	srvr := newDoctorServer(t, 4999)
+	defer srvr.Close()
+
+	params := environment.Parameters{
+		Command:     environment.CommandDoctor,
+		AuthSource:  environment.GitHubTokenEnv,
+		TokenSource: githubapi.StaticTokenSource("token", environment.GitHubTokenEnv),
+		BaseURL:     srvr.URL,
+		Archived:    githubapi.ArchivedInclude,
+	}
+
+	assert.NoError(t, run.Process(context.Background(), &params))
+
+	exhausted := newDoctorServer(t, 0)
+	defer exhausted.Close()
+
+	params.BaseURL = exhausted.URL
+	assert.EqualError(t, run.Process(context.Background(), &params), "doctor found 1 problem(s)")
+}
+
+func TestProcessDoctorNoToken(t *testing.T) {
+	params := environment.Parameters{
+		Command:   environment.CommandDoctor,
+		AuthErr:   errors.New("empty auth token error"),
+		ConfigErr: errors.New("config.yml:2: unknown flag"),
+	}
+
+	assert.EqualError(t, run.Process(context.Background(), &params), "doctor found 2 problem(s)")
+}
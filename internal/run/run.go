@@ -2,26 +2,73 @@ package run
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/mjdusa/github-fork-update/internal/environment"
 	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/history"
+	"github.com/mjdusa/github-fork-update/internal/httpcache"
 	"github.com/mjdusa/github-fork-update/internal/profile"
+	"golang.org/x/oauth2"
 )
 
+const (
+	// ExitOK is returned when every fork was synced.
+	ExitOK = 0
+
+	// ExitFailure is returned when the run could not complete.
+	ExitFailure = 1
+
+	// ExitSyncFailures is returned when the run completed but some forks failed to sync.
+	ExitSyncFailures = 2
+)
+
+// ExitError carries the process exit code that should be reported for Err.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (eerr *ExitError) Error() string {
+	return eerr.Err.Error()
+}
+
+func (eerr *ExitError) Unwrap() error {
+	return eerr.Err
+}
+
+// ExitCode maps an error returned by Run to the process exit code.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	var eerr *ExitError
+	if errors.As(err, &eerr) {
+		return eerr.Code
+	}
+
+	return ExitFailure
+}
+
 func Run(ctx context.Context) error {
 	env, eerr := environment.NewEnvironment()
 	if eerr != nil {
 		return fmt.Errorf("NewEnvironment error: %w", eerr)
 	}
 
-	auth, debugFlag, verboseFlag, perr := env.GetParameters()
+	params, perr := env.GetParameters()
 	if perr != nil {
 		return fmt.Errorf("GetParameters error: %w", perr)
 	}
 
-	if *debugFlag {
+	if params.Debug {
 		pro, merr := profile.NewProfile(ctx, "cpu-profile.pprof", "mem-profile.pprof")
 		if merr != nil {
 			return fmt.Errorf("NewProfile error: %w", merr)
@@ -47,7 +94,7 @@ func Run(ctx context.Context) error {
 		}()
 	}
 
-	merr := Process(ctx, auth, verboseFlag, debugFlag)
+	merr := Process(ctx, params)
 	if merr != nil {
 		return fmt.Errorf("Process error: %w", merr)
 	}
@@ -55,19 +102,402 @@ func Run(ctx context.Context) error {
 	return nil
 }
 
-func Process(ctx context.Context, auth *string, verboseFlag *bool, debugFlag *bool) error {
-	if auth == nil {
+func Process(ctx context.Context, params *environment.Parameters) error {
+	if params == nil {
 		return fmt.Errorf("empty token error")
 	}
 
-	gapi, aerr := githubapi.NewGitHubAPI(ctx, *auth)
+	opts := syncOptions(params)
+
+	if params.Command == environment.CommandDoctor {
+		return doctor(ctx, os.Stdout, params, opts)
+	}
+
+	ctx, cache, cerr := withCache(ctx, params)
+	if cerr != nil {
+		return cerr
+	}
+
+	gapi, aerr := newGitHubAPI(ctx, params)
 	if aerr != nil {
-		return fmt.Errorf("NewGitHubAPI error: %w", aerr)
+		return aerr
+	}
+
+	gapi.MaxRateLimitWait = params.MaxRateWait
+
+	defer reportAPIUsage(gapi, cache)
+
+	if len(params.BaseURL) > 0 && (params.Verbose || params.Debug) {
+		if verr := reportServerVersion(ctx, os.Stdout, gapi); verr != nil {
+			return verr
+		}
+	}
+
+	if cerr := checkToken(ctx, gapi, params); cerr != nil {
+		return cerr
+	}
+
+	var store *history.Store
+
+	if !params.NoHistory {
+		hstore, herr := historyStore(params.HistoryDir)
+		if herr != nil {
+			return herr
+		}
+
+		store = hstore
+	}
+
+	switch params.Command {
+	case environment.CommandApply:
+		return apply(ctx, gapi, store, params.Args[0], opts)
+	case environment.CommandRollback:
+		return rollback(ctx, gapi, store, params.RunID, opts)
+	default:
+		return syncForks(ctx, gapi, store, params, opts)
+	}
+}
+
+// syncOptions maps the parameters to the options of the githubapi package.
+func syncOptions(params *environment.Parameters) githubapi.SyncOptions {
+	return githubapi.SyncOptions{
+		Concurrency:         params.Concurrency,
+		ContinueOnError:     params.ContinueOnError,
+		DryRun:              params.DryRun,
+		Branches:            params.Branches,
+		RepoBranches:        params.RepoBranches,
+		CreateBranches:      params.CreateBranches,
+		ConflictPullRequest: params.ConflictPR,
+		Strategy:            params.Strategy,
+		RepoStrategies:      params.RepoStrategies,
+		Tags: githubapi.TagOptions{
+			Enabled: params.SyncTags,
+			Filter:  params.Tags,
+			Range:   params.TagConstraints,
+			Prune:   params.PruneTags,
+		},
+		Repos:     params.Repos,
+		Discovery: params.Discovery,
+		Orgs:      params.Orgs,
+		AllOrgs:   params.AllOrgs,
+		OrgsOnly:  params.OrgsOnly,
+		SkipRepos: params.SkipRepos,
+		Filter: githubapi.ForkFilter{
+			Include:      params.Include,
+			Exclude:      params.Exclude,
+			Visibilities: params.Visibilities(),
+			Archived:     params.Archived,
+			Topics:       params.Topics,
+			Languages:    params.Languages,
+			PushedWithin: time.Duration(params.PushedWithin),
+		},
+		RecordHistory: !params.NoHistory && !params.DryRun,
+		Force:         params.Force,
+		Verbose:       params.Verbose,
+		Debug:         params.Debug,
+	}
+}
+
+// withCache returns ctx carrying the HTTP client the GitHub clients made from it send their requests
+// through, which revalidates list and compare responses against the response cache, and the cache. Both
+// are left out with -no-cache.
+func withCache(ctx context.Context, params *environment.Parameters) (context.Context, *httpcache.Cache, error) {
+	if params.NoCache {
+		return ctx, nil, nil
+	}
+
+	dir := params.CacheDir
+	if len(dir) == 0 {
+		ddir, derr := httpcache.DefaultDir()
+		if derr != nil {
+			return nil, nil, fmt.Errorf("httpcache.DefaultDir error: %w", derr)
+		}
+
+		dir = ddir
+	}
+
+	cache, cerr := httpcache.NewCache(dir, int64(params.CacheMaxMB)<<20)
+	if cerr != nil {
+		return nil, nil, fmt.Errorf("httpcache.NewCache error: %w", cerr)
+	}
+
+	client := &http.Client{Transport: cache.Transport(http.DefaultTransport, githubapi.Cacheable)}
+
+	return context.WithValue(ctx, oauth2.HTTPClient, client), cache, nil
+}
+
+// newGitHubAPI returns a client for github.com, or for the GitHub Enterprise Server at params.BaseURL,
+// authenticated by the resolved token source, or rotating between the tokens of the pool when one is given.
+func newGitHubAPI(ctx context.Context, params *environment.Parameters) (*githubapi.GitHubAPI, error) {
+	var gapi *githubapi.GitHubAPI
+
+	var aerr error
+
+	if len(params.TokenPool) > 0 {
+		gapi, aerr = githubapi.NewGitHubAPIFromPool(ctx, params.TokenPool, params.BaseURL, params.UploadURL)
+		if aerr != nil {
+			return nil, fmt.Errorf("NewGitHubAPIFromPool error: %w", aerr)
+		}
+	} else {
+		src := params.TokenSource
+		if src == nil {
+			src = githubapi.StaticTokenSource(params.Auth.Value(), environment.AuthSourceFlag)
+		}
+
+		gapi, aerr = githubapi.NewGitHubAPIFromSource(ctx, src, params.BaseURL, params.UploadURL)
+		if aerr != nil {
+			return nil, fmt.Errorf("NewGitHubAPIFromSource error: %w", aerr)
+		}
+	}
+
+	gapi.MaxRetries = params.MaxRetries
+	gapi.Debug = params.Debug
+
+	return gapi, nil
+}
+
+// reportServerVersion writes the version of the GitHub Enterprise Server the client talks to to out.
+func reportServerVersion(ctx context.Context, out io.Writer, gapi *githubapi.GitHubAPI) error {
+	version, verr := gapi.DetectServerVersion(ctx)
+	if verr != nil {
+		return fmt.Errorf("DetectServerVersion error: %w", verr)
+	}
+
+	fmt.Fprintf(out, "-> GitHub Enterprise Server %s at %s\n", version, gapi.Client.BaseURL)
+
+	return nil
+}
+
+// checkToken stops the run early when the token is rejected and warns about an expiring token or missing
+// scopes. GitHub App installation tokens cannot read the user endpoint and are not checked.
+func checkToken(ctx context.Context, gapi *githubapi.GitHubAPI, params *environment.Parameters) error {
+	if params.AppID != 0 {
+		if params.Verbose || params.Debug {
+			fmt.Printf("-> Token from %s\n", params.AuthSource)
+		}
+
+		return nil
+	}
+
+	info, cerr := gapi.CheckToken(ctx)
+	if cerr != nil {
+		return fmt.Errorf("CheckToken error: %w", cerr)
+	}
+
+	if params.Verbose || params.Debug {
+		scopes := "not reported (fine-grained token)"
+		if info.Scopes != nil {
+			scopes = strings.Join(info.Scopes, ", ")
+		}
+
+		fmt.Printf("-> Token of %s from %s, scopes: %s\n", info.Login, params.AuthSource, scopes)
+	}
+
+	for _, warning := range info.Warnings(time.Now()) {
+		fmt.Printf("-> Warning: %s\n", warning)
+	}
+
+	return nil
+}
+
+// reportAPIUsage prints the number of requests the run sent, how many of them were retries or answered
+// from the cache, and the rate limit left afterwards.
+func reportAPIUsage(gapi *githubapi.GitHubAPI, cache *httpcache.Cache) {
+	calls := gapi.APICalls()
+	if calls == 0 {
+		return
+	}
+
+	notes := make([]string, 0, 2)
+
+	if retries := gapi.Retries(); retries > 0 {
+		notes = append(notes, fmt.Sprintf("%d retried", retries))
+	}
+
+	if cache != nil && cache.Hits() > 0 {
+		notes = append(notes, fmt.Sprintf("%d unchanged and served from the cache", cache.Hits()))
+	}
+
+	usage := fmt.Sprintf("%d API call(s) used", calls)
+	if len(notes) > 0 {
+		usage += " (" + strings.Join(notes, ", ") + ")"
+	}
+
+	// the limit is unknown when no response carried the core rate limit headers
+	if rate := gapi.Rate(); rate.Limit > 0 {
+		usage += fmt.Sprintf(", %d of %d left until %s", rate.Remaining, rate.Limit,
+			rate.Reset.Local().Format(time.TimeOnly))
+	}
+
+	fmt.Printf("-> %s\n", usage)
+
+	reportTokenUsage(gapi.TokenUsage())
+}
+
+// reportTokenUsage prints the requests each token of a pool sent and the rate limit it has left.
+func reportTokenUsage(usage []githubapi.TokenUsage) {
+	if len(usage) < 2 {
+		return
 	}
 
-	serr := gapi.SyncForks(ctx, "", *verboseFlag, *debugFlag)
+	for _, tok := range usage {
+		name := tok.Name
+		if len(tok.Owners) > 0 {
+			name += " (" + strings.Join(tok.Owners, ", ") + ")"
+		}
+
+		if tok.Rate.Limit == 0 {
+			fmt.Printf("   %s: %d API call(s)\n", name, tok.Calls)
+
+			continue
+		}
+
+		fmt.Printf("   %s: %d API call(s), %d of %d left until %s\n", name, tok.Calls, tok.Rate.Remaining,
+			tok.Rate.Limit, tok.Rate.Reset.Local().Format(time.TimeOnly))
+	}
+}
+
+// historyStore opens the run history in dir, or in the per-user default directory when dir is empty.
+func historyStore(dir string) (*history.Store, error) {
+	if len(dir) == 0 {
+		ddir, derr := history.DefaultDir()
+		if derr != nil {
+			return nil, fmt.Errorf("history.DefaultDir error: %w", derr)
+		}
+
+		dir = ddir
+	}
+
+	store, serr := history.NewStore(dir)
+	if serr != nil {
+		return nil, fmt.Errorf("history.NewStore error: %w", serr)
+	}
+
+	return store, nil
+}
+
+// recordRun saves the branch heads the report changed so the run can be rolled back. Nothing is
+// saved for dry-runs or when no branch changed.
+func recordRun(store *history.Store, rpt *githubapi.SyncReport) error {
+	if store == nil || rpt == nil {
+		return nil
+	}
+
+	run := history.NewRun(rpt, time.Now())
+	if len(run.Entries) == 0 {
+		return nil
+	}
+
+	path, serr := store.Save(run)
 	if serr != nil {
+		return fmt.Errorf("history.Save error: %w", serr)
+	}
+
+	fmt.Printf("-> Run %s recorded %d branch change(s) in %s, undo with: rollback -run %s\n",
+		run.ID, len(run.Entries), path, run.ID)
+
+	return nil
+}
+
+// notifyFailures lists the configured notification targets of every fork that failed to sync.
+func notifyFailures(rpt *githubapi.SyncReport, notify map[string][]string) {
+	if rpt == nil || len(notify) == 0 {
+		return
+	}
+
+	for _, res := range rpt.Failed() {
+		if targets := notify[strings.ToLower(res.FullName())]; len(targets) > 0 {
+			fmt.Printf("-> Notify %s: %s %s failed to sync\n", strings.Join(targets, ", "), res.FullName(), res.Branch)
+		}
+	}
+}
+
+func syncForks(ctx context.Context, gapi *githubapi.GitHubAPI, store *history.Store, params *environment.Parameters,
+	opts githubapi.SyncOptions) error {
+	rpt, serr := gapi.SyncForks(ctx, params.User, opts)
+
+	if rerr := recordRun(store, rpt); rerr != nil {
+		return rerr
+	}
+
+	notifyFailures(rpt, params.RepoNotify)
+
+	if serr != nil && !errors.Is(serr, githubapi.ErrSyncFailures) {
 		return fmt.Errorf("SyncForks error: %w", serr)
 	}
+
+	// with -continue-on-error the forks planned before and after a failure are still worth writing out
+	if len(params.PlanOut) > 0 {
+		plan := rpt.Plan()
+
+		werr := githubapi.WritePlanFile(params.PlanOut, plan)
+		if werr != nil {
+			return fmt.Errorf("WritePlanFile error: %w", werr)
+		}
+
+		fmt.Printf("-> Plan with %d entries written to %s\n", len(plan.Entries), params.PlanOut)
+	}
+
+	if serr != nil {
+		return &ExitError{Code: ExitSyncFailures, Err: fmt.Errorf("SyncForks error: %w", serr)}
+	}
+
+	return nil
+}
+
+func apply(ctx context.Context, gapi *githubapi.GitHubAPI, store *history.Store, planFile string,
+	opts githubapi.SyncOptions) error {
+	plan, rerr := githubapi.ReadPlanFile(planFile)
+	if rerr != nil {
+		return fmt.Errorf("ReadPlanFile error: %w", rerr)
+	}
+
+	rpt, aerr := gapi.ApplyPlan(ctx, plan, opts)
+
+	if herr := recordRun(store, rpt); herr != nil {
+		return herr
+	}
+
+	if errors.Is(aerr, githubapi.ErrSyncFailures) {
+		return &ExitError{Code: ExitSyncFailures, Err: fmt.Errorf("ApplyPlan error: %w", aerr)}
+	}
+
+	if aerr != nil {
+		return fmt.Errorf("ApplyPlan error: %w", aerr)
+	}
+
+	return nil
+}
+
+// rollback moves the branches of a recorded run back, or lists the recorded runs when runID is empty.
+func rollback(ctx context.Context, gapi *githubapi.GitHubAPI, store *history.Store, runID string,
+	opts githubapi.SyncOptions) error {
+	if len(runID) == 0 {
+		ids, lerr := store.List()
+		if lerr != nil {
+			return fmt.Errorf("history.List error: %w", lerr)
+		}
+
+		if len(ids) == 0 {
+			return fmt.Errorf("no runs recorded in %s", store.Dir)
+		}
+
+		return fmt.Errorf("missing -run, recorded runs:\n  %s", strings.Join(ids, "\n  "))
+	}
+
+	run, lerr := store.Load(runID)
+	if lerr != nil {
+		return fmt.Errorf("history.Load error: %w", lerr)
+	}
+
+	_, rerr := gapi.Rollback(ctx, run.Entries, opts)
+	if errors.Is(rerr, githubapi.ErrSyncFailures) {
+		return &ExitError{Code: ExitSyncFailures, Err: fmt.Errorf("Rollback error: %w", rerr)}
+	}
+
+	if rerr != nil {
+		return fmt.Errorf("Rollback error: %w", rerr)
+	}
+
 	return nil
 }
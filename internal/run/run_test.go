@@ -1 +1,103 @@
 package run_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/mjdusa/github-fork-update/internal/environment"
+	"github.com/mjdusa/github-fork-update/internal/githubapi"
+	"github.com/mjdusa/github-fork-update/internal/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "nil error", err: nil, want: run.ExitOK},
+		{name: "plain error", err: fmt.Errorf("boom"), want: run.ExitFailure},
+		{
+			name: "wrapped sync failures",
+			err:  fmt.Errorf("Process error: %w", &run.ExitError{Code: run.ExitSyncFailures, Err: fmt.Errorf("x")}),
+			want: run.ExitSyncFailures,
+		},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			assert.Equal(t, tst.want, run.ExitCode(tst.err))
+		})
+	}
+}
+
+func TestProcessNilParameters(t *testing.T) {
+	err := run.Process(context.Background(), nil)
+	if err == nil {
+		t.Errorf("Process should have returned an error")
+	}
+}
+
+func TestProcessPlanOutWithFailures(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/user", func(wtr http.ResponseWriter, _ *http.Request) {
+		wtr.Header().Set(githubapi.ScopesHeader, "repo, workflow")
+		fmt.Fprint(wtr, `{"login":"octocat"}`)
+	})
+	mux.HandleFunc("/api/v3/users/octocat", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `{"login":"octocat"}`)
+	})
+	mux.HandleFunc("/api/v3/user/repos", func(wtr http.ResponseWriter, req *http.Request) {
+		if req.FormValue("page") != "1" {
+			fmt.Fprint(wtr, `[]`)
+
+			return
+		}
+
+		fmt.Fprint(wtr, `[{"name":"app","fork":true,"default_branch":"main","owner":{"login":"octocat"}},
+			{"name":"broken","fork":true,"default_branch":"main","owner":{"login":"octocat"}}]`)
+	})
+	mux.HandleFunc("/api/v3/repos/octocat/app", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `{"name":"app","parent":{"name":"app","default_branch":"main","owner":{"login":"upstream"}}}`)
+	})
+	mux.HandleFunc("/api/v3/repos/octocat/app/git/ref/heads/main", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `{"object":{"type":"commit","sha":"fork-sha"}}`)
+	})
+	mux.HandleFunc("/api/v3/repos/upstream/app/compare/", func(wtr http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(wtr, `{"status":"behind","ahead_by":0,"behind_by":1,"base_commit":{"sha":"fork-sha"},
+			"commits":[{"sha":"upstream-sha"}]}`)
+	})
+	mux.HandleFunc("/api/v3/repos/octocat/broken", func(wtr http.ResponseWriter, req *http.Request) {
+		http.NotFound(wtr, req)
+	})
+
+	srvr := httptest.NewServer(mux)
+	defer srvr.Close()
+
+	planOut := filepath.Join(t.TempDir(), "plan.json")
+	params := environment.Parameters{
+		AuthSource:      environment.GitHubTokenEnv,
+		TokenSource:     githubapi.StaticTokenSource("token", environment.GitHubTokenEnv),
+		BaseURL:         srvr.URL,
+		Archived:        githubapi.ArchivedInclude,
+		DryRun:          true,
+		ContinueOnError: true,
+		PlanOut:         planOut,
+		NoCache:         true,
+		NoHistory:       true,
+	}
+
+	err := run.Process(context.Background(), &params)
+	assert.Equal(t, run.ExitSyncFailures, run.ExitCode(err))
+
+	plan, rerr := githubapi.ReadPlanFile(planOut)
+	if assert.NoError(t, rerr, "the plan is written despite the failed fork") && assert.Len(t, plan.Entries, 1) {
+		assert.Equal(t, "app", plan.Entries[0].Repo)
+		assert.Equal(t, githubapi.MergeTypeFastForward, plan.Entries[0].Action)
+	}
+}